@@ -0,0 +1,12 @@
+CREATE TABLE refresh_tokens(
+    id varchar(255) primary key ,
+    family_id varchar(255) not null ,
+    user_id varchar(255) not null ,
+    role varchar(20) not null ,
+    expires_at timestamp not null ,
+    used_at timestamp ,
+    revoked_at timestamp ,
+    created_at timestamp not null
+);
+
+CREATE INDEX idx_refresh_tokens_family_id ON refresh_tokens(family_id);
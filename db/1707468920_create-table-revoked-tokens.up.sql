@@ -0,0 +1,5 @@
+CREATE TABLE revoked_tokens(
+    jti varchar(255) primary key ,
+    expires_at timestamp not null ,
+    created_at timestamp not null
+);
@@ -0,0 +1,2 @@
+ALTER TABLE students DROP COLUMN verified_at;
+ALTER TABLE teachers DROP COLUMN verified_at;
@@ -0,0 +1,5 @@
+ALTER TABLE students ADD COLUMN verified_at TIMESTAMP;
+ALTER TABLE teachers ADD COLUMN verified_at TIMESTAMP;
+
+UPDATE students SET verified_at = created_at;
+UPDATE teachers SET verified_at = created_at;
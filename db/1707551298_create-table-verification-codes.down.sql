@@ -0,0 +1 @@
+DROP TABLE verification_codes;
\ No newline at end of file
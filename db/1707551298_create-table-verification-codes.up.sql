@@ -0,0 +1,12 @@
+CREATE TABLE verification_codes(
+    id serial primary key ,
+    user_id varchar(255) not null ,
+    role varchar(20) not null ,
+    code_hash varchar(255) not null ,
+    attempts int not null default 0 ,
+    expires_at timestamp not null ,
+    consumed_at timestamp ,
+    created_at timestamp not null
+);
+
+CREATE INDEX idx_verification_codes_user ON verification_codes(user_id, role);
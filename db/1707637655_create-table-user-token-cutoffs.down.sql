@@ -0,0 +1 @@
+DROP TABLE user_token_cutoffs;
\ No newline at end of file
@@ -0,0 +1,9 @@
+CREATE TABLE user_token_cutoffs(
+    user_id varchar(255) not null ,
+    role varchar(20) not null ,
+    not_before timestamp not null ,
+    keep_session_id varchar(255) ,
+    updated_at timestamp not null ,
+
+    CONSTRAINT pk_user_token_cutoffs PRIMARY KEY (user_id, role)
+);
@@ -0,0 +1,9 @@
+CREATE TABLE password_reset_tokens(
+    id serial primary key ,
+    user_id varchar(255) not null ,
+    role varchar(20) not null ,
+    token_hash varchar(255) not null UNIQUE ,
+    expires_at timestamp not null ,
+    used_at timestamp ,
+    created_at timestamp not null
+);
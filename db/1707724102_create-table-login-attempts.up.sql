@@ -0,0 +1,12 @@
+CREATE TABLE login_attempts(
+    id serial primary key ,
+    email varchar(255) not null ,
+    role varchar(20) not null ,
+    ip_address varchar(64) not null ,
+    success boolean not null ,
+    reason varchar(50) ,
+    created_at timestamp not null
+);
+
+CREATE INDEX login_attempts_account_idx ON login_attempts(email, role, created_at);
+CREATE INDEX login_attempts_ip_idx ON login_attempts(ip_address, created_at);
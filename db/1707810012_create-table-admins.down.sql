@@ -0,0 +1 @@
+DROP TABLE admins;
\ No newline at end of file
@@ -0,0 +1,9 @@
+CREATE TABLE admins(
+    id varchar(255) primary key ,
+    name varchar(255) not null ,
+    email varchar(255) not null UNIQUE ,
+    password varchar(255) not null ,
+    created_at TIMESTAMP NOT NULL ,
+    updated_at TIMESTAMP NOT NULL ,
+    deleted_at TIMESTAMP
+);
@@ -0,0 +1,3 @@
+ALTER TABLE students DROP COLUMN suspended_at;
+ALTER TABLE teachers DROP COLUMN approved_at;
+ALTER TABLE teachers DROP COLUMN suspended_at;
\ No newline at end of file
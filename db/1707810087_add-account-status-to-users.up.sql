@@ -0,0 +1,5 @@
+ALTER TABLE students ADD COLUMN suspended_at TIMESTAMP;
+ALTER TABLE teachers ADD COLUMN approved_at TIMESTAMP;
+ALTER TABLE teachers ADD COLUMN suspended_at TIMESTAMP;
+
+UPDATE teachers SET approved_at = created_at;
@@ -0,0 +1 @@
+DROP TABLE class_staff;
\ No newline at end of file
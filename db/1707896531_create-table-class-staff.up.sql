@@ -0,0 +1,12 @@
+CREATE TABLE class_staff(
+    class_id int references classes NOT NULL ,
+    teacher_id varchar(255) references teachers NOT NULL ,
+    role varchar(20) not null ,
+    invited_by varchar(255) ,
+    accepted_at TIMESTAMP ,
+    created_at TIMESTAMP NOT NULL ,
+    PRIMARY KEY (class_id, teacher_id)
+);
+
+INSERT INTO class_staff(class_id, teacher_id, role, accepted_at, created_at)
+SELECT id, teacher_id, 'owner', created_at, created_at FROM classes;
@@ -0,0 +1,2 @@
+DROP TABLE oidc_identities;
+DROP TABLE oidc_login_requests;
\ No newline at end of file
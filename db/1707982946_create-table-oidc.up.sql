@@ -0,0 +1,19 @@
+CREATE TABLE oidc_login_requests(
+    id serial primary key ,
+    state_hash varchar(255) not null UNIQUE ,
+    role varchar(20) not null ,
+    nonce varchar(255) not null ,
+    code_verifier varchar(255) not null ,
+    expires_at timestamp not null ,
+    used_at timestamp ,
+    created_at timestamp not null
+);
+
+CREATE TABLE oidc_identities(
+    issuer varchar(255) not null ,
+    subject varchar(255) not null ,
+    role varchar(20) not null ,
+    user_id varchar(255) not null ,
+    created_at timestamp not null ,
+    PRIMARY KEY (issuer, subject, role)
+);
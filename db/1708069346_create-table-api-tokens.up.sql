@@ -0,0 +1,15 @@
+CREATE TABLE api_tokens(
+    id varchar(255) primary key ,
+    user_id varchar(255) not null ,
+    role varchar(20) not null ,
+    name varchar(100) not null ,
+    prefix varchar(20) not null ,
+    token_hash varchar(255) not null UNIQUE ,
+    scopes text[] not null ,
+    last_used_at timestamp ,
+    expires_at timestamp ,
+    revoked_at timestamp ,
+    created_at timestamp not null
+);
+
+CREATE INDEX api_tokens_user_idx ON api_tokens(user_id, role);
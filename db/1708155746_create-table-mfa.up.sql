@@ -0,0 +1,20 @@
+CREATE TABLE totp_secrets(
+    user_id varchar(255) not null ,
+    role varchar(20) not null ,
+    secret varchar(255) not null ,
+    confirmed_at timestamp ,
+    last_used_step bigint ,
+    created_at timestamp not null ,
+    PRIMARY KEY (user_id, role)
+);
+
+CREATE TABLE mfa_recovery_codes(
+    id serial primary key ,
+    user_id varchar(255) not null ,
+    role varchar(20) not null ,
+    code_hash varchar(255) not null ,
+    used_at timestamp ,
+    created_at timestamp not null
+);
+
+CREATE INDEX mfa_recovery_codes_user_idx ON mfa_recovery_codes(user_id, role);
@@ -0,0 +1,13 @@
+CREATE TABLE sessions(
+    id varchar(255) primary key ,
+    user_id varchar(255) not null ,
+    role varchar(20) not null ,
+    user_agent varchar(512) not null ,
+    ip_address varchar(64) not null ,
+    last_active_at timestamp not null ,
+    expires_at timestamp not null ,
+    revoked_at timestamp ,
+    created_at timestamp not null
+);
+
+CREATE INDEX sessions_user_idx ON sessions(user_id, role);
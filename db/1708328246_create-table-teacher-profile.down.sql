@@ -0,0 +1 @@
+DROP TABLE teacher_profile;
\ No newline at end of file
@@ -0,0 +1,10 @@
+CREATE TABLE teacher_profile(
+    id varchar(255) primary key references teachers ,
+    title varchar(50) not null ,
+    department varchar(100) not null ,
+    office_hours varchar(255) not null ,
+    bio text not null ,
+    avatar_url varchar(512) not null ,
+    created_at timestamp not null ,
+    updated_at timestamp not null
+);
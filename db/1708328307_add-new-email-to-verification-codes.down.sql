@@ -0,0 +1 @@
+ALTER TABLE verification_codes DROP COLUMN new_email;
\ No newline at end of file
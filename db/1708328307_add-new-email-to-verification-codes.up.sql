@@ -0,0 +1 @@
+ALTER TABLE verification_codes ADD COLUMN new_email varchar(255);
@@ -0,0 +1,3 @@
+DROP SEQUENCE anonymized_student_nim;
+ALTER TABLE students DROP COLUMN anonymized_at;
+ALTER TABLE students DROP COLUMN deletion_scheduled_at;
\ No newline at end of file
@@ -0,0 +1,5 @@
+ALTER TABLE students ADD COLUMN deletion_scheduled_at TIMESTAMP;
+ALTER TABLE students ADD COLUMN anonymized_at TIMESTAMP;
+
+-- Anonymized students keep a unique, numeric placeholder NIM.
+CREATE SEQUENCE anonymized_student_nim;
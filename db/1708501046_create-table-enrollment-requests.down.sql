@@ -0,0 +1,4 @@
+DROP INDEX student_class_active_idx;
+DROP TABLE enrollment_requests;
+ALTER TABLE classes DROP COLUMN capacity;
+ALTER TABLE classes DROP COLUMN enrollment_mode;
\ No newline at end of file
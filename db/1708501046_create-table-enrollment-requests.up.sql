@@ -0,0 +1,25 @@
+ALTER TABLE classes ADD COLUMN enrollment_mode varchar(20) NOT NULL DEFAULT 'open';
+ALTER TABLE classes ADD COLUMN capacity int;
+
+-- An active enrollment is one that has not been left yet.
+ALTER TABLE student_class ALTER COLUMN deleted_at DROP NOT NULL;
+
+-- A student is in a class at most once. Duplicates from concurrent joins are
+-- ended, keeping the first.
+UPDATE student_class sc SET deleted_at = now() WHERE sc.deleted_at IS NULL AND EXISTS (
+    SELECT 1 FROM student_class o WHERE o.class_id = sc.class_id AND o.student_id = sc.student_id AND o.deleted_at IS NULL AND o.id < sc.id
+);
+CREATE UNIQUE INDEX student_class_active_idx ON student_class(class_id, student_id) WHERE deleted_at IS NULL;
+
+CREATE TABLE enrollment_requests(
+    id serial primary key ,
+    class_id int references classes NOT NULL ,
+    student_id varchar(255) references students NOT NULL ,
+    status varchar(20) not null ,
+    decided_by varchar(255) ,
+    decided_at timestamp ,
+    created_at timestamp not null
+);
+
+CREATE UNIQUE INDEX enrollment_requests_open_idx ON enrollment_requests(class_id, student_id) WHERE status IN ('pending', 'waitlisted');
+CREATE INDEX enrollment_requests_class_idx ON enrollment_requests(class_id, status, created_at);
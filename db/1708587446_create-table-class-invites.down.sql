@@ -0,0 +1,2 @@
+DROP TABLE class_invites;
+ALTER TABLE classes DROP COLUMN key_expires_at;
\ No newline at end of file
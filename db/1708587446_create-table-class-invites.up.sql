@@ -0,0 +1,15 @@
+ALTER TABLE classes ADD COLUMN key_expires_at TIMESTAMP;
+
+CREATE TABLE class_invites(
+    id serial primary key ,
+    class_id int references classes NOT NULL ,
+    code_hash varchar(255) not null UNIQUE ,
+    created_by varchar(255) not null ,
+    max_uses int ,
+    uses int not null default 0 ,
+    expires_at timestamp ,
+    revoked_at timestamp ,
+    created_at timestamp not null
+);
+
+CREATE INDEX class_invites_class_idx ON class_invites(class_id);
@@ -0,0 +1 @@
+ALTER TABLE classes DROP COLUMN archived_at;
\ No newline at end of file
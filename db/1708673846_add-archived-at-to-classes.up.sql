@@ -0,0 +1 @@
+ALTER TABLE classes ADD COLUMN archived_at TIMESTAMP;
@@ -0,0 +1,9 @@
+ALTER TABLE classes ADD COLUMN day varchar(10);
+ALTER TABLE classes ADD COLUMN start_time time;
+ALTER TABLE classes ADD COLUMN end_time time;
+
+UPDATE classes SET day = m.weekday::varchar, start_time = m.start_time, end_time = m.end_time
+FROM (SELECT DISTINCT ON (class_id) class_id, weekday, start_time, end_time FROM class_meetings ORDER BY class_id, weekday, start_time) m
+WHERE m.class_id = classes.id;
+
+DROP TABLE class_meetings;
\ No newline at end of file
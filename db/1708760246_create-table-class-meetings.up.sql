@@ -0,0 +1,22 @@
+CREATE TABLE class_meetings(
+    id serial primary key ,
+    class_id int references classes NOT NULL ,
+    -- ISO weekday: 1 is monday, 7 is sunday.
+    weekday smallint not null CHECK (weekday BETWEEN 1 AND 7) ,
+    start_time time not null ,
+    end_time time not null ,
+    room varchar(50) not null DEFAULT '' ,
+    timezone varchar(64) not null DEFAULT 'UTC' ,
+    created_at timestamp not null ,
+    CHECK (end_time > start_time)
+);
+
+CREATE INDEX class_meetings_class_idx ON class_meetings(class_id, weekday, start_time);
+
+-- Every class met once a week so far.
+INSERT INTO class_meetings(class_id, weekday, start_time, end_time, created_at)
+SELECT id, day::smallint, start_time, end_time, now() FROM classes WHERE day ~ '^[1-7]$' AND end_time > start_time;
+
+ALTER TABLE classes DROP COLUMN day;
+ALTER TABLE classes DROP COLUMN start_time;
+ALTER TABLE classes DROP COLUMN end_time;
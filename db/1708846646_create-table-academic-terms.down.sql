@@ -0,0 +1,2 @@
+ALTER TABLE classes DROP COLUMN term_id;
+DROP TABLE academic_terms;
\ No newline at end of file
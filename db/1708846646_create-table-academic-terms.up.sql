@@ -0,0 +1,17 @@
+CREATE TABLE academic_terms(
+    id serial primary key ,
+    name varchar(50) not null UNIQUE ,
+    starts_on date not null ,
+    ends_on date not null ,
+    -- Students can join the term's classes only inside this window. An open
+    -- end means no limit on that side.
+    enrollment_opens_at timestamp ,
+    enrollment_closes_at timestamp ,
+    created_at timestamp not null ,
+    updated_at timestamp not null ,
+    CHECK (ends_on >= starts_on) ,
+    CHECK (enrollment_closes_at > enrollment_opens_at)
+);
+
+ALTER TABLE classes ADD COLUMN term_id int references academic_terms;
+CREATE INDEX classes_term_idx ON classes(term_id);
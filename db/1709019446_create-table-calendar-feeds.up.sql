@@ -0,0 +1,9 @@
+-- One private subscription URL per user, kept as a hash like other tokens.
+CREATE TABLE calendar_feeds(
+    user_id varchar(255) not null ,
+    role varchar(20) not null ,
+    token_hash varchar(255) not null UNIQUE ,
+    last_used_at timestamp ,
+    created_at timestamp not null ,
+    PRIMARY KEY (user_id, role)
+);
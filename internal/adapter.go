@@ -33,7 +33,7 @@ func ConnectDatabase() *sqlx.DB {
 		databaseServer.dbName))
 
 	if err != nil {
-		fmt.Printf("Failed to connect database, err:", err)
+		fmt.Printf("Failed to connect database, err: %v\n", err)
 	}
 
 	return db
@@ -0,0 +1,259 @@
+package authz
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/rifkhia/lms-remake/internal/pkg"
+	"github.com/rifkhia/lms-remake/internal/utils"
+)
+
+type Action string
+
+const (
+	READ   Action = "read"
+	CREATE Action = "create"
+	UPDATE Action = "update"
+	DELETE Action = "delete"
+	JOIN   Action = "join"
+	LEAVE  Action = "leave"
+	MANAGE Action = "manage"
+)
+
+type ResourceType string
+
+const (
+	CLASS      ResourceType = "class"
+	SECTION    ResourceType = "section"
+	MATERIAL   ResourceType = "material"
+	SUBMISSION ResourceType = "submission"
+	PROFILE    ResourceType = "profile"
+	STAFF      ResourceType = "staff"
+	TERM       ResourceType = "term"
+)
+
+// API token scopes. Each grants a set of actions, see scopeRequired.
+const (
+	CLASSES_READ     = "classes:read"
+	CLASSES_WRITE    = "classes:write"
+	SUBMISSIONS_READ = "submissions:read"
+	PROFILE_READ     = "profile:read"
+	PROFILE_WRITE    = "profile:write"
+	USERS_MANAGE     = "users:manage"
+)
+
+// Scopes lists every scope an API token may carry.
+var Scopes = []string{CLASSES_READ, CLASSES_WRITE, SUBMISSIONS_READ, PROFILE_READ, PROFILE_WRITE, USERS_MANAGE}
+
+// Subject is who is asking.
+type Subject struct {
+	UserID uuid.UUID
+	Role   string
+	// Scopes limits a subject authenticated by an API token to part of what
+	// its user may do. It is nil for a login session, which is not limited.
+	Scopes []string
+}
+
+// HasScope tells whether the subject may act within scope.
+func (s Subject) HasScope(scope string) bool {
+	if s.Scopes == nil {
+		return true
+	}
+
+	for _, granted := range s.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Resource carries the facts the rules need. Callers load them, the rules only
+// compare them, so the policy stays free of database access.
+type Resource struct {
+	Type ResourceType
+	// OwnerID is the user a profile belongs to.
+	OwnerID uuid.UUID
+	// StaffRole is the subject's role in the staff of the class the resource
+	// belongs to, empty when the subject is not staff there.
+	StaffRole string
+	// IsMember tells whether the subject is enrolled in that class.
+	IsMember bool
+}
+
+type Rule func(subject Subject, resource Resource) bool
+
+func isAdmin(subject Subject, _ Resource) bool {
+	return subject.Role == utils.ADMIN_ROLE
+}
+
+func isTeacher(subject Subject, _ Resource) bool {
+	return subject.Role == utils.TEACHER_ROLE
+}
+
+func isStudent(subject Subject, _ Resource) bool {
+	return subject.Role == utils.STUDENT_ROLE
+}
+
+func isAuthenticated(subject Subject, _ Resource) bool {
+	return subject.UserID != uuid.Nil
+}
+
+func isClassOwner(subject Subject, resource Resource) bool {
+	return subject.Role == utils.TEACHER_ROLE && resource.StaffRole == utils.CLASS_STAFF_OWNER
+}
+
+// isClassTeacher is the owner or a co-teacher, who may change class content.
+func isClassTeacher(subject Subject, resource Resource) bool {
+	return subject.Role == utils.TEACHER_ROLE && (resource.StaffRole == utils.CLASS_STAFF_OWNER || resource.StaffRole == utils.CLASS_STAFF_CO_TEACHER)
+}
+
+// isClassStaff also includes assistants, who may read content and submissions.
+func isClassStaff(subject Subject, resource Resource) bool {
+	return subject.Role == utils.TEACHER_ROLE && resource.StaffRole != ""
+}
+
+func isClassMember(subject Subject, resource Resource) bool {
+	return subject.Role == utils.STUDENT_ROLE && resource.IsMember
+}
+
+func isOwner(subject Subject, resource Resource) bool {
+	return subject.UserID == resource.OwnerID
+}
+
+// policies lists, per resource type and action, the rules of which any one
+// grants access. Anything not listed is denied.
+var policies = map[ResourceType]map[Action][]Rule{
+	CLASS: {
+		READ:   {isAuthenticated},
+		CREATE: {isTeacher},
+		UPDATE: {isClassOwner, isAdmin},
+		DELETE: {isClassOwner, isAdmin},
+		MANAGE: {isClassTeacher, isAdmin},
+		JOIN:   {isStudent},
+		LEAVE:  {isClassMember},
+	},
+	STAFF: {
+		READ:   {isClassStaff, isAdmin},
+		CREATE: {isClassOwner, isAdmin},
+		DELETE: {isClassOwner, isAdmin},
+	},
+	SECTION: {
+		READ:   {isClassStaff, isClassMember, isAdmin},
+		CREATE: {isClassTeacher},
+		UPDATE: {isClassTeacher},
+		DELETE: {isClassTeacher, isAdmin},
+	},
+	MATERIAL: {
+		READ:   {isClassStaff, isClassMember, isAdmin},
+		CREATE: {isClassTeacher},
+		UPDATE: {isClassTeacher},
+		DELETE: {isClassTeacher, isAdmin},
+	},
+	SUBMISSION: {
+		READ:   {isClassStaff, isAdmin},
+		CREATE: {isClassMember},
+	},
+	PROFILE: {
+		READ:   {isOwner, isAdmin},
+		UPDATE: {isOwner},
+		DELETE: {isOwner, isAdmin},
+	},
+	TERM: {
+		READ:   {isAuthenticated},
+		CREATE: {isAdmin},
+		UPDATE: {isAdmin},
+		DELETE: {isAdmin},
+	},
+}
+
+// scopeRequired is the scope an API token needs on top of the policy. Actions
+// not listed here can't be done with an API token at all.
+var scopeRequired = map[ResourceType]map[Action]string{
+	CLASS: {
+		READ:   CLASSES_READ,
+		CREATE: CLASSES_WRITE,
+		UPDATE: CLASSES_WRITE,
+		DELETE: CLASSES_WRITE,
+		MANAGE: CLASSES_WRITE,
+	},
+	STAFF: {
+		READ:   CLASSES_READ,
+		CREATE: CLASSES_WRITE,
+		DELETE: CLASSES_WRITE,
+	},
+	SECTION: {
+		READ:   CLASSES_READ,
+		CREATE: CLASSES_WRITE,
+		UPDATE: CLASSES_WRITE,
+		DELETE: CLASSES_WRITE,
+	},
+	MATERIAL: {
+		READ:   CLASSES_READ,
+		CREATE: CLASSES_WRITE,
+		UPDATE: CLASSES_WRITE,
+		DELETE: CLASSES_WRITE,
+	},
+	SUBMISSION: {
+		READ: SUBMISSIONS_READ,
+	},
+	PROFILE: {
+		READ:   PROFILE_READ,
+		UPDATE: PROFILE_WRITE,
+		DELETE: PROFILE_WRITE,
+	},
+	TERM: {
+		READ:   CLASSES_READ,
+		CREATE: CLASSES_WRITE,
+		UPDATE: CLASSES_WRITE,
+		DELETE: CLASSES_WRITE,
+	},
+}
+
+func Can(subject Subject, action Action, resource Resource) bool {
+	if subject.UserID == uuid.Nil {
+		return false
+	}
+
+	if subject.Scopes != nil {
+		scope, ok := scopeRequired[resource.Type][action]
+		if !ok || !subject.HasScope(scope) {
+			return false
+		}
+	}
+
+	for _, rule := range policies[resource.Type][action] {
+		if rule(subject, resource) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AuthorizeScope is for actions outside the policy, which an API token may
+// only do when it carries scope.
+func AuthorizeScope(subject Subject, scope string) pkg.CustomError {
+	if subject.HasScope(scope) {
+		return pkg.CustomError{}
+	}
+
+	return pkg.CustomError{
+		Code:    utils.FORBIDDEN,
+		Cause:   fmt.Errorf("api token is missing the %s scope", scope),
+		Service: utils.USECASE_SERVICE,
+	}
+}
+
+// Authorize is Can as a CustomError, ready to be returned from a usecase.
+func Authorize(subject Subject, action Action, resource Resource) pkg.CustomError {
+	if Can(subject, action, resource) {
+		return pkg.CustomError{}
+	}
+
+	return pkg.CustomError{
+		Code:    utils.FORBIDDEN,
+		Cause:   fmt.Errorf("you are not allowed to %s this %s", action, resource.Type),
+		Service: utils.USECASE_SERVICE,
+	}
+}
@@ -0,0 +1,275 @@
+package authz
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/rifkhia/lms-remake/internal/utils"
+)
+
+// persona is a subject together with its relation to the class the resource
+// belongs to.
+type persona struct {
+	subject   Subject
+	staffRole string
+	isMember  bool
+}
+
+const (
+	admin        = "admin"
+	owner        = "class owner"
+	coTeacher    = "co-teacher"
+	assistant    = "assistant"
+	member       = "enrolled student"
+	nonMember    = "non-member student"
+	otherTeacher = "other teacher"
+	anonymous    = "anonymous"
+)
+
+var personas = map[string]persona{
+	admin:        {subject: Subject{UserID: uuid.New(), Role: utils.ADMIN_ROLE}},
+	owner:        {subject: Subject{UserID: uuid.New(), Role: utils.TEACHER_ROLE}, staffRole: utils.CLASS_STAFF_OWNER},
+	coTeacher:    {subject: Subject{UserID: uuid.New(), Role: utils.TEACHER_ROLE}, staffRole: utils.CLASS_STAFF_CO_TEACHER},
+	assistant:    {subject: Subject{UserID: uuid.New(), Role: utils.TEACHER_ROLE}, staffRole: utils.CLASS_STAFF_ASSISTANT},
+	member:       {subject: Subject{UserID: uuid.New(), Role: utils.STUDENT_ROLE}, isMember: true},
+	nonMember:    {subject: Subject{UserID: uuid.New(), Role: utils.STUDENT_ROLE}},
+	otherTeacher: {subject: Subject{UserID: uuid.New(), Role: utils.TEACHER_ROLE}},
+	anonymous:    {subject: Subject{Role: utils.STUDENT_ROLE}, isMember: true},
+}
+
+// profileOwner owns the profile every persona asks about.
+var profileOwner = member
+
+var resourceTypes = []ResourceType{CLASS, STAFF, SECTION, MATERIAL, SUBMISSION, PROFILE, TERM}
+
+var actions = []Action{READ, CREATE, UPDATE, DELETE, JOIN, LEAVE, MANAGE}
+
+func allowed(names ...string) map[string]bool {
+	result := make(map[string]bool)
+	for _, name := range names {
+		result[name] = true
+	}
+	return result
+}
+
+var everyone = allowed(admin, owner, coTeacher, assistant, member, nonMember, otherTeacher)
+
+// accessMatrix is who may do what with a login session. A missing action
+// means nobody may do it.
+var accessMatrix = map[ResourceType]map[Action]map[string]bool{
+	CLASS: {
+		READ:   everyone,
+		CREATE: allowed(owner, coTeacher, assistant, otherTeacher),
+		UPDATE: allowed(owner, admin),
+		DELETE: allowed(owner, admin),
+		MANAGE: allowed(owner, coTeacher, admin),
+		JOIN:   allowed(member, nonMember),
+		LEAVE:  allowed(member),
+	},
+	STAFF: {
+		READ:   allowed(owner, coTeacher, assistant, admin),
+		CREATE: allowed(owner, admin),
+		DELETE: allowed(owner, admin),
+	},
+	SECTION: {
+		READ:   allowed(owner, coTeacher, assistant, member, admin),
+		CREATE: allowed(owner, coTeacher),
+		UPDATE: allowed(owner, coTeacher),
+		DELETE: allowed(owner, coTeacher, admin),
+	},
+	MATERIAL: {
+		READ:   allowed(owner, coTeacher, assistant, member, admin),
+		CREATE: allowed(owner, coTeacher),
+		UPDATE: allowed(owner, coTeacher),
+		DELETE: allowed(owner, coTeacher, admin),
+	},
+	SUBMISSION: {
+		READ:   allowed(owner, coTeacher, assistant, admin),
+		CREATE: allowed(member),
+	},
+	PROFILE: {
+		READ:   allowed(profileOwner, admin),
+		UPDATE: allowed(profileOwner),
+		DELETE: allowed(profileOwner, admin),
+	},
+	TERM: {
+		READ:   everyone,
+		CREATE: allowed(admin),
+		UPDATE: allowed(admin),
+		DELETE: allowed(admin),
+	},
+}
+
+// scopeMatrix is the scope an API token needs for each action. A missing
+// action can't be done with an API token at all.
+var scopeMatrix = map[ResourceType]map[Action]string{
+	CLASS: {
+		READ:   CLASSES_READ,
+		CREATE: CLASSES_WRITE,
+		UPDATE: CLASSES_WRITE,
+		DELETE: CLASSES_WRITE,
+		MANAGE: CLASSES_WRITE,
+	},
+	STAFF: {
+		READ:   CLASSES_READ,
+		CREATE: CLASSES_WRITE,
+		DELETE: CLASSES_WRITE,
+	},
+	SECTION: {
+		READ:   CLASSES_READ,
+		CREATE: CLASSES_WRITE,
+		UPDATE: CLASSES_WRITE,
+		DELETE: CLASSES_WRITE,
+	},
+	MATERIAL: {
+		READ:   CLASSES_READ,
+		CREATE: CLASSES_WRITE,
+		UPDATE: CLASSES_WRITE,
+		DELETE: CLASSES_WRITE,
+	},
+	SUBMISSION: {
+		READ: SUBMISSIONS_READ,
+	},
+	PROFILE: {
+		READ:   PROFILE_READ,
+		UPDATE: PROFILE_WRITE,
+		DELETE: PROFILE_WRITE,
+	},
+	TERM: {
+		READ:   CLASSES_READ,
+		CREATE: CLASSES_WRITE,
+		UPDATE: CLASSES_WRITE,
+		DELETE: CLASSES_WRITE,
+	},
+}
+
+func resourceFor(resourceType ResourceType, p persona) Resource {
+	return Resource{
+		Type:      resourceType,
+		OwnerID:   personas[profileOwner].subject.UserID,
+		StaffRole: p.staffRole,
+		IsMember:  p.isMember,
+	}
+}
+
+func TestCanSessionMatrix(t *testing.T) {
+	for _, resourceType := range resourceTypes {
+		for _, action := range actions {
+			for name, p := range personas {
+				want := accessMatrix[resourceType][action][name]
+				t.Run(fmt.Sprintf("%s/%s/%s", resourceType, action, name), func(t *testing.T) {
+					got := Can(p.subject, action, resourceFor(resourceType, p))
+					if got != want {
+						t.Fatalf("Can = %v, want %v", got, want)
+					}
+				})
+			}
+		}
+	}
+}
+
+// API tokens belong to teachers and admins. A token may do what its owner may,
+// but only with the scope the action needs.
+func TestCanAPITokenScopes(t *testing.T) {
+	tokenOwners := []string{admin, owner, coTeacher, assistant, otherTeacher}
+
+	for _, resourceType := range resourceTypes {
+		for _, action := range actions {
+			required, needsScope := scopeMatrix[resourceType][action]
+			for _, name := range tokenOwners {
+				p := personas[name]
+				resource := resourceFor(resourceType, p)
+				policyAllows := accessMatrix[resourceType][action][name]
+
+				for _, scope := range Scopes {
+					subject := p.subject
+					subject.Scopes = []string{scope}
+					want := policyAllows && needsScope && scope == required
+					t.Run(fmt.Sprintf("%s/%s/%s/with %s", resourceType, action, name, scope), func(t *testing.T) {
+						if got := Can(subject, action, resource); got != want {
+							t.Fatalf("Can = %v, want %v", got, want)
+						}
+					})
+
+					subject.Scopes = without(Scopes, scope)
+					want = policyAllows && needsScope && scope != required
+					t.Run(fmt.Sprintf("%s/%s/%s/without %s", resourceType, action, name, scope), func(t *testing.T) {
+						if got := Can(subject, action, resource); got != want {
+							t.Fatalf("Can = %v, want %v", got, want)
+						}
+					})
+				}
+
+				subject := p.subject
+				subject.Scopes = []string{}
+				t.Run(fmt.Sprintf("%s/%s/%s/no scopes", resourceType, action, name), func(t *testing.T) {
+					if Can(subject, action, resource) {
+						t.Fatal("a token without scopes must not be allowed anything")
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestScopeMatrixCoversPolicies(t *testing.T) {
+	for resourceType, rules := range scopeRequired {
+		for action, scope := range rules {
+			if scopeMatrix[resourceType][action] != scope {
+				t.Errorf("%s/%s requires %q, the test expects %q", resourceType, action, scope, scopeMatrix[resourceType][action])
+			}
+		}
+	}
+
+	for resourceType, rules := range policies {
+		for action := range rules {
+			if _, ok := accessMatrix[resourceType][action]; !ok {
+				t.Errorf("%s/%s has rules the test doesn't cover", resourceType, action)
+			}
+		}
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	p := personas[nonMember]
+
+	customError := Authorize(p.subject, LEAVE, resourceFor(CLASS, p))
+	if customError.Cause == nil || customError.Code != utils.FORBIDDEN {
+		t.Fatalf("expected a forbidden error, got %+v", customError)
+	}
+
+	customError = Authorize(p.subject, JOIN, resourceFor(CLASS, p))
+	if customError.Cause != nil {
+		t.Fatalf("expected no error, got %s", customError.Cause)
+	}
+}
+
+func TestAuthorizeScope(t *testing.T) {
+	session := personas[admin].subject
+	if customError := AuthorizeScope(session, USERS_MANAGE); customError.Cause != nil {
+		t.Fatalf("a login session has every scope, got %s", customError.Cause)
+	}
+
+	token := session
+	token.Scopes = []string{USERS_MANAGE}
+	if customError := AuthorizeScope(token, USERS_MANAGE); customError.Cause != nil {
+		t.Fatalf("expected no error, got %s", customError.Cause)
+	}
+
+	token.Scopes = without(Scopes, USERS_MANAGE)
+	customError := AuthorizeScope(token, USERS_MANAGE)
+	if customError.Cause == nil || customError.Code != utils.FORBIDDEN {
+		t.Fatalf("expected a forbidden error, got %+v", customError)
+	}
+}
+
+func without(scopes []string, scope string) []string {
+	var result []string
+	for _, s := range scopes {
+		if s != scope {
+			result = append(result, s)
+		}
+	}
+	return result
+}
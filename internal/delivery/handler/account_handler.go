@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/rifkhia/lms-remake/internal/delivery/middleware"
+	"github.com/rifkhia/lms-remake/internal/dto"
+	"github.com/rifkhia/lms-remake/internal/pkg"
+	"github.com/rifkhia/lms-remake/internal/usecase"
+	"github.com/rifkhia/lms-remake/internal/utils"
+)
+
+type AccountHandlerImpl struct {
+	accountUsecase usecase.AccountUsecase
+	authMiddleware *middleware.AuthMiddleware
+}
+
+func (handler AccountHandlerImpl) Route(app *fiber.App) {
+	app.Get("/v1/me", handler.authMiddleware.JWTGuardAll, handler.FetchMe)
+	app.Patch("/v1/me", handler.authMiddleware.JWTGuardAll, handler.UpdateMe)
+	app.Post("/v1/me/email/confirm", handler.authMiddleware.JWTGuardAll, handler.authMiddleware.SessionOnly, handler.ConfirmEmailChange)
+}
+
+func (handler *AccountHandlerImpl) FetchMe(c *fiber.Ctx) error {
+	principal := middleware.GetPrincipal(c)
+
+	me, customError := handler.accountUsecase.FetchMe(c.Context(), principal.Subject())
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "success get account",
+		"data":    me,
+	})
+}
+
+func (handler *AccountHandlerImpl) UpdateMe(c *fiber.Ctx) error {
+	var request dto.UpdateMeRequest
+	principal := middleware.GetPrincipal(c)
+
+	err := c.BodyParser(&request)
+	if err != nil {
+		customError := pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   err,
+			Service: utils.HANDLER_SERVICE,
+		}
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	me, customError := handler.accountUsecase.UpdateMe(c.Context(), principal.Subject(), &request)
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	message := "account updated"
+	if me.PendingEmail != "" {
+		message = "account updated, confirm the new email with the code sent to it"
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": message,
+		"data":    me,
+	})
+}
+
+func (handler *AccountHandlerImpl) ConfirmEmailChange(c *fiber.Ctx) error {
+	var request dto.ConfirmEmailChangeRequest
+	principal := middleware.GetPrincipal(c)
+
+	err := c.BodyParser(&request)
+	if err != nil {
+		customError := pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   err,
+			Service: utils.HANDLER_SERVICE,
+		}
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	me, customError := handler.accountUsecase.ConfirmEmailChange(c.Context(), principal.Subject(), &request)
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "email changed",
+		"data":    me,
+	})
+}
+
+func NewAccountHandler(accountUsecase usecase.AccountUsecase, authMiddleware *middleware.AuthMiddleware) *AccountHandlerImpl {
+	return &AccountHandlerImpl{
+		accountUsecase: accountUsecase,
+		authMiddleware: authMiddleware,
+	}
+}
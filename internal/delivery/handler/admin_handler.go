@@ -0,0 +1,255 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/rifkhia/lms-remake/internal/authz"
+	"github.com/rifkhia/lms-remake/internal/delivery/middleware"
+	"github.com/rifkhia/lms-remake/internal/dto"
+	"github.com/rifkhia/lms-remake/internal/pkg"
+	"github.com/rifkhia/lms-remake/internal/usecase"
+	"github.com/rifkhia/lms-remake/internal/utils"
+	"strconv"
+)
+
+type AdminHandlerImpl struct {
+	adminUsecase   usecase.AdminUsecase
+	authMiddleware *middleware.AuthMiddleware
+}
+
+// accountRoles maps the :type path segment to a role.
+var accountRoles = map[string]string{
+	"students": utils.STUDENT_ROLE,
+	"teachers": utils.TEACHER_ROLE,
+}
+
+func (handler AdminHandlerImpl) Route(app *fiber.App) {
+	app.Post("/v1/admin/login", handler.LoginAdmin)
+
+	manageClasses := handler.authMiddleware.RequireScope(authz.CLASSES_WRITE)
+	manageUsers := handler.authMiddleware.RequireScope(authz.USERS_MANAGE)
+
+	admin := app.Group("/v1/admin")
+	admin.Put("/classes/:id/teacher", handler.authMiddleware.JWTGuardAdmin, manageClasses, handler.ReassignClassTeacher)
+	admin.Get("/:type", handler.authMiddleware.JWTGuardAdmin, manageUsers, handler.FetchUsers)
+	admin.Post("/:type/:id/approve", handler.authMiddleware.JWTGuardAdmin, manageUsers, handler.ApproveUser)
+	admin.Post("/:type/:id/suspend", handler.authMiddleware.JWTGuardAdmin, manageUsers, handler.SuspendUser)
+	admin.Post("/:type/:id/restore", handler.authMiddleware.JWTGuardAdmin, manageUsers, handler.RestoreUser)
+	admin.Post("/:type/:id/reset-password", handler.authMiddleware.JWTGuardAdmin, handler.authMiddleware.SessionOnly, handler.ResetUserPassword)
+	admin.Post("/:type/:id/reset-2fa", handler.authMiddleware.JWTGuardAdmin, manageUsers, handler.ResetUserMFA)
+	admin.Delete("/:type/:id", handler.authMiddleware.JWTGuardAdmin, manageUsers, handler.DeleteUser)
+}
+
+// parseAccount reads the :type and :id path params of the user routes.
+func parseAccount(c *fiber.Ctx) (string, uuid.UUID, pkg.CustomError) {
+	role, ok := accountRoles[c.Params("type")]
+	if !ok {
+		return "", uuid.Nil, pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("account type must be students or teachers"),
+			Service: utils.HANDLER_SERVICE,
+		}
+	}
+
+	if c.Params("id") == "" {
+		return role, uuid.Nil, pkg.CustomError{}
+	}
+
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return "", uuid.Nil, pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   err,
+			Service: utils.HANDLER_SERVICE,
+		}
+	}
+
+	return role, id, pkg.CustomError{}
+}
+
+func (handler *AdminHandlerImpl) LoginAdmin(c *fiber.Ctx) error {
+	var request dto.AdminLoginRequest
+	err := c.BodyParser(&request)
+	if err != nil {
+		customError := pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   err,
+			Service: utils.HANDLER_SERVICE,
+		}
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	data, customError := handler.adminUsecase.Login(c.Context(), &request, clientInfo(c))
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "login success",
+		"data":    data,
+	})
+}
+
+func (handler *AdminHandlerImpl) FetchUsers(c *fiber.Ctx) error {
+	role, _, customError := parseAccount(c)
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	data, customError := handler.adminUsecase.FetchUsers(c.Context(), role, c.Query("search"), c.Query("status"))
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "success get users",
+		"data":    data,
+	})
+}
+
+func (handler *AdminHandlerImpl) ApproveUser(c *fiber.Ctx) error {
+	role, id, customError := parseAccount(c)
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	customError = handler.adminUsecase.ApproveUser(c.Context(), role, id)
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "user approved",
+	})
+}
+
+func (handler *AdminHandlerImpl) SuspendUser(c *fiber.Ctx) error {
+	role, id, customError := parseAccount(c)
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	customError = handler.adminUsecase.SuspendUser(c.Context(), role, id)
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "user suspended",
+	})
+}
+
+func (handler *AdminHandlerImpl) RestoreUser(c *fiber.Ctx) error {
+	role, id, customError := parseAccount(c)
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	customError = handler.adminUsecase.RestoreUser(c.Context(), role, id)
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "user restored",
+	})
+}
+
+func (handler *AdminHandlerImpl) DeleteUser(c *fiber.Ctx) error {
+	role, id, customError := parseAccount(c)
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	customError = handler.adminUsecase.DeleteUser(c.Context(), role, id)
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "user deleted",
+	})
+}
+
+func (handler *AdminHandlerImpl) ResetUserPassword(c *fiber.Ctx) error {
+	var request dto.AdminResetPasswordRequest
+	role, id, customError := parseAccount(c)
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	if len(c.Body()) > 0 {
+		err := c.BodyParser(&request)
+		if err != nil {
+			customError := pkg.CustomError{
+				Code:    utils.BAD_REQUEST,
+				Cause:   err,
+				Service: utils.HANDLER_SERVICE,
+			}
+			return c.Status(customError.Code).JSON(customError.Error())
+		}
+	}
+
+	data, customError := handler.adminUsecase.ResetUserPassword(c.Context(), role, id, &request)
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "password reset success",
+		"data":    data,
+	})
+}
+
+func (handler *AdminHandlerImpl) ResetUserMFA(c *fiber.Ctx) error {
+	role, id, customError := parseAccount(c)
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	customError = handler.adminUsecase.ResetUserMFA(c.Context(), role, id)
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "two-factor authentication reset",
+	})
+}
+
+func (handler *AdminHandlerImpl) ReassignClassTeacher(c *fiber.Ctx) error {
+	var request dto.ReassignClassTeacherRequest
+	classId, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "input integer only for class id",
+		})
+	}
+
+	err = c.BodyParser(&request)
+	if err != nil {
+		customError := pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   err,
+			Service: utils.HANDLER_SERVICE,
+		}
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	customError := handler.adminUsecase.ReassignClassTeacher(c.Context(), classId, &request)
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "class teacher reassigned",
+	})
+}
+
+func NewAdminHandler(adminUsecase usecase.AdminUsecase, authMiddleware *middleware.AuthMiddleware) *AdminHandlerImpl {
+	return &AdminHandlerImpl{
+		adminUsecase:   adminUsecase,
+		authMiddleware: authMiddleware,
+	}
+}
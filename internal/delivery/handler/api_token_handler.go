@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/rifkhia/lms-remake/internal/delivery/middleware"
+	"github.com/rifkhia/lms-remake/internal/dto"
+	"github.com/rifkhia/lms-remake/internal/pkg"
+	"github.com/rifkhia/lms-remake/internal/usecase"
+	"github.com/rifkhia/lms-remake/internal/utils"
+)
+
+type APITokenHandlerImpl struct {
+	apiTokenUsecase usecase.APITokenUsecase
+	authMiddleware  *middleware.AuthMiddleware
+}
+
+func (handler APITokenHandlerImpl) Route(app *fiber.App) {
+	app.Get("/v1/auth/api-tokens", handler.authMiddleware.JWTGuardAll, handler.authMiddleware.SessionOnly, handler.FetchAPITokens)
+	app.Post("/v1/auth/api-tokens", handler.authMiddleware.JWTGuardAll, handler.authMiddleware.SessionOnly, handler.CreateAPIToken)
+	app.Delete("/v1/auth/api-tokens/:id", handler.authMiddleware.JWTGuardAll, handler.authMiddleware.SessionOnly, handler.RevokeAPIToken)
+}
+
+func (handler *APITokenHandlerImpl) FetchAPITokens(c *fiber.Ctx) error {
+	principal := middleware.GetPrincipal(c)
+
+	tokens, customError := handler.apiTokenUsecase.FetchAPITokens(c.Context(), principal.Subject())
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "success get api tokens",
+		"data":    tokens,
+	})
+}
+
+func (handler *APITokenHandlerImpl) CreateAPIToken(c *fiber.Ctx) error {
+	var request dto.CreateAPITokenRequest
+	principal := middleware.GetPrincipal(c)
+
+	err := c.BodyParser(&request)
+	if err != nil {
+		customError := pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   err,
+			Service: utils.HANDLER_SERVICE,
+		}
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	data, customError := handler.apiTokenUsecase.CreateAPIToken(c.Context(), principal.Subject(), &request)
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "api token created, copy it now as it won't be shown again",
+		"data":    data,
+	})
+}
+
+func (handler *APITokenHandlerImpl) RevokeAPIToken(c *fiber.Ctx) error {
+	principal := middleware.GetPrincipal(c)
+
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		customError := pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   err,
+			Service: utils.HANDLER_SERVICE,
+		}
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	customError := handler.apiTokenUsecase.RevokeAPIToken(c.Context(), principal.Subject(), id)
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "api token revoked",
+	})
+}
+
+func NewAPITokenHandler(apiTokenUsecase usecase.APITokenUsecase, authMiddleware *middleware.AuthMiddleware) *APITokenHandlerImpl {
+	return &APITokenHandlerImpl{
+		apiTokenUsecase: apiTokenUsecase,
+		authMiddleware:  authMiddleware,
+	}
+}
@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/rifkhia/lms-remake/internal/delivery/middleware"
+	"github.com/rifkhia/lms-remake/internal/dto"
+	"github.com/rifkhia/lms-remake/internal/pkg"
+	"github.com/rifkhia/lms-remake/internal/usecase"
+	"github.com/rifkhia/lms-remake/internal/utils"
+)
+
+type AuthHandlerImpl struct {
+	authUsecase    usecase.AuthUsecase
+	authMiddleware *middleware.AuthMiddleware
+}
+
+func (handler AuthHandlerImpl) Route(app *fiber.App) {
+	app.Post("/v1/auth/refresh", handler.RefreshToken)
+	app.Post("/v1/auth/logout", handler.authMiddleware.JWTGuardAll, handler.authMiddleware.SessionOnly, handler.Logout)
+	app.Post("/v1/auth/forgot-password", handler.ForgotPassword)
+	app.Post("/v1/auth/reset-password", handler.ResetPassword)
+	app.Post("/v1/auth/change-password", handler.authMiddleware.JWTGuardAll, handler.authMiddleware.SessionOnly, handler.ChangePassword)
+	app.Get("/.well-known/jwks.json", handler.FetchJWKS)
+}
+
+func (handler *AuthHandlerImpl) RefreshToken(c *fiber.Ctx) error {
+	var request dto.RefreshTokenRequest
+	err := c.BodyParser(&request)
+	if err != nil {
+		customError := pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   err,
+			Service: utils.HANDLER_SERVICE,
+		}
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	data, customError := handler.authUsecase.RefreshToken(c.Context(), &request, clientInfo(c))
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "token refreshed",
+		"data":    data,
+	})
+}
+
+func (handler *AuthHandlerImpl) Logout(c *fiber.Ctx) error {
+	var request dto.LogoutRequest
+	principal := middleware.GetPrincipal(c)
+
+	if len(c.Body()) > 0 {
+		err := c.BodyParser(&request)
+		if err != nil {
+			customError := pkg.CustomError{
+				Code:    utils.BAD_REQUEST,
+				Cause:   err,
+				Service: utils.HANDLER_SERVICE,
+			}
+			return c.Status(customError.Code).JSON(customError.Error())
+		}
+	}
+
+	customError := handler.authUsecase.Logout(c.Context(), principal.UserID, principal.TokenID, principal.ExpiresAt, principal.SessionID, &request)
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	c.ClearCookie("token")
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "logout success",
+	})
+}
+
+func (handler *AuthHandlerImpl) ForgotPassword(c *fiber.Ctx) error {
+	var request dto.ForgotPasswordRequest
+	err := c.BodyParser(&request)
+	if err != nil {
+		customError := pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   err,
+			Service: utils.HANDLER_SERVICE,
+		}
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	customError := handler.authUsecase.ForgotPassword(c.Context(), &request)
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "if the email is registered, a reset link has been sent",
+	})
+}
+
+func (handler *AuthHandlerImpl) ResetPassword(c *fiber.Ctx) error {
+	var request dto.ResetPasswordRequest
+	err := c.BodyParser(&request)
+	if err != nil {
+		customError := pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   err,
+			Service: utils.HANDLER_SERVICE,
+		}
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	customError := handler.authUsecase.ResetPassword(c.Context(), &request)
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	c.ClearCookie("token")
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "password reset success",
+	})
+}
+
+func (handler *AuthHandlerImpl) ChangePassword(c *fiber.Ctx) error {
+	var request dto.ChangePasswordRequest
+	principal := middleware.GetPrincipal(c)
+
+	err := c.BodyParser(&request)
+	if err != nil {
+		customError := pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   err,
+			Service: utils.HANDLER_SERVICE,
+		}
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	data, customError := handler.authUsecase.ChangePassword(c.Context(), principal.UserID, principal.Role, &request, clientInfo(c))
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "password changed",
+		"data":    data,
+	})
+}
+
+func (handler *AuthHandlerImpl) FetchJWKS(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.Status(fiber.StatusOK).JSON(utils.JWKS())
+}
+
+func NewAuthHandler(authUsecase usecase.AuthUsecase, authMiddleware *middleware.AuthMiddleware) *AuthHandlerImpl {
+	return &AuthHandlerImpl{
+		authUsecase:    authUsecase,
+		authMiddleware: authMiddleware,
+	}
+}
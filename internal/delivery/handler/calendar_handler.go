@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/rifkhia/lms-remake/internal/delivery/middleware"
+	"github.com/rifkhia/lms-remake/internal/usecase"
+	"strings"
+)
+
+type CalendarHandlerImpl struct {
+	calendarUsecase usecase.CalendarUsecase
+	authMiddleware  *middleware.AuthMiddleware
+}
+
+func (handler CalendarHandlerImpl) Route(app *fiber.App) {
+	app.Get("/v1/calendar", handler.authMiddleware.JWTGuardAll, handler.ExportCalendar)
+	app.Post("/v1/calendar/feed", handler.authMiddleware.JWTGuardAll, handler.authMiddleware.SessionOnly, handler.CreateCalendarFeed)
+	app.Delete("/v1/calendar/feed", handler.authMiddleware.JWTGuardAll, handler.authMiddleware.SessionOnly, handler.DeleteCalendarFeed)
+	app.Get("/v1/calendar/:token", handler.ExportCalendarFeed)
+}
+
+func (handler *CalendarHandlerImpl) ExportCalendar(c *fiber.Ctx) error {
+	principal := middleware.GetPrincipal(c)
+
+	calendar, customError := handler.calendarUsecase.ExportCalendar(c.Context(), principal.Subject(), c.Query("deadlines"))
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	c.Attachment("schedule.ics")
+	return sendCalendar(c, calendar)
+}
+
+// ExportCalendarFeed is opened by calendar apps, so the token in the path
+// takes the place of a bearer header.
+func (handler *CalendarHandlerImpl) ExportCalendarFeed(c *fiber.Ctx) error {
+	token := strings.TrimSuffix(c.Params("token"), ".ics")
+
+	calendar, customError := handler.calendarUsecase.ExportCalendarFeed(c.Context(), token, c.Query("deadlines"))
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	c.Set(fiber.HeaderCacheControl, "private, no-store")
+	return sendCalendar(c, calendar)
+}
+
+func (handler *CalendarHandlerImpl) CreateCalendarFeed(c *fiber.Ctx) error {
+	principal := middleware.GetPrincipal(c)
+
+	feed, customError := handler.calendarUsecase.CreateCalendarFeed(c.Context(), principal.Subject())
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "success create calendar feed",
+		"data":    feed,
+	})
+}
+
+func (handler *CalendarHandlerImpl) DeleteCalendarFeed(c *fiber.Ctx) error {
+	principal := middleware.GetPrincipal(c)
+
+	customError := handler.calendarUsecase.DeleteCalendarFeed(c.Context(), principal.Subject())
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "success delete calendar feed",
+	})
+}
+
+func sendCalendar(c *fiber.Ctx, calendar []byte) error {
+	c.Set(fiber.HeaderContentType, "text/calendar; charset=utf-8")
+	return c.Status(fiber.StatusOK).Send(calendar)
+}
+
+func NewCalendarHandler(calendarUsecase usecase.CalendarUsecase, authMiddleware *middleware.AuthMiddleware) *CalendarHandlerImpl {
+	return &CalendarHandlerImpl{
+		calendarUsecase: calendarUsecase,
+		authMiddleware:  authMiddleware,
+	}
+}
@@ -5,31 +5,58 @@ import (
 	"fmt"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
+	"github.com/rifkhia/lms-remake/internal/authz"
 	"github.com/rifkhia/lms-remake/internal/delivery/middleware"
 	"github.com/rifkhia/lms-remake/internal/dto"
 	"github.com/rifkhia/lms-remake/internal/models"
 	"github.com/rifkhia/lms-remake/internal/pkg"
 	"github.com/rifkhia/lms-remake/internal/usecase"
 	"github.com/rifkhia/lms-remake/internal/utils"
-	"github.com/spf13/viper"
-	"github.com/supabase-community/storage-go"
 	"strconv"
 )
 
 type ClassHandlerImpl struct {
 	classUsecase   usecase.ClassUsecase
 	studentUsecase usecase.StudentUsecase
+	authMiddleware *middleware.AuthMiddleware
 }
 
+// Route only requires a logged in user, who may do what is decided by the
+// authz policy in the class usecase.
 func (handler ClassHandlerImpl) Route(app *fiber.App) {
-	app.Get("/v1/class/:id", middleware.JWTGuardAll, handler.FetchClassById)
-	app.Get("/v1/class", middleware.JWTGuardAll, handler.FetchClassByName)
-	app.Post("v1/class", middleware.JWTGuardTeacher, handler.CreateClass)
-	app.Post("/v1/class/:id/join", middleware.JWTGuardStudent, handler.StudentJoinClass)
-	app.Post("v1/class/:id/section", middleware.JWTGuardTeacher, handler.CreateClassSection)
-	app.Post("v1/class/section/:section_id/submissions", middleware.JWTGuardTeacher, handler.AddSubmissionsTeacher)
-	app.Get("v1/class/section/:section_id/submissions", middleware.JWTGuardTeacher, handler.FetchSubmission)
-	app.Post("v1/class/section/:section_id", middleware.JWTGuardStudent, handler.AddSubmissionsStudent)
+	app.Get("/v1/class/:id", handler.authMiddleware.JWTGuardAll, handler.FetchClassById)
+	app.Get("/v1/class", handler.authMiddleware.JWTGuardAll, handler.authMiddleware.RequireScope(authz.CLASSES_READ), handler.FetchClassByName)
+	app.Post("v1/class", handler.authMiddleware.JWTGuardAll, handler.CreateClass)
+	app.Patch("/v1/class/:id", handler.authMiddleware.JWTGuardAll, handler.UpdateClass)
+	app.Delete("/v1/class/:id", handler.authMiddleware.JWTGuardAll, handler.DeleteClass)
+	app.Post("/v1/class/:id/archive", handler.authMiddleware.JWTGuardAll, handler.ArchiveClass)
+	app.Post("/v1/class/:id/unarchive", handler.authMiddleware.JWTGuardAll, handler.UnarchiveClass)
+	app.Post("/v1/class/:id/restore", handler.authMiddleware.JWTGuardAll, handler.RestoreClass)
+	app.Post("/v1/class/:id/clone", handler.authMiddleware.JWTGuardAll, handler.CloneClass)
+	app.Get("/v1/student/schedules/conflicts", handler.authMiddleware.JWTGuardStudent, handler.FetchScheduleConflicts)
+	app.Post("/v1/class/:id/join", handler.authMiddleware.JWTGuardAll, handler.StudentJoinClass)
+	app.Get("/v1/class/invite/:token", handler.authMiddleware.JWTGuardAll, handler.FetchClassInvite)
+	app.Post("/v1/class/invite/:token", handler.authMiddleware.JWTGuardAll, handler.JoinClassByInvite)
+	app.Post("/v1/class/:id/key", handler.authMiddleware.JWTGuardAll, handler.RotateClassKey)
+	app.Delete("/v1/class/:id/key", handler.authMiddleware.JWTGuardAll, handler.DisableClassKey)
+	app.Get("/v1/class/:id/invites", handler.authMiddleware.JWTGuardAll, handler.FetchClassInvites)
+	app.Post("/v1/class/:id/invites", handler.authMiddleware.JWTGuardAll, handler.CreateClassInvite)
+	app.Delete("/v1/class/:id/invites/:invite_id", handler.authMiddleware.JWTGuardAll, handler.RevokeClassInvite)
+	app.Delete("/v1/class/:id/join", handler.authMiddleware.JWTGuardAll, handler.WithdrawJoinRequest)
+	app.Post("/v1/class/:id/leave", handler.authMiddleware.JWTGuardAll, handler.StudentLeaveClass)
+	app.Patch("/v1/class/:id/enrollment", handler.authMiddleware.JWTGuardAll, handler.UpdateClassEnrollment)
+	app.Post("/v1/class/:id/students", handler.authMiddleware.JWTGuardAll, handler.EnrollStudent)
+	app.Get("/v1/class/:id/requests", handler.authMiddleware.JWTGuardAll, handler.FetchEnrollmentRequests)
+	app.Post("/v1/class/:id/requests/:request_id/approve", handler.authMiddleware.JWTGuardAll, handler.ApproveEnrollmentRequest)
+	app.Post("/v1/class/:id/requests/:request_id/reject", handler.authMiddleware.JWTGuardAll, handler.RejectEnrollmentRequest)
+	app.Get("/v1/class/:id/staff", handler.authMiddleware.JWTGuardAll, handler.FetchClassStaff)
+	app.Post("/v1/class/:id/staff", handler.authMiddleware.JWTGuardAll, handler.InviteClassStaff)
+	app.Post("/v1/class/:id/staff/accept", handler.authMiddleware.JWTGuardAll, handler.AcceptClassStaffInvite)
+	app.Delete("/v1/class/:id/staff/:teacher_id", handler.authMiddleware.JWTGuardAll, handler.RemoveClassStaff)
+	app.Post("v1/class/:id/section", handler.authMiddleware.JWTGuardAll, handler.CreateClassSection)
+	app.Post("v1/class/section/:section_id/submissions", handler.authMiddleware.JWTGuardAll, handler.AddSubmissionsTeacher)
+	app.Get("v1/class/section/:section_id/submissions", handler.authMiddleware.JWTGuardAll, handler.FetchSubmission)
+	app.Post("v1/class/section/:section_id", handler.authMiddleware.JWTGuardAll, handler.AddSubmissionsStudent)
 }
 
 func (handler *ClassHandlerImpl) FetchClassById(c *fiber.Ctx) error {
@@ -47,7 +74,9 @@ func (handler *ClassHandlerImpl) FetchClassById(c *fiber.Ctx) error {
 		})
 	}
 
-	classResult, customError := handler.classUsecase.FetchClassById(c.Context(), classId)
+	principal := middleware.GetPrincipal(c)
+
+	classResult, customError := handler.classUsecase.FetchClassById(c.Context(), principal.Subject(), classId)
 	if customError.Cause != nil {
 		return c.Status(customError.Code).JSON(customError.Error())
 	}
@@ -66,7 +95,7 @@ func (handler *ClassHandlerImpl) FetchClassByName(c *fiber.Ctx) error {
 		})
 	}
 
-	classResult, customError := handler.classUsecase.FetchClassByName(c.Context(), param)
+	classResult, customError := handler.classUsecase.FetchClassByName(c.Context(), param, c.Query("term"))
 	if customError.Cause != nil {
 		return c.Status(customError.Code).JSON(customError.Error())
 	}
@@ -79,7 +108,9 @@ func (handler *ClassHandlerImpl) FetchClassByName(c *fiber.Ctx) error {
 
 func (handler *ClassHandlerImpl) CreateClass(c *fiber.Ctx) error {
 	var request *dto.ClassCreate
-	teacherId, err := middleware.GetIdFromToken(c)
+	principal := middleware.GetPrincipal(c)
+
+	err := c.BodyParser(&request)
 	if err != nil {
 		customError := pkg.CustomError{
 			Code:    utils.INTERNAL_SERVER_ERROR,
@@ -89,207 +120,586 @@ func (handler *ClassHandlerImpl) CreateClass(c *fiber.Ctx) error {
 		return c.Status(customError.Code).JSON(customError.Error())
 	}
 
-	parsedTeacherId, err := uuid.Parse(teacherId)
+	customError := handler.classUsecase.CreateClass(c.Context(), principal.Subject(), request)
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "class created",
+	})
+}
+
+func (handler *ClassHandlerImpl) UpdateClass(c *fiber.Ctx) error {
+	var request dto.UpdateClassRequest
+	principal := middleware.GetPrincipal(c)
+
+	classId, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "input integer only for class id",
+		})
+	}
+
+	err = c.BodyParser(&request)
 	if err != nil {
 		customError := pkg.CustomError{
-			Code:    utils.INTERNAL_SERVER_ERROR,
+			Code:    utils.BAD_REQUEST,
 			Cause:   err,
 			Service: utils.HANDLER_SERVICE,
 		}
 		return c.Status(customError.Code).JSON(customError.Error())
 	}
 
+	customError := handler.classUsecase.UpdateClass(c.Context(), principal.Subject(), classId, &request)
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "class updated",
+	})
+}
+
+func (handler *ClassHandlerImpl) CloneClass(c *fiber.Ctx) error {
+	var request dto.CloneClassRequest
+	principal := middleware.GetPrincipal(c)
+
+	classId, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "input integer only for class id",
+		})
+	}
+
 	err = c.BodyParser(&request)
 	if err != nil {
 		customError := pkg.CustomError{
-			Code:    utils.INTERNAL_SERVER_ERROR,
+			Code:    utils.BAD_REQUEST,
 			Cause:   err,
 			Service: utils.HANDLER_SERVICE,
 		}
 		return c.Status(customError.Code).JSON(customError.Error())
 	}
 
-	customError := handler.classUsecase.CreateClass(c.Context(), request, parsedTeacherId)
+	cloneId, customError := handler.classUsecase.CloneClass(c.Context(), principal.Subject(), classId, &request)
 	if customError.Cause != nil {
 		return c.Status(customError.Code).JSON(customError.Error())
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-		"message": "class created",
+		"message": "class cloned",
+		"data": fiber.Map{
+			"id": cloneId,
+		},
 	})
 }
 
-func (handler *ClassHandlerImpl) StudentJoinClass(c *fiber.Ctx) error {
-	param := c.Params("id")
-	if param == "" {
+func (handler *ClassHandlerImpl) FetchScheduleConflicts(c *fiber.Ctx) error {
+	principal := middleware.GetPrincipal(c)
+
+	classId, err := strconv.Atoi(c.Query("class_id"))
+	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "class id cannot be blank!",
+			"message": "input integer only for class id",
 		})
 	}
 
-	intId, _ := strconv.Atoi(param)
+	conflicts, customError := handler.classUsecase.CheckScheduleConflicts(c.Context(), principal.Subject(), classId)
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "success checking schedule conflicts",
+		"data":    conflicts,
+	})
+}
 
-	studentId, err := middleware.GetIdFromToken(c)
+func (handler *ClassHandlerImpl) ArchiveClass(c *fiber.Ctx) error {
+	principal := middleware.GetPrincipal(c)
+
+	classId, err := strconv.Atoi(c.Params("id"))
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"messsage": err.Error(),
+			"message": "input integer only for class id",
 		})
 	}
 
-	parsedStudentId, err := uuid.Parse(studentId)
+	customError := handler.classUsecase.ArchiveClass(c.Context(), principal.Subject(), classId)
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "class archived",
+	})
+}
+
+func (handler *ClassHandlerImpl) UnarchiveClass(c *fiber.Ctx) error {
+	principal := middleware.GetPrincipal(c)
+
+	classId, err := strconv.Atoi(c.Params("id"))
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"messsage": err.Error(),
+			"message": "input integer only for class id",
 		})
 	}
-	request := struct {
-		Key string `json:"key"`
-	}{}
-	err = c.BodyParser(&request)
-	if err != nil {
-		customError := pkg.CustomError{
-			Code:    utils.UNPROCESSABLE_ENTITY,
-			Cause:   err,
-			Service: utils.HANDLER_SERVICE,
-		}
+
+	customError := handler.classUsecase.UnarchiveClass(c.Context(), principal.Subject(), classId)
+	if customError.Cause != nil {
 		return c.Status(customError.Code).JSON(customError.Error())
 	}
 
-	customError := handler.classUsecase.JoinClass(c.Context(), parsedStudentId, intId, request.Key)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "class unarchived",
+	})
+}
+
+func (handler *ClassHandlerImpl) DeleteClass(c *fiber.Ctx) error {
+	principal := middleware.GetPrincipal(c)
+
+	classId, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "input integer only for class id",
+		})
+	}
+
+	customError := handler.classUsecase.DeleteClass(c.Context(), principal.Subject(), classId)
 	if customError.Cause != nil {
 		return c.Status(customError.Code).JSON(customError.Error())
 	}
 
-	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-		"message": "Success join class",
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "class deleted",
 	})
 }
 
-func (handler *ClassHandlerImpl) CreateClassSection(c *fiber.Ctx) error {
-	var request models.SectionClass
-	teacherId, err := middleware.GetIdFromToken(c)
+func (handler *ClassHandlerImpl) RestoreClass(c *fiber.Ctx) error {
+	principal := middleware.GetPrincipal(c)
+
+	classId, err := strconv.Atoi(c.Params("id"))
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"messsage": err.Error(),
+			"message": "input integer only for class id",
 		})
 	}
 
+	customError := handler.classUsecase.RestoreClass(c.Context(), principal.Subject(), classId)
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "class restored",
+	})
+}
+
+func (handler *ClassHandlerImpl) StudentJoinClass(c *fiber.Ctx) error {
 	param := c.Params("id")
 	if param == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "id cannot be blank!",
+			"message": "class id cannot be blank!",
 		})
 	}
 
-	err = c.BodyParser(&request)
+	intId, _ := strconv.Atoi(param)
+
+	principal := middleware.GetPrincipal(c)
+
+	var request dto.JoinClassRequest
+	err := c.BodyParser(&request)
 	if err != nil {
 		customError := pkg.CustomError{
-			Code:    utils.INTERNAL_SERVER_ERROR,
+			Code:    utils.UNPROCESSABLE_ENTITY,
 			Cause:   err,
 			Service: utils.HANDLER_SERVICE,
 		}
 		return c.Status(customError.Code).JSON(customError.Error())
 	}
 
-	request.ClassId, _ = strconv.Atoi(param)
+	status, customError := handler.classUsecase.JoinClass(c.Context(), principal.Subject(), intId, &request)
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
 
-	class, customError := handler.classUsecase.FetchClassById(c.Context(), request.ClassId)
+	return enrollmentResponse(c, status)
+}
+
+func (handler *ClassHandlerImpl) FetchClassInvite(c *fiber.Ctx) error {
+	principal := middleware.GetPrincipal(c)
+
+	class, customError := handler.classUsecase.FetchClassInvite(c.Context(), principal.Subject(), c.Params("token"))
 	if customError.Cause != nil {
 		return c.Status(customError.Code).JSON(customError.Error())
 	}
 
-	parseTeacherId, err := uuid.Parse(teacherId)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "success getting invite",
+		"data":    class,
+	})
+}
+
+func (handler *ClassHandlerImpl) JoinClassByInvite(c *fiber.Ctx) error {
+	principal := middleware.GetPrincipal(c)
+
+	status, customError := handler.classUsecase.JoinClassByInvite(c.Context(), principal.Subject(), c.Params("token"))
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return enrollmentResponse(c, status)
+}
+
+func (handler *ClassHandlerImpl) RotateClassKey(c *fiber.Ctx) error {
+	var request dto.RotateClassKeyRequest
+	principal := middleware.GetPrincipal(c)
+
+	classId, err := strconv.Atoi(c.Params("id"))
 	if err != nil {
-		customError := pkg.CustomError{
-			Code:    utils.INTERNAL_SERVER_ERROR,
-			Cause:   err,
-			Service: utils.HANDLER_SERVICE,
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "input integer only for class id",
+		})
+	}
+
+	if len(c.Body()) > 0 {
+		err = c.BodyParser(&request)
+		if err != nil {
+			customError := pkg.CustomError{
+				Code:    utils.BAD_REQUEST,
+				Cause:   err,
+				Service: utils.HANDLER_SERVICE,
+			}
+			return c.Status(customError.Code).JSON(customError.Error())
 		}
+	}
+
+	data, customError := handler.classUsecase.RotateClassKey(c.Context(), principal.Subject(), classId, &request)
+	if customError.Cause != nil {
 		return c.Status(customError.Code).JSON(customError.Error())
 	}
 
-	if class.TeacherId != parseTeacherId {
-		customError = pkg.CustomError{
-			Code:    utils.FORBIDDEN,
-			Cause:   errors.New("you don't have authority to this class"),
-			Service: utils.HANDLER_SERVICE,
-		}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "class key regenerated",
+		"data":    data,
+	})
+}
+
+func (handler *ClassHandlerImpl) DisableClassKey(c *fiber.Ctx) error {
+	principal := middleware.GetPrincipal(c)
+
+	classId, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "input integer only for class id",
+		})
+	}
+
+	customError := handler.classUsecase.DisableClassKey(c.Context(), principal.Subject(), classId)
+	if customError.Cause != nil {
 		return c.Status(customError.Code).JSON(customError.Error())
 	}
 
-	customError = handler.classUsecase.CreateSectionClass(c.Context(), &request)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "class key disabled",
+	})
+}
+
+func (handler *ClassHandlerImpl) FetchClassInvites(c *fiber.Ctx) error {
+	principal := middleware.GetPrincipal(c)
+
+	classId, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "input integer only for class id",
+		})
+	}
+
+	invites, customError := handler.classUsecase.FetchClassInvites(c.Context(), principal.Subject(), classId)
 	if customError.Cause != nil {
 		return c.Status(customError.Code).JSON(customError.Error())
 	}
 
-	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-		"message": fmt.Sprintf("section class %s created", request.Title),
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "success getting class invites",
+		"data":    invites,
 	})
 }
 
-func (handler *ClassHandlerImpl) AddSubmissionsTeacher(c *fiber.Ctx) error {
-	var request models.Submission
+func (handler *ClassHandlerImpl) CreateClassInvite(c *fiber.Ctx) error {
+	var request dto.CreateClassInviteRequest
+	principal := middleware.GetPrincipal(c)
 
-	Id, err := middleware.GetIdFromToken(c)
+	classId, err := strconv.Atoi(c.Params("id"))
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"messsage": err.Error(),
+			"message": "input integer only for class id",
 		})
 	}
 
-	parsedId, err := uuid.Parse(Id)
+	if len(c.Body()) > 0 {
+		err = c.BodyParser(&request)
+		if err != nil {
+			customError := pkg.CustomError{
+				Code:    utils.BAD_REQUEST,
+				Cause:   err,
+				Service: utils.HANDLER_SERVICE,
+			}
+			return c.Status(customError.Code).JSON(customError.Error())
+		}
+	}
+
+	data, customError := handler.classUsecase.CreateClassInvite(c.Context(), principal.Subject(), classId, &request)
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "invite created, the code is only shown once",
+		"data":    data,
+	})
+}
+
+func (handler *ClassHandlerImpl) RevokeClassInvite(c *fiber.Ctx) error {
+	principal := middleware.GetPrincipal(c)
+
+	classId, err := strconv.Atoi(c.Params("id"))
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"messsage": err.Error(),
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "input integer only for class id",
 		})
 	}
 
-	sectionClassId := c.Params("section_id")
-	if sectionClassId == "" {
+	inviteId, err := strconv.Atoi(c.Params("invite_id"))
+	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "id cannot be blank!",
+			"message": "input integer only for invite id",
 		})
 	}
 
-	intSectionClassId, err := strconv.Atoi(sectionClassId)
+	customError := handler.classUsecase.RevokeClassInvite(c.Context(), principal.Subject(), classId, inviteId)
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "invite revoked",
+	})
+}
+
+// enrollmentResponse reports where a student stands after asking to get into
+// a class: 201 when enrolled, 202 while waiting.
+func enrollmentResponse(c *fiber.Ctx, status string) error {
+	messages := map[string]string{
+		utils.ENROLLMENT_ENROLLED:   "Success join class",
+		utils.ENROLLMENT_PENDING:    "join request sent, waiting for approval",
+		utils.ENROLLMENT_WAITLISTED: "class is full, added to the waitlist",
+	}
+
+	code := fiber.StatusAccepted
+	if status == utils.ENROLLMENT_ENROLLED {
+		code = fiber.StatusCreated
+	}
+
+	return c.Status(code).JSON(fiber.Map{
+		"message": messages[status],
+		"data": fiber.Map{
+			"status": status,
+		},
+	})
+}
+
+func (handler *ClassHandlerImpl) WithdrawJoinRequest(c *fiber.Ctx) error {
+	principal := middleware.GetPrincipal(c)
+
+	classId, err := strconv.Atoi(c.Params("id"))
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"messsage": err.Error(),
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "input integer only for class id",
 		})
 	}
 
-	sectionClass, customError := handler.classUsecase.FetchSectionClassById(c.Context(), intSectionClassId)
+	customError := handler.classUsecase.WithdrawJoinRequest(c.Context(), principal.Subject(), classId)
 	if customError.Cause != nil {
 		return c.Status(customError.Code).JSON(customError.Error())
 	}
 
-	class, customError := handler.classUsecase.FetchClassById(c.Context(), sectionClass.ClassId)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "join request withdrawn",
+	})
+}
+
+func (handler *ClassHandlerImpl) StudentLeaveClass(c *fiber.Ctx) error {
+	principal := middleware.GetPrincipal(c)
+
+	classId, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "input integer only for class id",
+		})
+	}
+
+	customError := handler.classUsecase.LeftClass(c.Context(), principal.Subject(), classId)
 	if customError.Cause != nil {
 		return c.Status(customError.Code).JSON(customError.Error())
 	}
 
-	if class.TeacherId != parsedId {
-		customError = pkg.CustomError{
-			Code:    utils.FORBIDDEN,
-			Cause:   errors.New("you don't have authority to this class"),
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "left class",
+	})
+}
+
+func (handler *ClassHandlerImpl) UpdateClassEnrollment(c *fiber.Ctx) error {
+	var request dto.UpdateEnrollmentRequest
+	principal := middleware.GetPrincipal(c)
+
+	classId, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "input integer only for class id",
+		})
+	}
+
+	err = c.BodyParser(&request)
+	if err != nil {
+		customError := pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   err,
 			Service: utils.HANDLER_SERVICE,
 		}
 		return c.Status(customError.Code).JSON(customError.Error())
 	}
 
+	customError := handler.classUsecase.UpdateClassEnrollment(c.Context(), principal.Subject(), classId, &request)
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "enrollment settings updated",
+	})
+}
+
+func (handler *ClassHandlerImpl) EnrollStudent(c *fiber.Ctx) error {
+	var request dto.EnrollStudentRequest
+	principal := middleware.GetPrincipal(c)
+
+	classId, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "input integer only for class id",
+		})
+	}
+
 	err = c.BodyParser(&request)
 	if err != nil {
 		customError := pkg.CustomError{
-			Code:    utils.INTERNAL_SERVER_ERROR,
+			Code:    utils.BAD_REQUEST,
 			Cause:   err,
 			Service: utils.HANDLER_SERVICE,
 		}
 		return c.Status(customError.Code).JSON(customError.Error())
 	}
 
-	request.ClassSectionId = intSectionClassId
+	status, customError := handler.classUsecase.EnrollStudent(c.Context(), principal.Subject(), classId, &request)
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
 
-	file, err := c.FormFile("file")
+	return enrollmentResponse(c, status)
+}
+
+func (handler *ClassHandlerImpl) FetchEnrollmentRequests(c *fiber.Ctx) error {
+	principal := middleware.GetPrincipal(c)
+
+	classId, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "input integer only for class id",
+		})
+	}
+
+	requests, customError := handler.classUsecase.FetchEnrollmentRequests(c.Context(), principal.Subject(), classId, c.Query("status"))
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "success getting enrollment requests",
+		"data":    requests,
+	})
+}
+
+func (handler *ClassHandlerImpl) ApproveEnrollmentRequest(c *fiber.Ctx) error {
+	principal := middleware.GetPrincipal(c)
+
+	classId, requestId, err := enrollmentRequestParams(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	status, customError := handler.classUsecase.ApproveEnrollmentRequest(c.Context(), principal.Subject(), classId, requestId)
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "request approved",
+		"data": fiber.Map{
+			"status": status,
+		},
+	})
+}
+
+func (handler *ClassHandlerImpl) RejectEnrollmentRequest(c *fiber.Ctx) error {
+	principal := middleware.GetPrincipal(c)
+
+	classId, requestId, err := enrollmentRequestParams(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	customError := handler.classUsecase.RejectEnrollmentRequest(c.Context(), principal.Subject(), classId, requestId)
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "request rejected",
+	})
+}
+
+func enrollmentRequestParams(c *fiber.Ctx) (int, int, error) {
+	classId, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return 0, 0, errors.New("input integer only for class id")
+	}
+
+	requestId, err := strconv.Atoi(c.Params("request_id"))
+	if err != nil {
+		return 0, 0, errors.New("input integer only for request id")
+	}
+
+	return classId, requestId, nil
+}
+
+func (handler *ClassHandlerImpl) CreateClassSection(c *fiber.Ctx) error {
+	var request models.SectionClass
+	principal := middleware.GetPrincipal(c)
+
+	param := c.Params("id")
+	if param == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "id cannot be blank!",
+		})
+	}
+
+	err := c.BodyParser(&request)
 	if err != nil {
 		customError := pkg.CustomError{
 			Code:    utils.INTERNAL_SERVER_ERROR,
@@ -299,7 +709,38 @@ func (handler *ClassHandlerImpl) AddSubmissionsTeacher(c *fiber.Ctx) error {
 		return c.Status(customError.Code).JSON(customError.Error())
 	}
 
-	parsedFile, err := file.Open()
+	request.ClassId, _ = strconv.Atoi(param)
+
+	customError := handler.classUsecase.CreateSectionClass(c.Context(), principal.Subject(), &request)
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": fmt.Sprintf("section class %s created", request.Title),
+	})
+}
+
+func (handler *ClassHandlerImpl) AddSubmissionsTeacher(c *fiber.Ctx) error {
+	var request models.Submission
+
+	principal := middleware.GetPrincipal(c)
+
+	sectionClassId := c.Params("section_id")
+	if sectionClassId == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "id cannot be blank!",
+		})
+	}
+
+	intSectionClassId, err := strconv.Atoi(sectionClassId)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"messsage": err.Error(),
+		})
+	}
+
+	err = c.BodyParser(&request)
 	if err != nil {
 		customError := pkg.CustomError{
 			Code:    utils.INTERNAL_SERVER_ERROR,
@@ -309,8 +750,9 @@ func (handler *ClassHandlerImpl) AddSubmissionsTeacher(c *fiber.Ctx) error {
 		return c.Status(customError.Code).JSON(customError.Error())
 	}
 
-	storageGo := storage_go.NewClient(viper.GetString("SUPABASE_URL"), viper.GetString("SUPABASE_TOKEN"), nil)
-	_, err = storageGo.UploadFile("submissions_teacher", fmt.Sprintf("class_section/%d/%s.pdf", request.ClassSectionId, request.Title), parsedFile)
+	request.ClassSectionId = intSectionClassId
+
+	file, err := c.FormFile("file")
 	if err != nil {
 		customError := pkg.CustomError{
 			Code:    utils.INTERNAL_SERVER_ERROR,
@@ -320,10 +762,7 @@ func (handler *ClassHandlerImpl) AddSubmissionsTeacher(c *fiber.Ctx) error {
 		return c.Status(customError.Code).JSON(customError.Error())
 	}
 
-	linkFile := storageGo.GetPublicUrl("submissions_teacher", fmt.Sprintf("class_section/%d/%s.pdf", request.ClassSectionId, request.Title))
-	request.File = linkFile.SignedURL + fmt.Sprintf("?download=%s.pdf", request.Title)
-
-	customError = handler.classUsecase.AddSubmissionTeacher(c.Context(), &request)
+	customError := handler.classUsecase.AddSubmissionTeacher(c.Context(), principal.Subject(), &request, file)
 	if customError.Cause != nil {
 		return c.Status(customError.Code).JSON(customError.Error())
 	}
@@ -337,19 +776,7 @@ func (handler *ClassHandlerImpl) AddSubmissionsTeacher(c *fiber.Ctx) error {
 func (handler *ClassHandlerImpl) AddSubmissionsStudent(c *fiber.Ctx) error {
 	var request dto.StudentSubmissionRequest
 
-	Id, err := middleware.GetIdFromToken(c)
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"messsage": err.Error(),
-		})
-	}
-
-	parsedId, err := uuid.Parse(Id)
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"messsage": err.Error(),
-		})
-	}
+	principal := middleware.GetPrincipal(c)
 
 	sectionClassId := c.Params("section_id")
 	if sectionClassId == "" {
@@ -386,11 +813,10 @@ func (handler *ClassHandlerImpl) AddSubmissionsStudent(c *fiber.Ctx) error {
 	}
 
 	request = dto.StudentSubmissionRequest{
-		ID:             parsedId,
 		ClassSectionId: intSectionClassId,
 	}
 
-	customError := handler.classUsecase.AddSubmissionStudent(c.Context(), &request, file)
+	customError := handler.classUsecase.AddSubmissionStudent(c.Context(), principal.Subject(), &request, file)
 	if customError.Cause != nil {
 		return c.Status(customError.Code).JSON(customError.Error())
 	}
@@ -402,48 +828,139 @@ func (handler *ClassHandlerImpl) AddSubmissionsStudent(c *fiber.Ctx) error {
 }
 
 func (handler *ClassHandlerImpl) FetchSubmission(c *fiber.Ctx) error {
-	Id, err := middleware.GetIdFromToken(c)
-	if err != nil {
+	principal := middleware.GetPrincipal(c)
+
+	sectionClassId := c.Params("section_id")
+	if sectionClassId == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"messsage": err.Error(),
+			"message": "id cannot be blank!",
 		})
 	}
 
-	parsedId, err := uuid.Parse(Id)
+	intSectionClassId, err := strconv.Atoi(sectionClassId)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"messsage": err.Error(),
 		})
 	}
 
-	sectionClassId := c.Params("section_id")
-	if sectionClassId == "" {
+	submissions, customError := handler.classUsecase.FetchSubmissionBySection(c.Context(), principal.Subject(), intSectionClassId)
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "success getting submissions",
+		"data":    submissions,
+	})
+}
+
+func (handler *ClassHandlerImpl) FetchClassStaff(c *fiber.Ctx) error {
+	principal := middleware.GetPrincipal(c)
+
+	classId, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "id cannot be blank!",
+			"message": "input integer only for class id",
 		})
 	}
 
-	intSectionClassId, err := strconv.Atoi(sectionClassId)
+	staff, customError := handler.classUsecase.FetchClassStaff(c.Context(), principal.Subject(), classId)
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "success getting class staff",
+		"data":    staff,
+	})
+}
+
+func (handler *ClassHandlerImpl) InviteClassStaff(c *fiber.Ctx) error {
+	var request dto.InviteClassStaffRequest
+	principal := middleware.GetPrincipal(c)
+
+	classId, err := strconv.Atoi(c.Params("id"))
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"messsage": err.Error(),
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "input integer only for class id",
+		})
+	}
+
+	err = c.BodyParser(&request)
+	if err != nil {
+		customError := pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   err,
+			Service: utils.HANDLER_SERVICE,
+		}
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	customError := handler.classUsecase.InviteClassStaff(c.Context(), principal.Subject(), classId, &request)
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "staff invited",
+	})
+}
+
+func (handler *ClassHandlerImpl) AcceptClassStaffInvite(c *fiber.Ctx) error {
+	principal := middleware.GetPrincipal(c)
+
+	classId, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "input integer only for class id",
 		})
 	}
 
-	submissions, customError := handler.classUsecase.FetchSubmissionBySection(c.Context(), intSectionClassId, parsedId)
+	customError := handler.classUsecase.AcceptClassStaffInvite(c.Context(), principal.Subject(), classId)
 	if customError.Cause != nil {
 		return c.Status(customError.Code).JSON(customError.Error())
 	}
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
-		"message": "success getting submissions",
-		"data":    submissions,
+		"message": "invitation accepted",
+	})
+}
+
+func (handler *ClassHandlerImpl) RemoveClassStaff(c *fiber.Ctx) error {
+	principal := middleware.GetPrincipal(c)
+
+	classId, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "input integer only for class id",
+		})
+	}
+
+	teacherId, err := uuid.Parse(c.Params("teacher_id"))
+	if err != nil {
+		customError := pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   err,
+			Service: utils.HANDLER_SERVICE,
+		}
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	customError := handler.classUsecase.RemoveClassStaff(c.Context(), principal.Subject(), classId, teacherId)
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "staff removed",
 	})
 }
 
-func NewClassHandler(classUsecase usecase.ClassUsecase, studentUsecase usecase.StudentUsecase) *ClassHandlerImpl {
+func NewClassHandler(classUsecase usecase.ClassUsecase, studentUsecase usecase.StudentUsecase, authMiddleware *middleware.AuthMiddleware) *ClassHandlerImpl {
 	return &ClassHandlerImpl{
 		classUsecase:   classUsecase,
 		studentUsecase: studentUsecase,
+		authMiddleware: authMiddleware,
 	}
 }
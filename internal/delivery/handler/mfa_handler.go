@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/rifkhia/lms-remake/internal/delivery/middleware"
+	"github.com/rifkhia/lms-remake/internal/dto"
+	"github.com/rifkhia/lms-remake/internal/pkg"
+	"github.com/rifkhia/lms-remake/internal/usecase"
+	"github.com/rifkhia/lms-remake/internal/utils"
+)
+
+type MFAHandlerImpl struct {
+	mfaUsecase     usecase.MFAUsecase
+	authMiddleware *middleware.AuthMiddleware
+}
+
+func (handler MFAHandlerImpl) Route(app *fiber.App) {
+	app.Post("/v1/auth/2fa/verify", handler.VerifyLogin)
+	app.Get("/v1/auth/2fa", handler.authMiddleware.JWTGuardAll, handler.authMiddleware.SessionOnly, handler.FetchStatus)
+	app.Post("/v1/auth/2fa/enroll", handler.authMiddleware.JWTGuardAll, handler.authMiddleware.SessionOnly, handler.Enroll)
+	app.Post("/v1/auth/2fa/confirm", handler.authMiddleware.JWTGuardAll, handler.authMiddleware.SessionOnly, handler.Confirm)
+	app.Post("/v1/auth/2fa/disable", handler.authMiddleware.JWTGuardAll, handler.authMiddleware.SessionOnly, handler.Disable)
+	app.Post("/v1/auth/2fa/recovery-codes", handler.authMiddleware.JWTGuardAll, handler.authMiddleware.SessionOnly, handler.RegenerateRecoveryCodes)
+}
+
+func (handler *MFAHandlerImpl) VerifyLogin(c *fiber.Ctx) error {
+	var request dto.MFALoginRequest
+
+	err := c.BodyParser(&request)
+	if err != nil {
+		customError := pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   err,
+			Service: utils.HANDLER_SERVICE,
+		}
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	data, customError := handler.mfaUsecase.VerifyLogin(c.Context(), &request, clientInfo(c))
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "login success",
+		"data":    data,
+	})
+}
+
+func (handler *MFAHandlerImpl) FetchStatus(c *fiber.Ctx) error {
+	principal := middleware.GetPrincipal(c)
+
+	data, customError := handler.mfaUsecase.FetchStatus(c.Context(), principal.Subject())
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "success get two-factor status",
+		"data":    data,
+	})
+}
+
+func (handler *MFAHandlerImpl) Enroll(c *fiber.Ctx) error {
+	principal := middleware.GetPrincipal(c)
+
+	data, customError := handler.mfaUsecase.Enroll(c.Context(), principal.Subject())
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "add the secret to your authenticator app, then confirm with a code",
+		"data":    data,
+	})
+}
+
+func (handler *MFAHandlerImpl) Confirm(c *fiber.Ctx) error {
+	var request dto.MFACodeRequest
+	principal := middleware.GetPrincipal(c)
+
+	err := c.BodyParser(&request)
+	if err != nil {
+		customError := pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   err,
+			Service: utils.HANDLER_SERVICE,
+		}
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	data, customError := handler.mfaUsecase.Confirm(c.Context(), principal.Subject(), &request)
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "two-factor authentication enabled, store the recovery codes now as they won't be shown again",
+		"data":    data,
+	})
+}
+
+func (handler *MFAHandlerImpl) Disable(c *fiber.Ctx) error {
+	var request dto.MFACodeRequest
+	principal := middleware.GetPrincipal(c)
+
+	err := c.BodyParser(&request)
+	if err != nil {
+		customError := pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   err,
+			Service: utils.HANDLER_SERVICE,
+		}
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	customError := handler.mfaUsecase.Disable(c.Context(), principal.Subject(), &request)
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "two-factor authentication disabled",
+	})
+}
+
+func (handler *MFAHandlerImpl) RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	var request dto.MFACodeRequest
+	principal := middleware.GetPrincipal(c)
+
+	err := c.BodyParser(&request)
+	if err != nil {
+		customError := pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   err,
+			Service: utils.HANDLER_SERVICE,
+		}
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	data, customError := handler.mfaUsecase.RegenerateRecoveryCodes(c.Context(), principal.Subject(), &request)
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "new recovery codes created, the old ones no longer work",
+		"data":    data,
+	})
+}
+
+func NewMFAHandler(mfaUsecase usecase.MFAUsecase, authMiddleware *middleware.AuthMiddleware) *MFAHandlerImpl {
+	return &MFAHandlerImpl{
+		mfaUsecase:     mfaUsecase,
+		authMiddleware: authMiddleware,
+	}
+}
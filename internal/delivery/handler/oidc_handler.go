@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rifkhia/lms-remake/internal/pkg"
+	"github.com/rifkhia/lms-remake/internal/usecase"
+	"github.com/rifkhia/lms-remake/internal/utils"
+)
+
+type OIDCHandlerImpl struct {
+	oidcUsecase usecase.OIDCUsecase
+}
+
+// ssoRoles maps the :role path segment to a role. Admins always use a password.
+var ssoRoles = map[string]string{
+	"student": utils.STUDENT_ROLE,
+	"teacher": utils.TEACHER_ROLE,
+}
+
+func (handler OIDCHandlerImpl) Route(app *fiber.App) {
+	app.Get("/v1/auth/oidc/:role/login", handler.StartLogin)
+	app.Get("/v1/auth/oidc/callback", handler.Callback)
+}
+
+// StartLogin sends the browser to the identity provider.
+func (handler *OIDCHandlerImpl) StartLogin(c *fiber.Ctx) error {
+	role, ok := ssoRoles[c.Params("role")]
+	if !ok {
+		customError := pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("role must be student or teacher"),
+			Service: utils.HANDLER_SERVICE,
+		}
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	authorizationURL, customError := handler.oidcUsecase.StartLogin(c.Context(), role)
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Redirect(authorizationURL, fiber.StatusFound)
+}
+
+// Callback is the redirect URL registered at the identity provider.
+func (handler *OIDCHandlerImpl) Callback(c *fiber.Ctx) error {
+	if c.Query("error") != "" {
+		customError := pkg.CustomError{
+			Code:    utils.UNAUTHORIZED,
+			Cause:   fmt.Errorf("single sign-on failed: %s %s", c.Query("error"), c.Query("error_description")),
+			Service: utils.HANDLER_SERVICE,
+		}
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	data, customError := handler.oidcUsecase.FinishLogin(c.Context(), c.Query("state"), c.Query("code"), clientInfo(c))
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "login success",
+		"data":    data,
+	})
+}
+
+func NewOIDCHandler(oidcUsecase usecase.OIDCUsecase) *OIDCHandlerImpl {
+	return &OIDCHandlerImpl{
+		oidcUsecase: oidcUsecase,
+	}
+}
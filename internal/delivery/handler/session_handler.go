@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/rifkhia/lms-remake/internal/delivery/middleware"
+	"github.com/rifkhia/lms-remake/internal/dto"
+	"github.com/rifkhia/lms-remake/internal/pkg"
+	"github.com/rifkhia/lms-remake/internal/usecase"
+	"github.com/rifkhia/lms-remake/internal/utils"
+)
+
+type SessionHandlerImpl struct {
+	sessionUsecase usecase.SessionUsecase
+	authMiddleware *middleware.AuthMiddleware
+}
+
+func (handler SessionHandlerImpl) Route(app *fiber.App) {
+	app.Get("/v1/me/sessions", handler.authMiddleware.JWTGuardAll, handler.authMiddleware.SessionOnly, handler.FetchSessions)
+	app.Delete("/v1/me/sessions/:id", handler.authMiddleware.JWTGuardAll, handler.authMiddleware.SessionOnly, handler.RevokeSession)
+}
+
+// clientInfo is the device a login or refresh request came from.
+func clientInfo(c *fiber.Ctx) dto.ClientInfo {
+	return dto.ClientInfo{
+		IPAddress: c.IP(),
+		UserAgent: c.Get(fiber.HeaderUserAgent),
+	}
+}
+
+func (handler *SessionHandlerImpl) FetchSessions(c *fiber.Ctx) error {
+	principal := middleware.GetPrincipal(c)
+
+	sessions, customError := handler.sessionUsecase.FetchSessions(c.Context(), principal.Subject(), principal.SessionID)
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "success get sessions",
+		"data":    sessions,
+	})
+}
+
+// RevokeSession signs out one session, or every session but the current one
+// when the id is "others".
+func (handler *SessionHandlerImpl) RevokeSession(c *fiber.Ctx) error {
+	principal := middleware.GetPrincipal(c)
+
+	if c.Params("id") == "others" {
+		customError := handler.sessionUsecase.RevokeOtherSessions(c.Context(), principal.Subject(), principal.SessionID)
+		if customError.Cause != nil {
+			return c.Status(customError.Code).JSON(customError.Error())
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "other sessions signed out",
+		})
+	}
+
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		customError := pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   err,
+			Service: utils.HANDLER_SERVICE,
+		}
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	customError := handler.sessionUsecase.RevokeSession(c.Context(), principal.Subject(), id)
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "session signed out",
+	})
+}
+
+func NewSessionHandler(sessionUsecase usecase.SessionUsecase, authMiddleware *middleware.AuthMiddleware) *SessionHandlerImpl {
+	return &SessionHandlerImpl{
+		sessionUsecase: sessionUsecase,
+		authMiddleware: authMiddleware,
+	}
+}
@@ -1,8 +1,8 @@
 package handler
 
 import (
+	"fmt"
 	"github.com/gofiber/fiber/v2"
-	"github.com/google/uuid"
 	"github.com/rifkhia/lms-remake/internal/delivery/middleware"
 	"github.com/rifkhia/lms-remake/internal/dto"
 	"github.com/rifkhia/lms-remake/internal/pkg"
@@ -11,40 +11,27 @@ import (
 )
 
 func (handler StudentHandlerImpl) Route(app *fiber.App) {
-	app.Get("/v1/student/profile", middleware.JWTGuardStudent, handler.FetchStudentById)
-	app.Put("/v1/student/profile", middleware.JWTGuardStudent, handler.EditStudent)
+	app.Get("/v1/student/profile", handler.authMiddleware.JWTGuardStudent, handler.FetchStudentById)
+	app.Put("/v1/student/profile", handler.authMiddleware.JWTGuardStudent, handler.EditStudent)
 	app.Post("/v1/student/register", handler.RegisterStudent)
 	app.Post("/v1/student/login", handler.LoginStudent)
-	app.Delete("/v1/student", middleware.JWTGuardStudent, handler.DeleteStudent)
-	app.Get("/v1/student/schedules", middleware.JWTGuardStudent, handler.FetchStudentSchedule)
+	app.Post("/v1/student/verify", handler.VerifyStudent)
+	app.Post("/v1/student/verify/resend", handler.ResendVerificationStudent)
+	app.Delete("/v1/student", handler.authMiddleware.JWTGuardStudent, handler.authMiddleware.SessionOnly, handler.DeleteStudent)
+	app.Post("/v1/student/deletion/cancel", handler.authMiddleware.JWTGuardStudent, handler.authMiddleware.SessionOnly, handler.CancelDeleteStudent)
+	app.Get("/v1/student/export", handler.authMiddleware.JWTGuardStudent, handler.ExportStudent)
+	app.Get("/v1/student/schedules", handler.authMiddleware.JWTGuardStudent, handler.FetchStudentSchedule)
 }
 
 type StudentHandlerImpl struct {
 	studentUsecase usecase.StudentUsecase
+	authMiddleware *middleware.AuthMiddleware
 }
 
 func (handler *StudentHandlerImpl) FetchStudentById(c *fiber.Ctx) error {
-	id, err := middleware.GetIdFromToken(c)
-	if err != nil {
-		customError := pkg.CustomError{
-			Code:    utils.BAD_REQUEST,
-			Cause:   err,
-			Service: utils.HANDLER_SERVICE,
-		}
-		return c.Status(customError.Code).JSON(customError.Error())
-	}
-
-	parseId, err := uuid.Parse(id)
-	if err != nil {
-		customError := pkg.CustomError{
-			Code:    utils.BAD_REQUEST,
-			Cause:   err,
-			Service: utils.HANDLER_SERVICE,
-		}
-		return c.Status(customError.Code).JSON(customError.Error())
-	}
+	principal := middleware.GetPrincipal(c)
 
-	studentResult, customError := handler.studentUsecase.FetchStudentById(c.Context(), parseId)
+	studentResult, customError := handler.studentUsecase.FetchStudentById(c.Context(), principal.Subject(), principal.UserID)
 	if customError.Cause != nil {
 		return c.Status(customError.Code).JSON(customError.Error())
 	}
@@ -92,7 +79,7 @@ func (handler *StudentHandlerImpl) RegisterStudent(c *fiber.Ctx) error {
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(map[string]interface{}{
-		"message": "Success create student",
+		"message": "Success create student, verification code sent to email",
 		"data":    data,
 	})
 }
@@ -107,7 +94,7 @@ func (handler *StudentHandlerImpl) LoginStudent(c *fiber.Ctx) error {
 		})
 	}
 
-	data, customError := handler.studentUsecase.Login(c.Context(), &request)
+	data, customError := handler.studentUsecase.Login(c.Context(), &request, clientInfo(c))
 	if customError.Cause != nil {
 		return c.Status(customError.Code).JSON(customError.Error())
 	}
@@ -118,8 +105,9 @@ func (handler *StudentHandlerImpl) LoginStudent(c *fiber.Ctx) error {
 	})
 }
 
-func (handler *StudentHandlerImpl) DeleteStudent(c *fiber.Ctx) error {
-	id, err := middleware.GetIdFromToken(c)
+func (handler *StudentHandlerImpl) VerifyStudent(c *fiber.Ctx) error {
+	var request dto.VerifyEmailRequest
+	err := c.BodyParser(&request)
 	if err != nil {
 		customError := pkg.CustomError{
 			Code:    utils.BAD_REQUEST,
@@ -129,7 +117,20 @@ func (handler *StudentHandlerImpl) DeleteStudent(c *fiber.Ctx) error {
 		return c.Status(customError.Code).JSON(customError.Error())
 	}
 
-	parseId, err := uuid.Parse(id)
+	data, customError := handler.studentUsecase.Verify(c.Context(), &request, clientInfo(c))
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "email verified",
+		"data":    data,
+	})
+}
+
+func (handler *StudentHandlerImpl) ResendVerificationStudent(c *fiber.Ctx) error {
+	var request dto.ResendVerificationRequest
+	err := c.BodyParser(&request)
 	if err != nil {
 		customError := pkg.CustomError{
 			Code:    utils.BAD_REQUEST,
@@ -139,38 +140,71 @@ func (handler *StudentHandlerImpl) DeleteStudent(c *fiber.Ctx) error {
 		return c.Status(customError.Code).JSON(customError.Error())
 	}
 
-	customError := handler.studentUsecase.DeleteStudent(c.Context(), parseId)
+	customError := handler.studentUsecase.ResendVerification(c.Context(), &request)
 	if customError.Cause != nil {
 		return c.Status(customError.Code).JSON(customError.Error())
 	}
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
-		"message": "account deleted",
+		"message": "verification code sent",
 	})
 }
 
-func (handler *StudentHandlerImpl) EditStudent(c *fiber.Ctx) error {
-	var request *dto.StudentProfileRequest
-	id, err := middleware.GetIdFromToken(c)
-	if err != nil {
-		customError := pkg.CustomError{
-			Code:    utils.BAD_REQUEST,
-			Cause:   err,
-			Service: utils.HANDLER_SERVICE,
-		}
+func (handler *StudentHandlerImpl) DeleteStudent(c *fiber.Ctx) error {
+	principal := middleware.GetPrincipal(c)
+
+	scheduledAt, customError := handler.studentUsecase.RequestDeletion(c.Context(), principal.Subject(), principal.UserID)
+	if customError.Cause != nil {
 		return c.Status(customError.Code).JSON(customError.Error())
 	}
 
-	err = c.BodyParser(&request)
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message": "account deletion scheduled",
+		"data": fiber.Map{
+			"deletion_scheduled_at": scheduledAt,
+		},
+	})
+}
+
+func (handler *StudentHandlerImpl) CancelDeleteStudent(c *fiber.Ctx) error {
+	principal := middleware.GetPrincipal(c)
+
+	customError := handler.studentUsecase.CancelDeletion(c.Context(), principal.Subject(), principal.UserID)
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "account deletion cancelled",
+	})
+}
+
+func (handler *StudentHandlerImpl) ExportStudent(c *fiber.Ctx) error {
+	principal := middleware.GetPrincipal(c)
+
+	export, customError := handler.studentUsecase.ExportData(c.Context(), principal.Subject(), principal.UserID)
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	c.Attachment(fmt.Sprintf("lms-export-%s.json", principal.UserID))
+	return c.Status(fiber.StatusOK).JSON(export)
+}
+
+func (handler *StudentHandlerImpl) EditStudent(c *fiber.Ctx) error {
+	var request *dto.StudentProfileRequest
+	principal := middleware.GetPrincipal(c)
+
+	err := c.BodyParser(&request)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(map[string]interface{}{
 			"message": err.Error(),
 		})
 	}
 
-	request.ID, err = uuid.Parse(id)
+	request.ID = principal.UserID
 
-	customError := handler.studentUsecase.EditProfileStudent(c.Context(), request)
+	customError := handler.studentUsecase.EditProfileStudent(c.Context(), principal.Subject(), request)
 	if customError.Cause != nil {
 		return c.Status(customError.Code).JSON(customError.Error())
 	}
@@ -183,27 +217,9 @@ func (handler *StudentHandlerImpl) EditStudent(c *fiber.Ctx) error {
 }
 
 func (handler *StudentHandlerImpl) FetchStudentSchedule(c *fiber.Ctx) error {
-	id, err := middleware.GetIdFromToken(c)
-	if err != nil {
-		customError := pkg.CustomError{
-			Code:    utils.BAD_REQUEST,
-			Cause:   err,
-			Service: utils.HANDLER_SERVICE,
-		}
-		return c.Status(customError.Code).JSON(customError.Error())
-	}
-
-	parsedId, err := uuid.Parse(id)
-	if err != nil {
-		customError := pkg.CustomError{
-			Code:    utils.BAD_REQUEST,
-			Cause:   err,
-			Service: utils.HANDLER_SERVICE,
-		}
-		return c.Status(customError.Code).JSON(customError.Error())
-	}
+	principal := middleware.GetPrincipal(c)
 
-	studentSchedules, customError := handler.studentUsecase.FetchStudentSchedule(c.Context(), parsedId)
+	studentSchedules, customError := handler.studentUsecase.FetchStudentSchedule(c.Context(), principal.UserID, c.Query("term"))
 	if customError.Cause != nil {
 		return c.Status(customError.Code).JSON(customError.Error())
 	}
@@ -214,8 +230,9 @@ func (handler *StudentHandlerImpl) FetchStudentSchedule(c *fiber.Ctx) error {
 	})
 }
 
-func NewStudentHandler(studentUsecase usecase.StudentUsecase) *StudentHandlerImpl {
+func NewStudentHandler(studentUsecase usecase.StudentUsecase, authMiddleware *middleware.AuthMiddleware) *StudentHandlerImpl {
 	return &StudentHandlerImpl{
 		studentUsecase: studentUsecase,
+		authMiddleware: authMiddleware,
 	}
 }
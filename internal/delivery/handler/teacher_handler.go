@@ -15,6 +15,8 @@ type TeacherHandlerImpl struct {
 func (handler TeacherHandlerImpl) Route(app *fiber.App) {
 	app.Post("/v1/teacher/login", handler.LoginTeacher)
 	app.Post("/v1/teacher/register", handler.RegisterTeacher)
+	app.Post("/v1/teacher/verify", handler.VerifyTeacher)
+	app.Post("/v1/teacher/verify/resend", handler.ResendVerificationTeacher)
 }
 
 func (handler *TeacherHandlerImpl) LoginTeacher(c *fiber.Ctx) error {
@@ -40,7 +42,7 @@ func (handler *TeacherHandlerImpl) LoginTeacher(c *fiber.Ctx) error {
 		})
 	}
 
-	data, customError := handler.teacherUsecase.LoginTeacher(c.Context(), &request)
+	data, customError := handler.teacherUsecase.LoginTeacher(c.Context(), &request, clientInfo(c))
 	if customError.Cause != nil {
 		return c.Status(customError.Code).JSON(customError.Error())
 	}
@@ -69,11 +71,56 @@ func (handler *TeacherHandlerImpl) RegisterTeacher(c *fiber.Ctx) error {
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-		"message": "register success",
+		"message": "register success, verification code sent to email",
 		"data":    data,
 	})
 }
 
+func (handler *TeacherHandlerImpl) VerifyTeacher(c *fiber.Ctx) error {
+	var request dto.VerifyEmailRequest
+	err := c.BodyParser(&request)
+	if err != nil {
+		customError := pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   err,
+			Service: utils.HANDLER_SERVICE,
+		}
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	data, customError := handler.teacherUsecase.VerifyTeacher(c.Context(), &request, clientInfo(c))
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "email verified",
+		"data":    data,
+	})
+}
+
+func (handler *TeacherHandlerImpl) ResendVerificationTeacher(c *fiber.Ctx) error {
+	var request dto.ResendVerificationRequest
+	err := c.BodyParser(&request)
+	if err != nil {
+		customError := pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   err,
+			Service: utils.HANDLER_SERVICE,
+		}
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	customError := handler.teacherUsecase.ResendVerificationTeacher(c.Context(), &request)
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "verification code sent",
+	})
+}
+
 func NewTeacherHandler(teacherUsecase usecase.TeacherUsecase) *TeacherHandlerImpl {
 	return &TeacherHandlerImpl{
 		teacherUsecase: teacherUsecase,
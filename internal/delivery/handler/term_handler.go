@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/rifkhia/lms-remake/internal/delivery/middleware"
+	"github.com/rifkhia/lms-remake/internal/dto"
+	"github.com/rifkhia/lms-remake/internal/pkg"
+	"github.com/rifkhia/lms-remake/internal/usecase"
+	"github.com/rifkhia/lms-remake/internal/utils"
+	"strconv"
+)
+
+type TermHandlerImpl struct {
+	termUsecase    usecase.TermUsecase
+	authMiddleware *middleware.AuthMiddleware
+}
+
+func (handler TermHandlerImpl) Route(app *fiber.App) {
+	app.Get("/v1/terms", handler.authMiddleware.JWTGuardAll, handler.FetchTerms)
+	app.Get("/v1/terms/current", handler.authMiddleware.JWTGuardAll, handler.FetchCurrentTerm)
+	app.Post("/v1/terms", handler.authMiddleware.JWTGuardAll, handler.CreateTerm)
+	app.Put("/v1/terms/:id", handler.authMiddleware.JWTGuardAll, handler.UpdateTerm)
+	app.Delete("/v1/terms/:id", handler.authMiddleware.JWTGuardAll, handler.DeleteTerm)
+}
+
+func (handler *TermHandlerImpl) FetchTerms(c *fiber.Ctx) error {
+	principal := middleware.GetPrincipal(c)
+
+	terms, customError := handler.termUsecase.FetchTerms(c.Context(), principal.Subject())
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "success get terms",
+		"data":    terms,
+	})
+}
+
+func (handler *TermHandlerImpl) FetchCurrentTerm(c *fiber.Ctx) error {
+	principal := middleware.GetPrincipal(c)
+
+	term, customError := handler.termUsecase.FetchCurrentTerm(c.Context(), principal.Subject())
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "success get current term",
+		"data":    term,
+	})
+}
+
+func (handler *TermHandlerImpl) CreateTerm(c *fiber.Ctx) error {
+	var request dto.TermRequest
+	principal := middleware.GetPrincipal(c)
+
+	err := c.BodyParser(&request)
+	if err != nil {
+		customError := pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   err,
+			Service: utils.HANDLER_SERVICE,
+		}
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	term, customError := handler.termUsecase.CreateTerm(c.Context(), principal.Subject(), &request)
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "term created",
+		"data":    term,
+	})
+}
+
+func (handler *TermHandlerImpl) UpdateTerm(c *fiber.Ctx) error {
+	var request dto.TermRequest
+	principal := middleware.GetPrincipal(c)
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "input integer only for term id",
+		})
+	}
+
+	err = c.BodyParser(&request)
+	if err != nil {
+		customError := pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   err,
+			Service: utils.HANDLER_SERVICE,
+		}
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	term, customError := handler.termUsecase.UpdateTerm(c.Context(), principal.Subject(), id, &request)
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "term updated",
+		"data":    term,
+	})
+}
+
+func (handler *TermHandlerImpl) DeleteTerm(c *fiber.Ctx) error {
+	principal := middleware.GetPrincipal(c)
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "input integer only for term id",
+		})
+	}
+
+	customError := handler.termUsecase.DeleteTerm(c.Context(), principal.Subject(), id)
+	if customError.Cause != nil {
+		return c.Status(customError.Code).JSON(customError.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "term deleted",
+	})
+}
+
+func NewTermHandler(termUsecase usecase.TermUsecase, authMiddleware *middleware.AuthMiddleware) *TermHandlerImpl {
+	return &TermHandlerImpl{
+		termUsecase:    termUsecase,
+		authMiddleware: authMiddleware,
+	}
+}
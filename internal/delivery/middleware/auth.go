@@ -1,48 +1,83 @@
 package middleware
 
 import (
-	"fmt"
-	_ "github.com/rifkhia/lms-remake/internal/models"
+	"github.com/google/uuid"
+	"github.com/rifkhia/lms-remake/internal/authz"
+	"github.com/rifkhia/lms-remake/internal/pkg"
+	"github.com/rifkhia/lms-remake/internal/usecase"
 	"github.com/rifkhia/lms-remake/internal/utils"
-	"github.com/spf13/viper"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt"
 )
 
-func JWTGuard(c *fiber.Ctx, role []string) error {
-	var tokenString string
+const principalKey = "principal"
+
+// Principal is the verified identity behind the current request, set by JWTGuard.
+type Principal struct {
+	UserID    uuid.UUID
+	Role      string
+	TokenID   string
+	ExpiresAt time.Time
+	// SessionID is the login session of a session JWT, uuid.Nil otherwise.
+	SessionID uuid.UUID
+	// Scopes is only set when the request used an API token.
+	Scopes []string
+}
+
+type AuthMiddleware struct {
+	tokenService usecase.TokenService
+}
+
+func GetTokenString(c *fiber.Ctx) string {
 	authorization := c.Get("Authorization")
 
 	if strings.HasPrefix(authorization, "Bearer ") {
-		tokenString = strings.TrimPrefix(authorization, "Bearer ")
-	} else if c.Cookies("token") != "" {
-		tokenString = c.Cookies("token")
+		return strings.TrimPrefix(authorization, "Bearer ")
 	}
 
-	if tokenString == "" {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"status": "fail", "message": "User not logged in"})
+	return c.Cookies("token")
+}
+
+// Subject is the principal as seen by the authz policy.
+func (p *Principal) Subject() authz.Subject {
+	return authz.Subject{
+		UserID: p.UserID,
+		Role:   p.Role,
+		Scopes: p.Scopes,
 	}
+}
 
-	tokenByte, err := jwt.Parse(tokenString, func(jwtToken *jwt.Token) (interface{}, error) {
-		if _, ok := jwtToken.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %s", jwtToken.Header["alg"])
-		}
+// IsAPIToken tells whether the request used an API token instead of a session.
+func (p *Principal) IsAPIToken() bool {
+	return p.Scopes != nil
+}
+
+// GetPrincipal returns the principal stored by JWTGuard, or nil on unguarded routes.
+func GetPrincipal(c *fiber.Ctx) *Principal {
+	principal, _ := c.Locals(principalKey).(*Principal)
+	return principal
+}
+
+func (m *AuthMiddleware) JWTGuard(c *fiber.Ctx, role []string) error {
+	tokenString := GetTokenString(c)
 
-		return []byte(viper.GetString("SECRET_JWT")), nil
-	})
-	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"status": "fail", "message": fmt.Sprintf("invalidate token: %v", err)})
+	if tokenString == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"status": "fail", "message": "User not logged in"})
 	}
 
-	claims, ok := tokenByte.Claims.(jwt.MapClaims)
-	if !ok || !tokenByte.Valid {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"status": "fail", "message": "invalid token claim"})
+	principal, customError := m.verify(c, tokenString)
+	if customError.Cause != nil {
+		if customError.Code == utils.INTERNAL_SERVER_ERROR {
+			return c.Status(customError.Code).JSON(customError.Error())
+		}
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"status": "fail", "message": "invalid token"})
 	}
 
 	for _, v := range role {
-		if v == claims["Role"] {
+		if v == principal.Role {
+			c.Locals(principalKey, principal)
 			return c.Next()
 		}
 	}
@@ -50,80 +85,76 @@ func JWTGuard(c *fiber.Ctx, role []string) error {
 	return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"status": "fail", "message": "Unauthorized"})
 }
 
-func GetIdFromToken(c *fiber.Ctx) (string, error) {
-	var tokenString string
-	authorization := c.Get("Authorization")
-
-	if strings.HasPrefix(authorization, "Bearer ") {
-		tokenString = strings.TrimPrefix(authorization, "Bearer ")
-	} else if c.Cookies("token") != "" {
-		tokenString = c.Cookies("token")
-	}
-
-	if tokenString == "" {
-		return "", c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"status": "fail", "message": "User not logged in"})
-	}
-
-	tokenByte, err := jwt.Parse(tokenString, func(jwtToken *jwt.Token) (interface{}, error) {
-		if _, ok := jwtToken.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %s", jwtToken.Header["alg"])
+// verify accepts both session JWTs and API tokens, told apart by the prefix.
+func (m *AuthMiddleware) verify(c *fiber.Ctx, tokenString string) (*Principal, pkg.CustomError) {
+	if strings.HasPrefix(tokenString, utils.API_TOKEN_PREFIX) {
+		token, customError := m.tokenService.VerifyAPIToken(c.Context(), tokenString)
+		if customError.Cause != nil {
+			return nil, customError
 		}
 
-		return []byte(viper.GetString("SECRET_JWT")), nil
-	})
-	if err != nil {
-		return "", c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"status": "fail", "message": fmt.Sprintf("invalidate token: %v", err)})
+		return &Principal{
+			UserID:  token.UserID,
+			Role:    token.Role,
+			TokenID: token.ID.String(),
+			Scopes:  append([]string{}, token.Scopes...),
+		}, pkg.CustomError{}
 	}
 
-	claims, ok := tokenByte.Claims.(jwt.MapClaims)
-	if !ok || !tokenByte.Valid {
-		return "", c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"status": "fail", "message": "invalid token claim"})
+	claims, customError := m.tokenService.VerifyAccessToken(c.Context(), tokenString)
+	if customError.Cause != nil {
+		return nil, customError
 	}
 
-	return fmt.Sprintf("%s", claims["ID"]), nil
+	return &Principal{
+		UserID:    claims.UserID,
+		Role:      claims.Role,
+		TokenID:   claims.RegisteredClaims.ID,
+		ExpiresAt: claims.ExpiresAt.Time,
+		SessionID: claims.SessionID,
+	}, pkg.CustomError{}
 }
 
-func GetRoleFromToken(c *fiber.Ctx) (string, error) {
-	var tokenString string
-	authorization := c.Get("Authorization")
-
-	if strings.HasPrefix(authorization, "Bearer ") {
-		tokenString = strings.TrimPrefix(authorization, "Bearer ")
-	} else if c.Cookies("token") != "" {
-		tokenString = c.Cookies("token")
+// SessionOnly goes after a guard on routes that manage credentials, which an
+// API token must never reach.
+func (m *AuthMiddleware) SessionOnly(c *fiber.Ctx) error {
+	if principal := GetPrincipal(c); principal != nil && principal.IsAPIToken() {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"status": "fail", "message": "not allowed with an api token"})
 	}
 
-	if tokenString == "" {
-		return "", c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"status": "fail", "message": "User not logged in"})
-	}
+	return c.Next()
+}
 
-	tokenByte, err := jwt.Parse(tokenString, func(jwtToken *jwt.Token) (interface{}, error) {
-		if _, ok := jwtToken.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %s", jwtToken.Header["alg"])
+// RequireScope goes after a guard on routes whose usecase doesn't go through
+// the authz policy.
+func (m *AuthMiddleware) RequireScope(scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if principal := GetPrincipal(c); principal != nil && !principal.Subject().HasScope(scope) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"status": "fail", "message": "api token is missing the " + scope + " scope"})
 		}
 
-		return []byte(viper.GetString("SECRET_JWT")), nil
-	})
-	if err != nil {
-		return "", c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"status": "fail", "message": fmt.Sprintf("invalidate token: %v", err)})
+		return c.Next()
 	}
+}
 
-	claims, ok := tokenByte.Claims.(jwt.MapClaims)
-	if !ok || !tokenByte.Valid {
-		return "", c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"status": "fail", "message": "invalid token claim"})
-	}
+func (m *AuthMiddleware) JWTGuardStudent(c *fiber.Ctx) error {
+	return m.JWTGuard(c, []string{utils.STUDENT_ROLE})
+}
 
-	return fmt.Sprintf("%s", claims["Role"]), nil
+func (m *AuthMiddleware) JWTGuardTeacher(c *fiber.Ctx) error {
+	return m.JWTGuard(c, []string{utils.TEACHER_ROLE})
 }
 
-func JWTGuardStudent(c *fiber.Ctx) error {
-	return JWTGuard(c, []string{utils.STUDENT_ROLE})
+func (m *AuthMiddleware) JWTGuardAdmin(c *fiber.Ctx) error {
+	return m.JWTGuard(c, []string{utils.ADMIN_ROLE})
 }
 
-func JWTGuardTeacher(c *fiber.Ctx) error {
-	return JWTGuard(c, []string{utils.TEACHER_ROLE})
+func (m *AuthMiddleware) JWTGuardAll(c *fiber.Ctx) error {
+	return m.JWTGuard(c, []string{utils.STUDENT_ROLE, utils.TEACHER_ROLE, utils.ADMIN_ROLE})
 }
 
-func JWTGuardAll(c *fiber.Ctx) error {
-	return JWTGuard(c, []string{utils.STUDENT_ROLE, utils.TEACHER_ROLE})
+func NewAuthMiddleware(tokenService usecase.TokenService) *AuthMiddleware {
+	return &AuthMiddleware{
+		tokenService: tokenService,
+	}
 }
@@ -0,0 +1,18 @@
+package dto
+
+import "github.com/google/uuid"
+
+type AdminLoginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// AdminResetPasswordRequest sets a user's password. When Password is blank a
+// random temporary password is generated and returned instead.
+type AdminResetPasswordRequest struct {
+	Password string `json:"password"`
+}
+
+type ReassignClassTeacherRequest struct {
+	TeacherID uuid.UUID `json:"teacher_id"`
+}
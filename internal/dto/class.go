@@ -1,35 +1,58 @@
 package dto
 
 import (
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/rifkhia/lms-remake/internal/models"
 	"github.com/rifkhia/lms-remake/internal/pkg"
 	"github.com/rifkhia/lms-remake/internal/utils"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// ClassCreate offers the class in the current term when term_id is left out.
+// A class that overlaps the teacher's schedule is refused unless
+// allow_conflicts is set.
 type ClassCreate struct {
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	Day         string `json:"day"`
-	StartTime   string `json:"start_time"`
-	EndTime     string `json:"end_time"`
+	Name           string                `json:"name"`
+	Description    string                `json:"description"`
+	TermId         *int                  `json:"term_id"`
+	Meetings       []ClassMeetingRequest `json:"meetings"`
+	AllowConflicts bool                  `json:"allow_conflicts"`
 }
 
 type ClassByNameResponse struct {
-	ID          int    `json:"id"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	Day         string `json:"day"`
-	StartTime   string `json:"start_time"`
-	EndTime     string `json:"end_time"`
+	ID          int                    `json:"id"`
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	TermId      *int                   `json:"term_id"`
+	Meetings    []*models.ClassMeeting `json:"meetings"`
 }
 
-func (c *ClassCreate) NewClass(teacherId uuid.UUID) (*models.Class, pkg.CustomError) {
-	classKey := utils.GenerateClassKey(8)
+// ClassMeetingRequest is one weekly meeting, like {"day": "monday",
+// "start_time": "08:00", "end_time": "09:40", "room": "B201"}. Without a
+// timezone the server default is used.
+type ClassMeetingRequest struct {
+	Day       string `json:"day"`
+	StartTime string `json:"start_time"`
+	EndTime   string `json:"end_time"`
+	Room      string `json:"room"`
+	Timezone  string `json:"timezone"`
+}
 
-	parsedStartTime, err := time.Parse("15:04", c.StartTime)
+func (r *ClassMeetingRequest) NewMeeting(defaultTimezone string) (*models.ClassMeeting, pkg.CustomError) {
+	weekday, err := strconv.Atoi(utils.ConvertDaysToInt(strings.ToLower(r.Day)))
+	if err != nil {
+		return nil, pkg.CustomError{
+			Cause:   errors.New("day must be a day of the week, like monday"),
+			Code:    utils.BAD_REQUEST,
+			Service: utils.MODEL_SERVICE,
+		}
+	}
+
+	startTime, err := time.Parse("15:04", r.StartTime)
 	if err != nil {
 		return nil, pkg.CustomError{
 			Cause:   err,
@@ -38,7 +61,7 @@ func (c *ClassCreate) NewClass(teacherId uuid.UUID) (*models.Class, pkg.CustomEr
 		}
 	}
 
-	parsedEndTime, err := time.Parse("15:04", c.EndTime)
+	endTime, err := time.Parse("15:04", r.EndTime)
 	if err != nil {
 		return nil, pkg.CustomError{
 			Cause:   err,
@@ -46,13 +69,183 @@ func (c *ClassCreate) NewClass(teacherId uuid.UUID) (*models.Class, pkg.CustomEr
 			Service: utils.MODEL_SERVICE,
 		}
 	}
+
+	if !endTime.After(startTime) {
+		return nil, pkg.CustomError{
+			Cause:   errors.New("a meeting must end after it starts"),
+			Code:    utils.BAD_REQUEST,
+			Service: utils.MODEL_SERVICE,
+		}
+	}
+
+	room := strings.TrimSpace(r.Room)
+	if len(room) > 50 {
+		return nil, pkg.CustomError{
+			Cause:   errors.New("room can't be longer than 50 characters"),
+			Code:    utils.BAD_REQUEST,
+			Service: utils.MODEL_SERVICE,
+		}
+	}
+
+	timezone := r.Timezone
+	if timezone == "" {
+		timezone = defaultTimezone
+	}
+	if _, err = time.LoadLocation(timezone); err != nil || len(timezone) > 64 {
+		return nil, pkg.CustomError{
+			Cause:   errors.New("timezone must be an IANA name, like Asia/Jakarta"),
+			Code:    utils.BAD_REQUEST,
+			Service: utils.MODEL_SERVICE,
+		}
+	}
+
+	return &models.ClassMeeting{
+		Weekday:   weekday,
+		Day:       utils.ConvertIntToDay(strconv.Itoa(weekday)),
+		StartTime: startTime.Format("15:04"),
+		EndTime:   endTime.Format("15:04"),
+		Room:      room,
+		Timezone:  timezone,
+	}, pkg.CustomError{}
+}
+
+// newClassMeetings validates the weekly meetings of a class. A class meets at
+// least once a week, and its meetings may not overlap.
+func newClassMeetings(requests []ClassMeetingRequest, defaultTimezone string) ([]*models.ClassMeeting, pkg.CustomError) {
+	if len(requests) == 0 {
+		return nil, pkg.CustomError{
+			Cause:   errors.New("a class needs at least one meeting"),
+			Code:    utils.BAD_REQUEST,
+			Service: utils.MODEL_SERVICE,
+		}
+	}
+
+	var meetings []*models.ClassMeeting
+	for i := range requests {
+		meeting, customError := requests[i].NewMeeting(defaultTimezone)
+		if customError.Cause != nil {
+			return nil, customError
+		}
+
+		for _, other := range meetings {
+			// "15:04" strings compare in time order.
+			if other.Weekday == meeting.Weekday && other.Timezone == meeting.Timezone && other.StartTime < meeting.EndTime && meeting.StartTime < other.EndTime {
+				return nil, pkg.CustomError{
+					Cause:   fmt.Errorf("meetings on %s overlap", utils.ConvertIntToDay(strconv.Itoa(meeting.Weekday))),
+					Code:    utils.BAD_REQUEST,
+					Service: utils.MODEL_SERVICE,
+				}
+			}
+		}
+
+		meetings = append(meetings, meeting)
+	}
+
+	return meetings, pkg.CustomError{}
+}
+
+func (c *ClassCreate) NewClass(teacherId uuid.UUID, defaultTimezone string) (*models.Class, pkg.CustomError) {
+	classKey, err := utils.GenerateClassKey(utils.CLASS_KEY_LENGTH)
+	if err != nil {
+		return nil, pkg.CustomError{
+			Cause:   err,
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Service: utils.MODEL_SERVICE,
+		}
+	}
+
+	meetings, customError := newClassMeetings(c.Meetings, defaultTimezone)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
 	return &models.Class{
 		Name:        c.Name,
 		Description: c.Description,
 		Key:         classKey,
 		TeacherId:   teacherId,
-		Day:         utils.ConvertDaysToInt(c.Day),
-		StartTime:   fmt.Sprintf("%s", parsedStartTime),
-		EndTime:     fmt.Sprintf("%s", parsedEndTime),
+		TermId:      c.TermId,
+		Meetings:    meetings,
 	}, pkg.CustomError{}
 }
+
+type InviteClassStaffRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+// UpdateEnrollmentRequest changes only the fields that are sent. A capacity of
+// 0 removes the limit.
+type UpdateEnrollmentRequest struct {
+	Mode     *string `json:"enrollment_mode"`
+	Capacity *int    `json:"capacity"`
+}
+
+type EnrollStudentRequest struct {
+	Email string `json:"email"`
+}
+
+// JoinClassRequest carries either the class key or an invite code.
+type JoinClassRequest struct {
+	Key        string `json:"key"`
+	InviteCode string `json:"invite_code"`
+}
+
+// RotateClassKeyRequest replaces the class key. Without expires_in_hours the
+// new key doesn't expire.
+type RotateClassKeyRequest struct {
+	ExpiresInHours int `json:"expires_in_hours"`
+}
+
+// CreateClassInviteRequest leaves an invite unlimited in uses or time when
+// max_uses or expires_in_hours is 0.
+type CreateClassInviteRequest struct {
+	MaxUses        int `json:"max_uses"`
+	ExpiresInHours int `json:"expires_in_hours"`
+}
+
+// UpdateClassRequest changes only the fields that are sent. Meetings, when
+// sent, replace all of the class's meetings.
+type UpdateClassRequest struct {
+	Name        *string                `json:"name"`
+	Description *string                `json:"description"`
+	TermId      *int                   `json:"term_id"`
+	Meetings    *[]ClassMeetingRequest `json:"meetings"`
+}
+
+// CloneClassRequest offers a copy of a class in another term. The name
+// defaults to the old one.
+type CloneClassRequest struct {
+	TermId int    `json:"term_id"`
+	Name   string `json:"name"`
+}
+
+// Normalize validates the request and returns the new meetings, nil when
+// they are left as they are.
+func (r *UpdateClassRequest) Normalize(defaultTimezone string) ([]*models.ClassMeeting, pkg.CustomError) {
+	if r.Name != nil {
+		name := strings.TrimSpace(*r.Name)
+		if name == "" || len(name) > 50 {
+			return nil, pkg.CustomError{
+				Cause:   errors.New("name must be between 1 and 50 characters"),
+				Code:    utils.BAD_REQUEST,
+				Service: utils.MODEL_SERVICE,
+			}
+		}
+		r.Name = &name
+	}
+
+	if r.Description != nil && len(*r.Description) > 255 {
+		return nil, pkg.CustomError{
+			Cause:   errors.New("description can't be longer than 255 characters"),
+			Code:    utils.BAD_REQUEST,
+			Service: utils.MODEL_SERVICE,
+		}
+	}
+
+	if r.Meetings == nil {
+		return nil, pkg.CustomError{}
+	}
+
+	return newClassMeetings(*r.Meetings, defaultTimezone)
+}
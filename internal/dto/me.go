@@ -0,0 +1,133 @@
+package dto
+
+import (
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/rifkhia/lms-remake/internal/pkg"
+	"github.com/rifkhia/lms-remake/internal/utils"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// MeResponse is the signed in user as any role sees itself. It is built field
+// by field from the models, so a password hash can never end up in it.
+type MeResponse struct {
+	ID      uuid.UUID   `json:"id"`
+	Role    string      `json:"role"`
+	Name    string      `json:"name"`
+	Email   string      `json:"email"`
+	NIM     int         `json:"NIM,omitempty"`
+	NPM     int         `json:"NPM,omitempty"`
+	Profile interface{} `json:"profile"`
+	// PendingEmail is set after a change of email until the code sent to the
+	// new address is confirmed.
+	PendingEmail string `json:"pending_email,omitempty"`
+	// DeletionScheduledAt is set while a student's closure request can still
+	// be cancelled.
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at,omitempty"`
+}
+
+type StudentProfileResponse struct {
+	DateOfBirth time.Time `json:"date_of_birth"`
+	Gender      string    `json:"gender"`
+	Address     string    `json:"address"`
+	Phone       string    `json:"phone"`
+}
+
+// UpdateMeRequest only changes the fields that are sent. The profile fields
+// belong to one role each.
+type UpdateMeRequest struct {
+	Name  *string `json:"name"`
+	Email *string `json:"email"`
+
+	DateOfBirth *time.Time `json:"date_of_birth"`
+	Gender      *string    `json:"gender"`
+	Address     *string    `json:"address"`
+	Phone       *string    `json:"phone"`
+
+	Title       *string `json:"title"`
+	Department  *string `json:"department"`
+	OfficeHours *string `json:"office_hours"`
+	Bio         *string `json:"bio"`
+	AvatarURL   *string `json:"avatar_url"`
+}
+
+type ConfirmEmailChangeRequest struct {
+	Code string `json:"code"`
+}
+
+func (r *UpdateMeRequest) HasStudentProfile() bool {
+	return r.DateOfBirth != nil || r.Gender != nil || r.Address != nil || r.Phone != nil
+}
+
+func (r *UpdateMeRequest) HasTeacherProfile() bool {
+	return r.Title != nil || r.Department != nil || r.OfficeHours != nil || r.Bio != nil || r.AvatarURL != nil
+}
+
+func (r *UpdateMeRequest) Validate(role string) pkg.CustomError {
+	if r.HasStudentProfile() && role != utils.STUDENT_ROLE {
+		return badMeRequest(errors.New("date_of_birth, gender, address and phone are only for students"))
+	}
+
+	if r.HasTeacherProfile() && role != utils.TEACHER_ROLE {
+		return badMeRequest(errors.New("title, department, office_hours, bio and avatar_url are only for teachers"))
+	}
+
+	if r.Name != nil {
+		*r.Name = strings.TrimSpace(*r.Name)
+		if *r.Name == "" {
+			return badMeRequest(errors.New("name cant be blank"))
+		}
+	}
+
+	if r.Email != nil {
+		*r.Email = strings.TrimSpace(*r.Email)
+		_, err := mail.ParseAddress(*r.Email)
+		if err != nil {
+			return badMeRequest(errors.New("invalidate email"))
+		}
+	}
+
+	if r.Gender != nil && *r.Gender != "M" && *r.Gender != "F" {
+		return badMeRequest(errors.New("gender must be M or F"))
+	}
+
+	limits := []struct {
+		name  string
+		value *string
+		max   int
+	}{
+		{"name", r.Name, 50},
+		{"phone", r.Phone, 15},
+		{"title", r.Title, 50},
+		{"department", r.Department, 100},
+		{"office_hours", r.OfficeHours, 255},
+		{"bio", r.Bio, 2000},
+		{"avatar_url", r.AvatarURL, 512},
+	}
+	for _, limit := range limits {
+		if limit.value != nil && len(*limit.value) > limit.max {
+			return badMeRequest(fmt.Errorf("%s can be at most %d characters", limit.name, limit.max))
+		}
+	}
+
+	if r.AvatarURL != nil && *r.AvatarURL != "" {
+		avatar, err := url.Parse(*r.AvatarURL)
+		if err != nil || (avatar.Scheme != "https" && avatar.Scheme != "http") || avatar.Host == "" {
+			return badMeRequest(errors.New("avatar_url must be an http or https url"))
+		}
+	}
+
+	return pkg.CustomError{}
+}
+
+func badMeRequest(err error) pkg.CustomError {
+	return pkg.CustomError{
+		Code:    utils.BAD_REQUEST,
+		Service: "models",
+		Cause:   err,
+	}
+}
@@ -37,6 +37,16 @@ type StudentSubmissionRequest struct {
 	File           string    `json:"file"`
 }
 
+// StudentExport is everything the LMS holds about a student, handed out on
+// request before their account is closed.
+type StudentExport struct {
+	ExportedAt  time.Time                         `json:"exported_at"`
+	Account     *models.Student                   `json:"account"`
+	Profile     *StudentProfileRequest            `json:"profile"`
+	Enrollments []*models.StudentEnrollment       `json:"enrollments"`
+	Submissions []*models.StudentSubmissionRecord `json:"submissions"`
+}
+
 func (s *StudentRegisterRequest) NewStudent() (*models.Student, pkg.CustomError) {
 	studentId := uuid.New()
 
@@ -69,11 +79,12 @@ func (s *StudentRegisterRequest) NewStudent() (*models.Student, pkg.CustomError)
 	}
 
 	//validate password
-	if len(s.Password) < 8 {
+	err = utils.ValidatePasswordPolicy(s.Password)
+	if err != nil {
 		return nil, pkg.CustomError{
 			Code:    utils.BAD_REQUEST,
 			Service: "models",
-			Cause:   errors.New("password length must be more than 8"),
+			Cause:   err,
 		}
 	}
 
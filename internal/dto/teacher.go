@@ -59,11 +59,12 @@ func (s *TeacherRegisterRequest) NewTeacher() (*models.Teacher, error2.CustomErr
 	}
 
 	//validate password
-	if len(s.Password) < 8 {
+	err = utils.ValidatePasswordPolicy(s.Password)
+	if err != nil {
 		custErr = error2.CustomError{
 			Code:    utils.BAD_REQUEST,
 			Service: "models",
-			Cause:   errors.New("password length must be more than 8"),
+			Cause:   err,
 		}
 		return nil, custErr
 	}
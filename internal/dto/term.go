@@ -0,0 +1,73 @@
+package dto
+
+import (
+	"errors"
+	"github.com/rifkhia/lms-remake/internal/models"
+	"github.com/rifkhia/lms-remake/internal/pkg"
+	"github.com/rifkhia/lms-remake/internal/utils"
+	"strings"
+	"time"
+)
+
+// TermRequest creates or replaces a term. Dates are like 2024-02-01, the
+// enrollment window bounds are RFC 3339 timestamps and may be left out.
+type TermRequest struct {
+	Name               string     `json:"name"`
+	StartsOn           string     `json:"starts_on"`
+	EndsOn             string     `json:"ends_on"`
+	EnrollmentOpensAt  *time.Time `json:"enrollment_opens_at"`
+	EnrollmentClosesAt *time.Time `json:"enrollment_closes_at"`
+}
+
+func (r *TermRequest) NewTerm() (*models.Term, pkg.CustomError) {
+	name := strings.TrimSpace(r.Name)
+	if name == "" || len(name) > 50 {
+		return nil, pkg.CustomError{
+			Cause:   errors.New("name must be between 1 and 50 characters"),
+			Code:    utils.BAD_REQUEST,
+			Service: utils.MODEL_SERVICE,
+		}
+	}
+
+	startsOn, err := time.Parse(time.DateOnly, r.StartsOn)
+	if err != nil {
+		return nil, pkg.CustomError{
+			Cause:   err,
+			Code:    utils.BAD_REQUEST,
+			Service: utils.MODEL_SERVICE,
+		}
+	}
+
+	endsOn, err := time.Parse(time.DateOnly, r.EndsOn)
+	if err != nil {
+		return nil, pkg.CustomError{
+			Cause:   err,
+			Code:    utils.BAD_REQUEST,
+			Service: utils.MODEL_SERVICE,
+		}
+	}
+
+	if endsOn.Before(startsOn) {
+		return nil, pkg.CustomError{
+			Cause:   errors.New("a term can't end before it starts"),
+			Code:    utils.BAD_REQUEST,
+			Service: utils.MODEL_SERVICE,
+		}
+	}
+
+	if r.EnrollmentOpensAt != nil && r.EnrollmentClosesAt != nil && !r.EnrollmentClosesAt.After(*r.EnrollmentOpensAt) {
+		return nil, pkg.CustomError{
+			Cause:   errors.New("enrollment must close after it opens"),
+			Code:    utils.BAD_REQUEST,
+			Service: utils.MODEL_SERVICE,
+		}
+	}
+
+	return &models.Term{
+		Name:               name,
+		StartsOn:           startsOn,
+		EndsOn:             endsOn,
+		EnrollmentOpensAt:  r.EnrollmentOpensAt,
+		EnrollmentClosesAt: r.EnrollmentClosesAt,
+	}, pkg.CustomError{}
+}
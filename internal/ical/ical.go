@@ -0,0 +1,240 @@
+// Package ical writes iCalendar (RFC 5545) files for calendar apps.
+package ical
+
+import (
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	dateTimeFormat = "20060102T150405"
+	// maxLineOctets is how long a content line may be before it is folded.
+	maxLineOctets = 75
+)
+
+// Calendar is a VCALENDAR with the components a schedule needs.
+type Calendar struct {
+	Name string
+	// Stamp is when the calendar was made, the DTSTAMP of every component.
+	Stamp  time.Time
+	Events []Event
+	Todos  []Todo
+}
+
+// Event is a VEVENT. Times in UTC are written as such, other times as local
+// times in their location, which then gets a VTIMEZONE.
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	Location    string
+	Start       time.Time
+	// End is left out when it equals Start.
+	End time.Time
+	// Weekly repeats the event every week, until Until when it is set.
+	Weekly bool
+	Until  *time.Time
+}
+
+// Todo is a VTODO, due at Due.
+type Todo struct {
+	UID         string
+	Summary     string
+	Description string
+	Due         time.Time
+}
+
+// Encode returns the calendar as a .ics file.
+func (cal *Calendar) Encode() []byte {
+	w := &writer{}
+	w.line("BEGIN:VCALENDAR")
+	w.line("VERSION:2.0")
+	w.line("PRODID:-//LMS Remake//Schedule//EN")
+	w.line("CALSCALE:GREGORIAN")
+	w.line("METHOD:PUBLISH")
+	if cal.Name != "" {
+		w.line("X-WR-CALNAME:" + escapeText(cal.Name))
+	}
+
+	cal.writeTimezones(w)
+
+	stamp := "DTSTAMP:" + formatUTC(cal.Stamp)
+	for _, event := range cal.Events {
+		w.line("BEGIN:VEVENT")
+		w.line("UID:" + event.UID)
+		w.line(stamp)
+		w.line("DTSTART" + formatTime(event.Start))
+		if !event.End.Equal(event.Start) {
+			w.line("DTEND" + formatTime(event.End))
+		}
+		if event.Weekly {
+			rule := "RRULE:FREQ=WEEKLY"
+			if event.Until != nil {
+				// With a TZID on DTSTART, UNTIL must be in UTC.
+				rule += ";UNTIL=" + formatUTC(*event.Until)
+			}
+			w.line(rule)
+		}
+		w.line("SUMMARY:" + escapeText(event.Summary))
+		if event.Description != "" {
+			w.line("DESCRIPTION:" + escapeText(event.Description))
+		}
+		if event.Location != "" {
+			w.line("LOCATION:" + escapeText(event.Location))
+		}
+		w.line("END:VEVENT")
+	}
+
+	for _, todo := range cal.Todos {
+		w.line("BEGIN:VTODO")
+		w.line("UID:" + todo.UID)
+		w.line(stamp)
+		w.line("DUE" + formatTime(todo.Due))
+		w.line("SUMMARY:" + escapeText(todo.Summary))
+		if todo.Description != "" {
+			w.line("DESCRIPTION:" + escapeText(todo.Description))
+		}
+		w.line("END:VTODO")
+	}
+
+	w.line("END:VCALENDAR")
+
+	return []byte(w.String())
+}
+
+// writeTimezones adds a VTIMEZONE for every location the events use, with the
+// offset changes between the first event and the last recurrence.
+func (cal *Calendar) writeTimezones(w *writer) {
+	type span struct {
+		location *time.Location
+		from, to time.Time
+	}
+	spans := map[string]*span{}
+
+	for _, event := range cal.Events {
+		location := event.Start.Location()
+		if location == time.UTC {
+			continue
+		}
+
+		to := event.End
+		if event.Weekly {
+			// An open ended rule gets a year of offsets, which is as far as
+			// calendar apps usually look ahead of a refresh anyway.
+			to = event.Start.AddDate(1, 0, 0)
+			if event.Until != nil {
+				to = *event.Until
+			}
+		}
+
+		s, ok := spans[location.String()]
+		if !ok {
+			spans[location.String()] = &span{location: location, from: event.Start, to: to}
+			continue
+		}
+		if event.Start.Before(s.from) {
+			s.from = event.Start
+		}
+		if to.After(s.to) {
+			s.to = to
+		}
+	}
+
+	names := make([]string, 0, len(spans))
+	for name := range spans {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		s := spans[name]
+		w.line("BEGIN:VTIMEZONE")
+		w.line("TZID:" + name)
+
+		t := s.from.In(s.location)
+		zoneName, offset := t.Zone()
+		onset := t
+		previous := offset
+		for {
+			component := "STANDARD"
+			if t.IsDST() {
+				component = "DAYLIGHT"
+			}
+			w.line("BEGIN:" + component)
+			// The onset is a local time in the offset that was in effect.
+			w.line("DTSTART:" + onset.In(time.FixedZone("", previous)).Format(dateTimeFormat))
+			w.line("TZOFFSETFROM:" + formatOffset(previous))
+			w.line("TZOFFSETTO:" + formatOffset(offset))
+			w.line("TZNAME:" + escapeText(zoneName))
+			w.line("END:" + component)
+
+			_, end := t.ZoneBounds()
+			if end.IsZero() || end.After(s.to) {
+				break
+			}
+
+			previous = offset
+			onset = end
+			t = end.In(s.location)
+			zoneName, offset = t.Zone()
+		}
+
+		w.line("END:VTIMEZONE")
+	}
+}
+
+// formatTime is the value of a DTSTART, DTEND or DUE property, including the
+// TZID parameter for local times.
+func formatTime(t time.Time) string {
+	if t.Location() == time.UTC {
+		return ":" + formatUTC(t)
+	}
+
+	return ";TZID=" + t.Location().String() + ":" + t.Format(dateTimeFormat)
+}
+
+func formatUTC(t time.Time) string {
+	return t.UTC().Format(dateTimeFormat) + "Z"
+}
+
+func formatOffset(seconds int) string {
+	sign := "+"
+	if seconds < 0 {
+		sign = "-"
+		seconds = -seconds
+	}
+
+	return sign + time.Date(0, 1, 1, 0, 0, seconds, 0, time.UTC).Format("1504")
+}
+
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+
+// escapeText escapes a TEXT value (RFC 5545 section 3.3.11).
+func escapeText(text string) string {
+	return textEscaper.Replace(text)
+}
+
+// writer builds content lines, folding them at 75 octets without splitting a
+// UTF-8 character.
+type writer struct {
+	strings.Builder
+}
+
+func (w *writer) line(content string) {
+	limit := maxLineOctets
+	for len(content) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(content[cut]) {
+			cut--
+		}
+		w.WriteString(content[:cut])
+		w.WriteString("\r\n ")
+		content = content[cut:]
+		// The leading space of a continuation line counts too.
+		limit = maxLineOctets - 1
+	}
+	w.WriteString(content)
+	w.WriteString("\r\n")
+}
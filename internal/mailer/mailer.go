@@ -0,0 +1,108 @@
+package mailer
+
+import (
+	"fmt"
+	"github.com/spf13/viper"
+	"log"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Mailer interface {
+	Send(to string, subject string, body string) error
+}
+
+type smtpMailer struct {
+	host     string
+	port     string
+	username string
+	password string
+	from     string
+}
+
+func (m *smtpMailer) Send(to string, subject string, body string) error {
+	message := strings.Join([]string{
+		fmt.Sprintf("From: %s", m.from),
+		fmt.Sprintf("To: %s", to),
+		fmt.Sprintf("Subject: %s", subject),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=\"utf-8\"",
+		"",
+		body,
+	}, "\r\n")
+
+	var auth smtp.Auth
+	if m.username != "" {
+		auth = smtp.PlainAuth("", m.username, m.password, m.host)
+	}
+
+	return smtp.SendMail(m.host+":"+m.port, auth, m.from, []string{to}, []byte(message))
+}
+
+// logMailer writes mails to a file, or to the server log when no file is set,
+// so the OTP flows can be exercised locally without an SMTP server.
+type logMailer struct {
+	path  string
+	mutex sync.Mutex
+}
+
+func (m *logMailer) Send(to string, subject string, body string) error {
+	entry := fmt.Sprintf("[%s] To: %s\nSubject: %s\n\n%s\n\n", time.Now().Format(time.RFC3339), to, subject, body)
+
+	if m.path == "" {
+		log.Print(entry)
+		return nil
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	file, err := os.OpenFile(m.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.WriteString(entry)
+	return err
+}
+
+func NewSMTPMailer(host string, port string, username string, password string, from string) Mailer {
+	return &smtpMailer{
+		host:     host,
+		port:     port,
+		username: username,
+		password: password,
+		from:     from,
+	}
+}
+
+func NewLogMailer(path string) Mailer {
+	return &logMailer{
+		path: path,
+	}
+}
+
+// NewMailer picks the implementation from MAILER ("smtp" or "log").
+func NewMailer() (Mailer, error) {
+	switch viper.GetString("MAILER") {
+	case "smtp":
+		if viper.GetString("SMTP_HOST") == "" || viper.GetString("MAIL_FROM") == "" {
+			return nil, fmt.Errorf("SMTP_HOST and MAIL_FROM are required for the smtp mailer")
+		}
+		return NewSMTPMailer(
+			viper.GetString("SMTP_HOST"),
+			viper.GetString("SMTP_PORT"),
+			viper.GetString("SMTP_USERNAME"),
+			viper.GetString("SMTP_PASSWORD"),
+			viper.GetString("MAIL_FROM"),
+		), nil
+	case "", "log":
+		return NewLogMailer(viper.GetString("MAIL_LOG_FILE")), nil
+	default:
+		return nil, fmt.Errorf("unsupported MAILER %q", viper.GetString("MAILER"))
+	}
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type Admin struct {
+	ID       uuid.UUID `json:"id"`
+	Name     string    `json:"name"`
+	Email    string    `json:"email"`
+	Password string    `json:"-"`
+}
+
+// StudentAccount is the admin view of a student, including account status.
+type StudentAccount struct {
+	ID          uuid.UUID  `json:"id"`
+	Name        string     `json:"name"`
+	NIM         int        `json:"NIM"`
+	Email       string     `json:"email"`
+	VerifiedAt  *time.Time `json:"verified_at"`
+	SuspendedAt *time.Time `json:"suspended_at"`
+	DeletedAt   *time.Time `json:"deleted_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	// DeletionScheduledAt is set while the student's own closure request is
+	// in its grace period.
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at"`
+}
+
+// TeacherAccount is the admin view of a teacher, including account status.
+type TeacherAccount struct {
+	ID          uuid.UUID  `json:"id"`
+	Name        string     `json:"name"`
+	NPM         int        `json:"NPM"`
+	Email       string     `json:"email"`
+	VerifiedAt  *time.Time `json:"verified_at"`
+	ApprovedAt  *time.Time `json:"approved_at"`
+	SuspendedAt *time.Time `json:"suspended_at"`
+	DeletedAt   *time.Time `json:"deleted_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"time"
+)
+
+// APIToken is a long-lived credential for scripts. Only the hash of the token
+// is stored; Prefix is kept so users can tell their tokens apart.
+type APIToken struct {
+	ID         uuid.UUID      `json:"id"`
+	UserID     uuid.UUID      `json:"-"`
+	Role       string         `json:"-"`
+	Name       string         `json:"name"`
+	Prefix     string         `json:"prefix"`
+	TokenHash  string         `json:"-"`
+	Scopes     pq.StringArray `json:"scopes"`
+	LastUsedAt *time.Time     `json:"last_used_at"`
+	ExpiresAt  *time.Time     `json:"expires_at"`
+	RevokedAt  *time.Time     `json:"revoked_at"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
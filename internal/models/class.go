@@ -2,17 +2,29 @@ package models
 
 import (
 	"github.com/google/uuid"
+	"time"
 )
 
 type Class struct {
-	ID           int             `json:"id"`
-	Name         string          `json:"name"`
-	Description  string          `json:"description"`
-	Key          string          `json:"key"`
-	TeacherId    uuid.UUID       `json:"teacher_id"`
-	Day          string          `json:"day"`
-	StartTime    string          `json:"start_time"`
-	EndTime      string          `json:"end_time"`
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Key         string `json:"key"`
+	// KeyExpiresAt is when the key stops working, nil when it doesn't expire.
+	KeyExpiresAt *time.Time `json:"key_expires_at"`
+	TeacherId    uuid.UUID  `json:"teacher_id"`
+	// TermId is the term the class is offered in, nil for classes from
+	// before terms.
+	TermId *int `json:"term_id"`
+	// EnrollmentMode is how students get in, see utils.ENROLLMENT_MODE_OPEN.
+	EnrollmentMode string `json:"enrollment_mode"`
+	// Capacity is the most students enrolled at once, nil when unlimited.
+	Capacity *int `json:"capacity"`
+	// ArchivedAt is set while the class is archived: still visible, but
+	// read-only.
+	ArchivedAt *time.Time `json:"archived_at"`
+	// Meetings are sorted by weekday, then start time.
+	Meetings     []*ClassMeeting `json:"meetings"`
 	ClassSection []*SectionClass `json:"class_section"`
 	Student      []*StudentClass `json:"student"`
 }
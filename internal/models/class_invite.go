@@ -0,0 +1,20 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// ClassInvite lets students into a class without its key, whatever the
+// enrollment mode. Only a hash of the code is stored.
+type ClassInvite struct {
+	ID        int        `json:"id"`
+	ClassId   int        `json:"class_id"`
+	CodeHash  string     `json:"-"`
+	CreatedBy uuid.UUID  `json:"created_by"`
+	MaxUses   *int       `json:"max_uses"`
+	Uses      int        `json:"uses"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
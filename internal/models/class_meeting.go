@@ -0,0 +1,31 @@
+package models
+
+// ClassMeeting is one weekly slot of a class. Times are wall clock times in
+// Timezone.
+type ClassMeeting struct {
+	ID      int `json:"id"`
+	ClassId int `json:"class_id"`
+	// Weekday is the ISO weekday, 1 for monday up to 7 for sunday.
+	Weekday   int    `json:"weekday"`
+	Day       string `json:"day"`
+	StartTime string `json:"start_time"`
+	EndTime   string `json:"end_time"`
+	Room      string `json:"room"`
+	Timezone  string `json:"timezone"`
+}
+
+// ScheduleEntry is one weekly meeting in a student's or teacher's schedule.
+type ScheduleEntry struct {
+	Name   string `json:"class_name"`
+	TermId *int   `json:"term_id"`
+	ClassMeeting
+}
+
+// ScheduleConflict is a meeting already in someone's schedule that overlaps
+// a meeting of the class they are about to take on.
+type ScheduleConflict struct {
+	ClassId       int           `json:"class_id"`
+	ClassName     string        `json:"class_name"`
+	Meeting       *ClassMeeting `json:"meeting"`
+	ConflictsWith *ClassMeeting `json:"conflicts_with"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type ClassStaff struct {
+	ClassId    int        `json:"class_id"`
+	TeacherId  uuid.UUID  `json:"teacher_id"`
+	Name       string     `json:"name"`
+	Email      string     `json:"email"`
+	Role       string     `json:"role"`
+	InvitedBy  *uuid.UUID `json:"invited_by"`
+	AcceptedAt *time.Time `json:"accepted_at"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// EnrollmentRequest is a student waiting to get into a class, either for a
+// teacher's approval or for a free seat.
+type EnrollmentRequest struct {
+	ID        int       `json:"id"`
+	ClassId   int       `json:"class_id"`
+	StudentId uuid.UUID `json:"student_id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Status    string    `json:"status"`
+	// Position is the place in the waitlist, counted from 1.
+	Position  *int       `json:"position,omitempty"`
+	DecidedBy *uuid.UUID `json:"decided_by"`
+	DecidedAt *time.Time `json:"decided_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+type LoginAttempt struct {
+	ID        int       `json:"id"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	IPAddress string    `json:"ip_address"`
+	Success   bool      `json:"success"`
+	Reason    *string   `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
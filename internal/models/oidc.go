@@ -0,0 +1,25 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// OIDCLoginRequest is a single sign-on login that was started and waits for the
+// identity provider to redirect back with the matching state.
+type OIDCLoginRequest struct {
+	ID           int       `json:"id"`
+	StateHash    string    `json:"-"`
+	Role         string    `json:"role"`
+	Nonce        string    `json:"-"`
+	CodeVerifier string    `json:"-"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// OIDCIdentity links an account at the identity provider to a student or teacher.
+type OIDCIdentity struct {
+	Issuer  string    `json:"issuer"`
+	Subject string    `json:"subject"`
+	Role    string    `json:"role"`
+	UserID  uuid.UUID `json:"user_id"`
+}
@@ -6,11 +6,15 @@ import (
 )
 
 type Student struct {
-	ID       uuid.UUID `json:"id"`
-	Name     string    `json:"name"`
-	NIM      int       `json:"NIM"`
-	Email    string    `json:"email"`
-	Password string    `json:"-"`
+	ID          uuid.UUID  `json:"id"`
+	Name        string     `json:"name"`
+	NIM         int        `json:"NIM"`
+	Email       string     `json:"email"`
+	Password    string     `json:"-"`
+	VerifiedAt  *time.Time `json:"-"`
+	SuspendedAt *time.Time `json:"-"`
+	// DeletionScheduledAt is when a requested account closure takes effect.
+	DeletionScheduledAt *time.Time `json:"-"`
 }
 
 type StudentProfile struct {
@@ -29,15 +33,26 @@ type StudentClass struct {
 	Name string    `json:"name"`
 }
 
-type StudentSchedule struct {
-	Name      string `json:"class_name"`
-	Day       string `json:"date"`
-	StartTime string `json:"start_time"`
-	EndTime   string `json:"end_time"`
-}
-
 type StudentSubmission struct {
 	ID   uuid.UUID `json:"id"`
 	Name string    `json:"name"`
 	File string    `json:"file"`
 }
+
+// StudentEnrollment is one class membership, past or present, as included in
+// a student's data export.
+type StudentEnrollment struct {
+	ClassID  int        `json:"class_id"`
+	Name     string     `json:"class_name"`
+	JoinedAt time.Time  `json:"joined_at"`
+	LeftAt   *time.Time `json:"left_at"`
+}
+
+// StudentSubmissionRecord is one submission as included in a student's data
+// export.
+type StudentSubmissionRecord struct {
+	ClassSectionID int       `json:"class_section_id"`
+	SectionTitle   string    `json:"section_title"`
+	File           string    `json:"file"`
+	SubmittedAt    time.Time `json:"submitted_at"`
+}
@@ -10,3 +10,13 @@ type Submission struct {
 	Deadline       time.Time `json:"deadline"`
 	ClassSectionId int       `json:"class_section_id"`
 }
+
+// Deadline is an assignment due in one of the classes in someone's schedule.
+type Deadline struct {
+	SubmissionId int       `json:"submission_id"`
+	ClassId      int       `json:"class_id"`
+	ClassName    string    `json:"class_name"`
+	Title        string    `json:"title"`
+	Description  string    `json:"description"`
+	Deadline     time.Time `json:"deadline"`
+}
@@ -2,12 +2,25 @@ package models
 
 import (
 	"github.com/google/uuid"
+	"time"
 )
 
 type Teacher struct {
-	ID       uuid.UUID `json:"id"`
-	Name     string    `json:"name"`
-	NPM      int       `json:"NPM"`
-	Email    string    `json:"email"`
-	Password string    `json:"password"`
+	ID          uuid.UUID  `json:"id"`
+	Name        string     `json:"name"`
+	NPM         int        `json:"NPM"`
+	Email       string     `json:"email"`
+	Password    string     `json:"-"`
+	VerifiedAt  *time.Time `json:"-"`
+	ApprovedAt  *time.Time `json:"-"`
+	SuspendedAt *time.Time `json:"-"`
+}
+
+type TeacherProfile struct {
+	ID          uuid.UUID `json:"-"`
+	Title       string    `json:"title"`
+	Department  string    `json:"department"`
+	OfficeHours string    `json:"office_hours"`
+	Bio         string    `json:"bio"`
+	AvatarURL   string    `json:"avatar_url"`
 }
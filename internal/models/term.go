@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Term is an academic term, like a semester. Classes are offered in a term.
+type Term struct {
+	ID       int       `json:"id"`
+	Name     string    `json:"name"`
+	StartsOn time.Time `json:"starts_on"`
+	EndsOn   time.Time `json:"ends_on"`
+	// EnrollmentOpensAt and EnrollmentClosesAt bound when students may join
+	// the term's classes, nil for no bound on that side.
+	EnrollmentOpensAt  *time.Time `json:"enrollment_opens_at"`
+	EnrollmentClosesAt *time.Time `json:"enrollment_closes_at"`
+}
+
+// EnrollmentOpen tells whether students may join the term's classes at now.
+func (t *Term) EnrollmentOpen(now time.Time) bool {
+	if t.EnrollmentOpensAt != nil && now.Before(*t.EnrollmentOpensAt) {
+		return false
+	}
+	if t.EnrollmentClosesAt != nil && !now.Before(*t.EnrollmentClosesAt) {
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// TOTPSecret is a user's authenticator enrollment. Two-factor login is only
+// required once ConfirmedAt is set. Secret is encrypted at rest.
+type TOTPSecret struct {
+	UserID       uuid.UUID  `json:"user_id"`
+	Role         string     `json:"role"`
+	Secret       string     `json:"-"`
+	ConfirmedAt  *time.Time `json:"confirmed_at"`
+	LastUsedStep *int64     `json:"-"`
+}
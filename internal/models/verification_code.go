@@ -0,0 +1,19 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type VerificationCode struct {
+	ID       int       `json:"id"`
+	UserID   uuid.UUID `json:"user_id"`
+	Role     string    `json:"role"`
+	CodeHash string    `json:"-"`
+	Attempts int       `json:"attempts"`
+	// NewEmail is set on codes that confirm an email change, and is the
+	// address the code was sent to.
+	NewEmail  *string   `json:"new_email"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,352 @@
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/spf13/viper"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Claims is what we read from a verified ID token.
+type Claims struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	raw           jwt.MapClaims
+}
+
+// Number reads a claim holding a student or teacher number, which identity
+// providers send either as a JSON number or as a string. It returns 0 when the
+// claim is missing or not a number.
+func (c *Claims) Number(name string) int {
+	switch value := c.raw[name].(type) {
+	case float64:
+		return int(value)
+	case string:
+		number, err := strconv.Atoi(value)
+		if err != nil {
+			return 0
+		}
+		return number
+	default:
+		return 0
+	}
+}
+
+// Provider runs the authorization code flow with PKCE against one OpenID
+// Connect identity provider.
+type Provider interface {
+	AuthCodeURL(c context.Context, state string, nonce string, codeVerifier string) (string, error)
+	Exchange(c context.Context, code string, codeVerifier string, nonce string) (*Claims, error)
+}
+
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JwksURI               string `json:"jwks_uri"`
+}
+
+type httpProvider struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	redirectURL  string
+	scopes       string
+	client       *http.Client
+
+	mutex       sync.Mutex
+	endpoints   *discovery
+	keys        map[string]interface{}
+	keysFetched time.Time
+}
+
+// jwksRefreshInterval limits how often an unknown kid makes us fetch the key
+// set again, so forged tokens can't be used to hammer the identity provider.
+const jwksRefreshInterval = time.Minute
+
+// CodeChallenge is the S256 PKCE challenge for a code verifier.
+func CodeChallenge(codeVerifier string) string {
+	sum := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func (p *httpProvider) AuthCodeURL(c context.Context, state string, nonce string, codeVerifier string) (string, error) {
+	endpoints, err := p.discover(c)
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.clientID},
+		"redirect_uri":          {p.redirectURL},
+		"scope":                 {p.scopes},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {CodeChallenge(codeVerifier)},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(endpoints.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	return endpoints.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+func (p *httpProvider) Exchange(c context.Context, code string, codeVerifier string, nonce string) (*Claims, error) {
+	endpoints, err := p.discover(c)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.redirectURL},
+		"client_id":     {p.clientID},
+		"code_verifier": {codeVerifier},
+	}
+
+	request, err := http.NewRequestWithContext(c, http.MethodPost, endpoints.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	if p.clientSecret != "" {
+		request.SetBasicAuth(url.QueryEscape(p.clientID), url.QueryEscape(p.clientSecret))
+	}
+
+	var token struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	status, err := p.doJSON(request, &token)
+	if err != nil {
+		return nil, err
+	}
+
+	if status != http.StatusOK || token.Error != "" {
+		return nil, fmt.Errorf("token endpoint returned %d %s %s", status, token.Error, token.ErrorDescription)
+	}
+
+	if token.IDToken == "" {
+		return nil, fmt.Errorf("token endpoint returned no id_token")
+	}
+
+	return p.verifyIDToken(c, endpoints, token.IDToken, nonce)
+}
+
+func (p *httpProvider) verifyIDToken(c context.Context, endpoints *discovery, rawIDToken string, nonce string) (*Claims, error) {
+	claims := jwt.MapClaims{}
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "ES256", "ES384"}), jwt.WithoutClaimsValidation())
+	_, err := parser.ParseWithClaims(rawIDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.key(c, endpoints, kid)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	leeway := int64(viper.GetInt("JWT_LEEWAY"))
+
+	if !claims.VerifyIssuer(endpoints.Issuer, true) {
+		return nil, fmt.Errorf("id token has the wrong issuer")
+	}
+
+	if !claims.VerifyAudience(p.clientID, true) {
+		return nil, fmt.Errorf("id token is not meant for this client")
+	}
+
+	if azp, ok := claims["azp"].(string); ok && azp != p.clientID {
+		return nil, fmt.Errorf("id token was issued to another client")
+	}
+
+	if !claims.VerifyExpiresAt(now.Unix()-leeway, true) {
+		return nil, fmt.Errorf("id token is expired")
+	}
+
+	if tokenNonce, _ := claims["nonce"].(string); tokenNonce != nonce {
+		return nil, fmt.Errorf("id token nonce does not match")
+	}
+
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return nil, fmt.Errorf("id token has no subject")
+	}
+
+	result := &Claims{
+		Issuer:  endpoints.Issuer,
+		Subject: subject,
+		raw:     claims,
+	}
+	result.Email, _ = claims["email"].(string)
+	result.Name, _ = claims["name"].(string)
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		result.EmailVerified = verified
+	case string:
+		result.EmailVerified = verified == "true"
+	}
+
+	return result, nil
+}
+
+// discover loads the provider metadata on first use, so the server can start
+// while the identity provider is unreachable.
+func (p *httpProvider) discover(c context.Context) (*discovery, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.endpoints != nil {
+		return p.endpoints, nil
+	}
+
+	request, err := http.NewRequestWithContext(c, http.MethodGet, p.issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var endpoints discovery
+	status, err := p.doJSON(request, &endpoints)
+	if err != nil {
+		return nil, err
+	}
+
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("discovery returned %d", status)
+	}
+
+	if endpoints.Issuer != p.issuer {
+		return nil, fmt.Errorf("discovery issuer %q does not match OIDC_ISSUER", endpoints.Issuer)
+	}
+
+	if endpoints.AuthorizationEndpoint == "" || endpoints.TokenEndpoint == "" || endpoints.JwksURI == "" {
+		return nil, fmt.Errorf("discovery document is missing endpoints")
+	}
+
+	p.endpoints = &endpoints
+	return p.endpoints, nil
+}
+
+// key returns the signing key for kid, fetching the key set again when the
+// provider rotated its keys since the last fetch.
+func (p *httpProvider) key(c context.Context, endpoints *discovery, kid string) (interface{}, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+
+	if time.Since(p.keysFetched) < jwksRefreshInterval {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	request, err := http.NewRequestWithContext(c, http.MethodGet, endpoints.JwksURI, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	status, err := p.doJSON(request, &jwks)
+	if err != nil {
+		return nil, err
+	}
+
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("jwks endpoint returned %d", status)
+	}
+
+	keys := make(map[string]interface{})
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		public, err := jwk.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = public
+	}
+
+	p.keys = keys
+	p.keysFetched = time.Now()
+
+	key, ok := p.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	return key, nil
+}
+
+func (p *httpProvider) doJSON(request *http.Request, target interface{}) (int, error) {
+	response, err := p.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, 1<<20))
+	if err != nil {
+		return 0, err
+	}
+
+	if err := json.Unmarshal(body, target); err != nil && response.StatusCode == http.StatusOK {
+		return 0, fmt.Errorf("decoding %s: %w", request.URL.Path, err)
+	}
+
+	return response.StatusCode, nil
+}
+
+func NewHTTPProvider(issuer string, clientID string, clientSecret string, redirectURL string, scopes string) Provider {
+	return &httpProvider{
+		issuer:       strings.TrimSuffix(issuer, "/"),
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		redirectURL:  redirectURL,
+		scopes:       scopes,
+		client:       &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// NewProvider builds the provider from the OIDC_* settings. It returns nil
+// without an error when OIDC_ISSUER is empty, which turns single sign-on off.
+func NewProvider() (Provider, error) {
+	issuer := viper.GetString("OIDC_ISSUER")
+	if issuer == "" {
+		return nil, nil
+	}
+
+	if viper.GetString("OIDC_CLIENT_ID") == "" || viper.GetString("OIDC_REDIRECT_URL") == "" {
+		return nil, fmt.Errorf("OIDC_CLIENT_ID and OIDC_REDIRECT_URL are required when OIDC_ISSUER is set")
+	}
+
+	return NewHTTPProvider(
+		issuer,
+		viper.GetString("OIDC_CLIENT_ID"),
+		viper.GetString("OIDC_CLIENT_SECRET"),
+		viper.GetString("OIDC_REDIRECT_URL"),
+		viper.GetString("OIDC_SCOPES"),
+	), nil
+}
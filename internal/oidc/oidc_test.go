@@ -0,0 +1,216 @@
+package oidc
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rifkhia/lms-remake/internal/oidc/oidctest"
+	"github.com/spf13/viper"
+)
+
+const (
+	testClientID    = "lms"
+	testSecret      = "s3cret"
+	testRedirectURL = "http://localhost:3000/v1/auth/oidc/callback"
+)
+
+func newTestProvider(t *testing.T) (*oidctest.Server, *httpProvider) {
+	t.Helper()
+
+	viper.Set("JWT_LEEWAY", 30)
+
+	server := oidctest.NewServer(testClientID, testSecret)
+	t.Cleanup(server.Close)
+
+	provider := NewHTTPProvider(server.Issuer(), testClientID, testSecret, testRedirectURL, "openid email profile").(*httpProvider)
+
+	return server, provider
+}
+
+// login runs one authorization code flow. The ID token gets claims on top of
+// the defaults, and the code is exchanged with exchangeVerifier when set.
+func login(t *testing.T, server *oidctest.Server, provider Provider, claims map[string]interface{}, exchangeVerifier string) (*Claims, error) {
+	t.Helper()
+
+	c := context.Background()
+	nonce := "nonce-value"
+	codeVerifier := "a-code-verifier-that-is-long-enough-for-pkce-43"
+
+	authorizationURL, err := provider.AuthCodeURL(c, "state-value", nonce, codeVerifier)
+	if err != nil {
+		t.Fatalf("building the authorization url: %s", err)
+	}
+
+	code, err := server.Authorize(authorizationURL, "user-1", claims)
+	if err != nil {
+		t.Fatalf("authorizing: %s", err)
+	}
+
+	if exchangeVerifier == "" {
+		exchangeVerifier = codeVerifier
+	}
+
+	return provider.Exchange(c, code, exchangeVerifier, nonce)
+}
+
+func TestExchange(t *testing.T) {
+	tests := []struct {
+		name     string
+		claims   map[string]interface{}
+		verifier string
+		wantErr  string
+	}{
+		{name: "good login"},
+		{name: "expired within leeway", claims: map[string]interface{}{"exp": time.Now().Add(-10 * time.Second).Unix()}},
+		{name: "audience list", claims: map[string]interface{}{"aud": []string{"other", testClientID}, "azp": testClientID}},
+		{name: "bad nonce", claims: map[string]interface{}{"nonce": "replayed"}, wantErr: "nonce"},
+		{name: "missing nonce", claims: map[string]interface{}{"nonce": nil}, wantErr: "nonce"},
+		{name: "bad audience", claims: map[string]interface{}{"aud": "another-client"}, wantErr: "not meant for this client"},
+		{name: "issued to another client", claims: map[string]interface{}{"aud": []string{"other", testClientID}, "azp": "other"}, wantErr: "another client"},
+		{name: "bad issuer", claims: map[string]interface{}{"iss": "https://evil.example"}, wantErr: "wrong issuer"},
+		{name: "expired", claims: map[string]interface{}{"exp": time.Now().Add(-time.Hour).Unix()}, wantErr: "expired"},
+		{name: "missing exp", claims: map[string]interface{}{"exp": nil}, wantErr: "expired"},
+		{name: "missing subject", claims: map[string]interface{}{"sub": nil}, wantErr: "no subject"},
+		{name: "pkce verifier mismatch", verifier: "a-different-verifier-than-the-one-we-started-with", wantErr: "invalid_grant"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, provider := newTestProvider(t)
+
+			claims, err := login(t, server, provider, test.claims, test.verifier)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("expected an error containing %q, got %v", test.wantErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected the login to succeed, got %s", err)
+			}
+			if claims.Subject != "user-1" || claims.Issuer != server.Issuer() {
+				t.Fatalf("unexpected claims %+v", claims)
+			}
+		})
+	}
+}
+
+func TestExchangeClaims(t *testing.T) {
+	server, provider := newTestProvider(t)
+
+	claims, err := login(t, server, provider, map[string]interface{}{
+		"email":          "ana@example.ac.id",
+		"email_verified": "true",
+		"name":           "Ana",
+		"nim":            "12345",
+		"npm":            67890,
+	}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if claims.Email != "ana@example.ac.id" || !claims.EmailVerified || claims.Name != "Ana" {
+		t.Fatalf("unexpected claims %+v", claims)
+	}
+	if claims.Number("nim") != 12345 || claims.Number("npm") != 67890 || claims.Number("missing") != 0 {
+		t.Fatalf("unexpected numbers %d %d", claims.Number("nim"), claims.Number("npm"))
+	}
+}
+
+func TestExchangeCodeIsSingleUse(t *testing.T) {
+	server, provider := newTestProvider(t)
+	c := context.Background()
+
+	authorizationURL, err := provider.AuthCodeURL(c, "state", "nonce", "a-code-verifier-that-is-long-enough-for-pkce-43")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := server.Authorize(authorizationURL, "user-1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := provider.Exchange(c, code, "a-code-verifier-that-is-long-enough-for-pkce-43", "nonce"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.Exchange(c, code, "a-code-verifier-that-is-long-enough-for-pkce-43", "nonce"); err == nil {
+		t.Fatal("a code must not be exchanged twice")
+	}
+}
+
+func TestExchangeWrongClientSecret(t *testing.T) {
+	server, _ := newTestProvider(t)
+	provider := NewHTTPProvider(server.Issuer(), testClientID, "wrong", testRedirectURL, "openid")
+
+	if _, err := login(t, server, provider, nil, ""); err == nil || !strings.Contains(err.Error(), "invalid_client") {
+		t.Fatalf("expected invalid_client, got %v", err)
+	}
+}
+
+// An ID token signed with a key we haven't seen fetches the key set again, but
+// at most once per jwksRefreshInterval.
+func TestExchangeUnknownKidRefreshesKeys(t *testing.T) {
+	server, provider := newTestProvider(t)
+
+	if _, err := login(t, server, provider, nil, ""); err != nil {
+		t.Fatal(err)
+	}
+	if server.JWKSRequests() != 1 {
+		t.Fatalf("expected 1 key set request, got %d", server.JWKSRequests())
+	}
+
+	if _, err := login(t, server, provider, nil, ""); err != nil {
+		t.Fatal(err)
+	}
+	if server.JWKSRequests() != 1 {
+		t.Fatalf("a known kid must not fetch the key set, got %d requests", server.JWKSRequests())
+	}
+
+	server.RotateKey()
+
+	if _, err := login(t, server, provider, nil, ""); err == nil || !strings.Contains(err.Error(), "unknown key id") {
+		t.Fatalf("expected an unknown key id right after a fetch, got %v", err)
+	}
+	if server.JWKSRequests() != 1 {
+		t.Fatalf("the key set must not be fetched again within the interval, got %d requests", server.JWKSRequests())
+	}
+
+	provider.keysFetched = time.Now().Add(-jwksRefreshInterval)
+
+	if _, err := login(t, server, provider, nil, ""); err != nil {
+		t.Fatalf("expected the rotated key to be fetched, got %s", err)
+	}
+	if server.JWKSRequests() != 2 {
+		t.Fatalf("expected 2 key set requests, got %d", server.JWKSRequests())
+	}
+}
+
+func TestDiscoveryIssuerMismatch(t *testing.T) {
+	server, _ := newTestProvider(t)
+	provider := NewHTTPProvider(server.Issuer()+"/tenant", testClientID, testSecret, testRedirectURL, "openid")
+
+	if _, err := provider.AuthCodeURL(context.Background(), "state", "nonce", "verifier"); err == nil {
+		t.Fatal("expected discovery at the wrong issuer to fail")
+	}
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	server, provider := newTestProvider(t)
+
+	authorizationURL, err := provider.AuthCodeURL(context.Background(), "state", "nonce", "verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{server.URL + "/authorize?", "code_challenge=" + CodeChallenge("verifier"), "code_challenge_method=S256", "state=state", "nonce=nonce"} {
+		if !strings.Contains(authorizationURL, want) {
+			t.Errorf("expected %q in %s", want, authorizationURL)
+		}
+	}
+	if strings.Contains(authorizationURL, "verifier&") || strings.HasSuffix(authorizationURL, "=verifier") {
+		t.Errorf("the code verifier must not be sent in %s", authorizationURL)
+	}
+}
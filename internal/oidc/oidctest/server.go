@@ -0,0 +1,234 @@
+// Package oidctest runs a local OpenID Connect provider for tests. It serves
+// discovery, the token endpoint with PKCE and a JSON Web Key Set, and signs ID
+// tokens with a key that can be rotated.
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+type grant struct {
+	clientID      string
+	redirectURL   string
+	codeChallenge string
+	claims        jwt.MapClaims
+}
+
+type Server struct {
+	*httptest.Server
+	ClientID     string
+	ClientSecret string
+
+	mutex        sync.Mutex
+	key          *rsa.PrivateKey
+	kid          string
+	keyCount     int
+	grants       map[string]*grant
+	jwksRequests int
+}
+
+// NewServer starts a provider that only knows the given client. Close it when
+// the test is done.
+func NewServer(clientID string, clientSecret string) *Server {
+	s := &Server{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		grants:       make(map[string]*grant),
+	}
+	s.RotateKey()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("/token", s.token)
+	mux.HandleFunc("/jwks", s.jwks)
+	s.Server = httptest.NewServer(mux)
+
+	return s
+}
+
+// Issuer is what OIDC_ISSUER points at.
+func (s *Server) Issuer() string {
+	return s.URL
+}
+
+// RotateKey replaces the signing key. The old key leaves the key set.
+func (s *Server) RotateKey() {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.keyCount++
+	s.key = key
+	s.kid = fmt.Sprintf("key-%d", s.keyCount)
+}
+
+// JWKSRequests counts how often the key set was fetched.
+func (s *Server) JWKSRequests() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.jwksRequests
+}
+
+// Authorize plays the user signing in at the authorization URL the client
+// sent them to, and returns the code the provider would redirect back with.
+// The ID token gets the usual claims for subject, and claims adds to or
+// replaces them.
+func (s *Server) Authorize(authorizationURL string, subject string, claims map[string]interface{}) (string, error) {
+	parsed, err := url.Parse(authorizationURL)
+	if err != nil {
+		return "", err
+	}
+	query := parsed.Query()
+
+	if query.Get("client_id") != s.ClientID {
+		return "", fmt.Errorf("unknown client %q", query.Get("client_id"))
+	}
+	if query.Get("response_type") != "code" {
+		return "", fmt.Errorf("unsupported response_type %q", query.Get("response_type"))
+	}
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		return "", fmt.Errorf("a S256 code challenge is required")
+	}
+
+	now := time.Now()
+	idClaims := jwt.MapClaims{
+		"iss":   s.Issuer(),
+		"sub":   subject,
+		"aud":   s.ClientID,
+		"iat":   now.Unix(),
+		"exp":   now.Add(5 * time.Minute).Unix(),
+		"nonce": query.Get("nonce"),
+	}
+	for name, value := range claims {
+		if value == nil {
+			delete(idClaims, name)
+			continue
+		}
+		idClaims[name] = value
+	}
+
+	code := base64.RawURLEncoding.EncodeToString(randomBytes(16))
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.grants[code] = &grant{
+		clientID:      s.ClientID,
+		redirectURL:   query.Get("redirect_uri"),
+		codeChallenge: query.Get("code_challenge"),
+		claims:        idClaims,
+	}
+
+	return code, nil
+}
+
+func (s *Server) discovery(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                s.Issuer(),
+		"authorization_endpoint":                s.URL + "/authorize",
+		"token_endpoint":                        s.URL + "/token",
+		"jwks_uri":                              s.URL + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ParseForm() != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	if s.ClientSecret != "" {
+		clientID, clientSecret, ok := r.BasicAuth()
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+		if !ok || clientID != s.ClientID || clientSecret != s.ClientSecret {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+			return
+		}
+	}
+
+	s.mutex.Lock()
+	grant, ok := s.grants[r.PostForm.Get("code")]
+	delete(s.grants, r.PostForm.Get("code"))
+	key, kid := s.key, s.kid
+	s.mutex.Unlock()
+
+	if !ok || grant.clientID != r.PostForm.Get("client_id") || grant.redirectURL != r.PostForm.Get("redirect_uri") {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	challenge := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(challenge[:]) != grant.codeChallenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "code verifier does not match"})
+		return
+	}
+
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, grant.claims)
+	idToken.Header["kid"] = kid
+	signed, err := idToken.SignedString(key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": base64.RawURLEncoding.EncodeToString(randomBytes(16)),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     signed,
+	})
+}
+
+func (s *Server) jwks(w http.ResponseWriter, _ *http.Request) {
+	s.mutex.Lock()
+	s.jwksRequests++
+	public, kid := s.key.PublicKey, s.kid
+	s.mutex.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"kid": kid,
+			"n":   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+		}},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func randomBytes(n int) []byte {
+	bytes := make([]byte, n)
+	if _, err := rand.Read(bytes); err != nil {
+		panic(err)
+	}
+	return bytes
+}
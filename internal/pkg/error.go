@@ -1,6 +1,9 @@
 package pkg
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
 
 type CustomError struct {
 	Code    int    `json:"code"`
@@ -8,8 +11,24 @@ type CustomError struct {
 	Cause   error  `json:"detail"`
 }
 
+// DetailedError is a cause that carries more for the client than a message,
+// like the list of conflicting classes. Its details are added to the body.
+type DetailedError interface {
+	error
+	Details() map[string]interface{}
+}
+
 func (err *CustomError) Error() interface{} {
-	return map[string]interface{}{
+	body := map[string]interface{}{
 		"message": fmt.Sprintf("Error at %s : %s", err.Service, err.Cause.Error()),
 	}
+
+	var detailed DetailedError
+	if errors.As(err.Cause, &detailed) {
+		for key, value := range detailed.Details() {
+			body[key] = value
+		}
+	}
+
+	return body
 }
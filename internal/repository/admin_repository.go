@@ -0,0 +1,350 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/rifkhia/lms-remake/internal/models"
+	"github.com/rifkhia/lms-remake/internal/pkg"
+	"github.com/rifkhia/lms-remake/internal/utils"
+	"strings"
+)
+
+type AdminRepositoryImpl struct {
+	DB *sqlx.DB
+}
+
+// accountTables limits the tables the account status queries may touch.
+var accountTables = map[string]string{
+	utils.STUDENT_ROLE: "students",
+	utils.TEACHER_ROLE: "teachers",
+}
+
+// approvalColumns is what "approve" means per role: students are pending until
+// their email is verified, teachers until an admin approves them.
+var approvalColumns = map[string]string{
+	utils.STUDENT_ROLE: "verified_at",
+	utils.TEACHER_ROLE: "approved_at",
+}
+
+func accountStatusCondition(role string, status string) (string, error) {
+	switch status {
+	case "":
+		return "deleted_at IS NULL", nil
+	case utils.ACCOUNT_STATUS_PENDING:
+		return fmt.Sprintf("%s IS NULL AND deleted_at IS NULL", approvalColumns[role]), nil
+	case utils.ACCOUNT_STATUS_ACTIVE:
+		return fmt.Sprintf("%s IS NOT NULL AND suspended_at IS NULL AND deleted_at IS NULL", approvalColumns[role]), nil
+	case utils.ACCOUNT_STATUS_SUSPENDED:
+		return "suspended_at IS NOT NULL AND deleted_at IS NULL", nil
+	case utils.ACCOUNT_STATUS_DELETED:
+		return "deleted_at IS NOT NULL", nil
+	default:
+		return "", fmt.Errorf("unknown status %q", status)
+	}
+}
+
+func (r *AdminRepositoryImpl) GetAdminByEmail(c context.Context, email string) (*models.Admin, pkg.CustomError) {
+	return r.getAdmin(c, "SELECT id, name, email, password FROM admins WHERE email = $1 AND deleted_at IS NULL", email)
+}
+
+func (r *AdminRepositoryImpl) GetAdminById(c context.Context, id uuid.UUID) (*models.Admin, pkg.CustomError) {
+	return r.getAdmin(c, "SELECT id, name, email, password FROM admins WHERE id = $1 AND deleted_at IS NULL", id)
+}
+
+func (r *AdminRepositoryImpl) getAdmin(c context.Context, query string, arg interface{}) (*models.Admin, pkg.CustomError) {
+	var admin models.Admin
+
+	rows, err := r.DB.QueryxContext(c, query, arg)
+	if err != nil {
+		return nil, pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, pkg.CustomError{
+				Code:    utils.INTERNAL_SERVER_ERROR,
+				Cause:   err,
+				Service: utils.REPOSITORY_SERVICE,
+			}
+		}
+		return nil, pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("no admin found"),
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	err = rows.StructScan(&admin)
+	if err != nil {
+		return nil, pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return &admin, pkg.CustomError{}
+}
+
+func (r *AdminRepositoryImpl) CreateAdmin(c context.Context, admin *models.Admin) pkg.CustomError {
+	_, err := r.DB.NamedExecContext(c, "INSERT INTO admins(id, name, email, password, created_at, updated_at) VALUES(:id, :name, :email, :password, now(), now())", admin)
+	if err != nil {
+		if strings.Contains(err.Error(), "violates unique constraint") {
+			return pkg.CustomError{
+				Code:    utils.BAD_REQUEST,
+				Cause:   err,
+				Service: utils.REPOSITORY_SERVICE,
+			}
+		}
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return pkg.CustomError{}
+}
+
+func (r *AdminRepositoryImpl) CountAdmins(c context.Context) (int, pkg.CustomError) {
+	var count int
+
+	err := r.DB.GetContext(c, &count, "SELECT count(*) FROM admins WHERE deleted_at IS NULL")
+	if err != nil {
+		return 0, pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return count, pkg.CustomError{}
+}
+
+func (r *AdminRepositoryImpl) UpdateAdminPassword(c context.Context, id uuid.UUID, password string) pkg.CustomError {
+	_, err := r.DB.ExecContext(c, "UPDATE admins SET password = $1, updated_at = now() WHERE id = $2", password, id)
+	if err != nil {
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return pkg.CustomError{}
+}
+
+func (r *AdminRepositoryImpl) FetchStudentAccounts(c context.Context, search string, status string) ([]*models.StudentAccount, pkg.CustomError) {
+	students := []*models.StudentAccount{}
+
+	condition, err := accountStatusCondition(utils.STUDENT_ROLE, status)
+	if err != nil {
+		return nil, pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	query := "SELECT id, name, nim, email, verified_at AS verifiedat, suspended_at AS suspendedat, deleted_at AS deletedat, created_at AS createdat, deletion_scheduled_at AS deletionscheduledat FROM students WHERE (name ILIKE $1 OR email ILIKE $1) AND " + condition + " ORDER BY created_at DESC"
+	err = r.DB.SelectContext(c, &students, query, "%"+search+"%")
+	if err != nil {
+		return nil, pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return students, pkg.CustomError{}
+}
+
+func (r *AdminRepositoryImpl) FetchTeacherAccounts(c context.Context, search string, status string) ([]*models.TeacherAccount, pkg.CustomError) {
+	teachers := []*models.TeacherAccount{}
+
+	condition, err := accountStatusCondition(utils.TEACHER_ROLE, status)
+	if err != nil {
+		return nil, pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	query := "SELECT id, name, npm, email, verified_at AS verifiedat, approved_at AS approvedat, suspended_at AS suspendedat, deleted_at AS deletedat, created_at AS createdat FROM teachers WHERE (name ILIKE $1 OR email ILIKE $1) AND " + condition + " ORDER BY created_at DESC"
+	err = r.DB.SelectContext(c, &teachers, query, "%"+search+"%")
+	if err != nil {
+		return nil, pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return teachers, pkg.CustomError{}
+}
+
+func (r *AdminRepositoryImpl) ApproveUser(c context.Context, role string, id uuid.UUID) pkg.CustomError {
+	column := approvalColumns[role]
+	return r.updateAccount(c, role, id, fmt.Sprintf("%s = COALESCE(%s, now())", column, column), "deleted_at IS NULL")
+}
+
+func (r *AdminRepositoryImpl) SuspendUser(c context.Context, role string, id uuid.UUID) pkg.CustomError {
+	return r.updateAccount(c, role, id, "suspended_at = COALESCE(suspended_at, now())", "deleted_at IS NULL")
+}
+
+// RestoreUser lifts a suspension and undoes a soft delete. Students whose
+// closure has already been carried out are anonymized and cannot come back.
+func (r *AdminRepositoryImpl) RestoreUser(c context.Context, role string, id uuid.UUID) pkg.CustomError {
+	condition := "TRUE"
+	if role == utils.STUDENT_ROLE {
+		condition = "anonymized_at IS NULL"
+	}
+	return r.updateAccount(c, role, id, "suspended_at = NULL, deleted_at = NULL", condition)
+}
+
+// DeleteUser soft deletes the account. A deleted student leaves their classes
+// the same way a closed account does, so their seats go to the waitlist.
+func (r *AdminRepositoryImpl) DeleteUser(c context.Context, role string, id uuid.UUID) pkg.CustomError {
+	if role != utils.STUDENT_ROLE {
+		return r.updateAccount(c, role, id, "deleted_at = now()", "deleted_at IS NULL")
+	}
+
+	var freedClassIds []int
+	customError := r.updateAccountThen(c, role, id, "deleted_at = now()", "deleted_at IS NULL", func(tx *sqlx.Tx) (err error) {
+		freedClassIds, err = withdrawStudent(c, tx, id)
+		return err
+	})
+	if customError.Cause != nil {
+		return customError
+	}
+
+	promoteWaitlists(c, r.DB, freedClassIds)
+
+	return pkg.CustomError{}
+}
+
+func (r *AdminRepositoryImpl) updateAccount(c context.Context, role string, id uuid.UUID, set string, condition string) pkg.CustomError {
+	return r.updateAccountThen(c, role, id, set, condition, nil)
+}
+
+// updateAccountThen is updateAccount running then, when set, in the same
+// transaction once the account was updated.
+func (r *AdminRepositoryImpl) updateAccountThen(c context.Context, role string, id uuid.UUID, set string, condition string, then func(tx *sqlx.Tx) error) pkg.CustomError {
+	table, ok := accountTables[role]
+	if !ok {
+		return pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("unknown account type"),
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	tx, err := r.DB.BeginTxx(c, nil)
+	if err != nil {
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	defer tx.Rollback()
+
+	query := fmt.Sprintf("UPDATE %s SET %s, updated_at = now() WHERE id = $1 AND %s", table, set, condition)
+	result, err := tx.ExecContext(c, query, id)
+	if err != nil {
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	if affected == 0 {
+		return pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("no user found using current id"),
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	if then != nil {
+		err = then(tx)
+		if err != nil {
+			return pkg.CustomError{
+				Code:    utils.INTERNAL_SERVER_ERROR,
+				Cause:   err,
+				Service: utils.REPOSITORY_SERVICE,
+			}
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return pkg.CustomError{}
+}
+
+func (r *AdminRepositoryImpl) UpdateAdminName(c context.Context, id uuid.UUID, name string) pkg.CustomError {
+	_, err := r.DB.ExecContext(c, "UPDATE admins SET name = $1, updated_at = now() WHERE id = $2", name, id)
+	if err != nil {
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return pkg.CustomError{}
+}
+
+func (r *AdminRepositoryImpl) UpdateAdminEmail(c context.Context, id uuid.UUID, email string) pkg.CustomError {
+	_, err := r.DB.ExecContext(c, "UPDATE admins SET email = $1, updated_at = now() WHERE id = $2", email, id)
+	if err != nil {
+		if strings.Contains(err.Error(), "violates unique constraint") {
+			return pkg.CustomError{
+				Code:    utils.BAD_REQUEST,
+				Cause:   errors.New("email is already used by another account"),
+				Service: utils.REPOSITORY_SERVICE,
+			}
+		}
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return pkg.CustomError{}
+}
+
+func NewAdminRepository(db *sqlx.DB) AdminRepository {
+	return &AdminRepositoryImpl{
+		DB: db,
+	}
+}
@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/rifkhia/lms-remake/internal/models"
+	"github.com/rifkhia/lms-remake/internal/pkg"
+	"github.com/rifkhia/lms-remake/internal/utils"
+)
+
+type APITokenRepositoryImpl struct {
+	DB *sqlx.DB
+}
+
+func (r *APITokenRepositoryImpl) CreateAPIToken(c context.Context, token *models.APIToken) pkg.CustomError {
+	_, err := r.DB.NamedExecContext(c, "INSERT INTO api_tokens(id, user_id, role, name, prefix, token_hash, scopes, expires_at, created_at) VALUES(:id, :userid, :role, :name, :prefix, :tokenhash, :scopes, :expiresat, :createdat)", token)
+	if err != nil {
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return pkg.CustomError{}
+}
+
+// UseAPIToken looks up a live token by its hash and records the use in the
+// same statement.
+func (r *APITokenRepositoryImpl) UseAPIToken(c context.Context, tokenHash string) (*models.APIToken, pkg.CustomError) {
+	var token models.APIToken
+
+	rows, err := r.DB.QueryxContext(c, "UPDATE api_tokens SET last_used_at = now() WHERE token_hash = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > now()) RETURNING id, user_id AS userid, role, name, prefix, scopes, last_used_at AS lastusedat, expires_at AS expiresat, created_at AS createdat", tokenHash)
+	if err != nil {
+		return nil, pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, pkg.CustomError{
+				Code:    utils.INTERNAL_SERVER_ERROR,
+				Cause:   err,
+				Service: utils.REPOSITORY_SERVICE,
+			}
+		}
+		return nil, pkg.CustomError{
+			Code:    utils.UNAUTHORIZED,
+			Cause:   errors.New("api token is invalid, expired or revoked"),
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	err = rows.StructScan(&token)
+	if err != nil {
+		return nil, pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return &token, pkg.CustomError{}
+}
+
+func (r *APITokenRepositoryImpl) GetAPITokensByUser(c context.Context, userId uuid.UUID, role string) ([]*models.APIToken, pkg.CustomError) {
+	tokens := []*models.APIToken{}
+
+	err := r.DB.SelectContext(c, &tokens, "SELECT id, name, prefix, scopes, last_used_at AS lastusedat, expires_at AS expiresat, revoked_at AS revokedat, created_at AS createdat FROM api_tokens WHERE user_id = $1 AND role = $2 ORDER BY created_at DESC", userId, role)
+	if err != nil {
+		return nil, pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return tokens, pkg.CustomError{}
+}
+
+func (r *APITokenRepositoryImpl) RevokeAPIToken(c context.Context, id uuid.UUID, userId uuid.UUID, role string) pkg.CustomError {
+	result, err := r.DB.ExecContext(c, "UPDATE api_tokens SET revoked_at = now() WHERE id = $1 AND user_id = $2 AND role = $3 AND revoked_at IS NULL", id, userId, role)
+	if err != nil {
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	if affected == 0 {
+		return pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("no active api token found using current id"),
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return pkg.CustomError{}
+}
+
+func (r *APITokenRepositoryImpl) RevokeUserAPITokens(c context.Context, userId uuid.UUID, role string) pkg.CustomError {
+	_, err := r.DB.ExecContext(c, "UPDATE api_tokens SET revoked_at = now() WHERE user_id = $1 AND role = $2 AND revoked_at IS NULL", userId, role)
+	if err != nil {
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return pkg.CustomError{}
+}
+
+func NewAPITokenRepository(db *sqlx.DB) APITokenRepository {
+	return &APITokenRepositoryImpl{
+		DB: db,
+	}
+}
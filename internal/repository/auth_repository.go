@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/rifkhia/lms-remake/internal/models"
+	"github.com/rifkhia/lms-remake/internal/pkg"
+	"github.com/rifkhia/lms-remake/internal/utils"
+)
+
+type AuthRepositoryImpl struct {
+	DB *sqlx.DB
+}
+
+func (r *AuthRepositoryImpl) CreateRefreshToken(c context.Context, token *models.RefreshToken) pkg.CustomError {
+	_, err := r.DB.NamedExecContext(c, "INSERT INTO refresh_tokens(id, family_id, user_id, role, expires_at, created_at) VALUES(:id, :familyid, :userid, :role, :expiresat, now())", token)
+	if err != nil {
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return pkg.CustomError{}
+}
+
+func (r *AuthRepositoryImpl) GetRefreshTokenById(c context.Context, id uuid.UUID) (*models.RefreshToken, pkg.CustomError) {
+	var token models.RefreshToken
+
+	rows, err := r.DB.QueryxContext(c, "SELECT id, family_id AS familyid, user_id AS userid, role, expires_at AS expiresat, used_at AS usedat, revoked_at AS revokedat FROM refresh_tokens WHERE id = $1", id)
+	if err != nil {
+		return nil, pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, pkg.CustomError{
+				Code:    utils.INTERNAL_SERVER_ERROR,
+				Cause:   err,
+				Service: utils.REPOSITORY_SERVICE,
+			}
+		}
+		return nil, pkg.CustomError{
+			Code:    utils.UNAUTHORIZED,
+			Cause:   errors.New("refresh token not recognized"),
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	err = rows.StructScan(&token)
+	if err != nil {
+		return nil, pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return &token, pkg.CustomError{}
+}
+
+// MarkRefreshTokenUsed flags the token as consumed and reports whether this call
+// was the one that consumed it, so concurrent refreshes can't both succeed.
+func (r *AuthRepositoryImpl) MarkRefreshTokenUsed(c context.Context, id uuid.UUID) (bool, pkg.CustomError) {
+	result, err := r.DB.ExecContext(c, "UPDATE refresh_tokens SET used_at = now() WHERE id = $1 AND used_at IS NULL AND revoked_at IS NULL", id)
+	if err != nil {
+		return false, pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return affected > 0, pkg.CustomError{}
+}
+
+func (r *AuthRepositoryImpl) RevokeRefreshTokenFamily(c context.Context, familyId uuid.UUID) pkg.CustomError {
+	_, err := r.DB.ExecContext(c, "UPDATE refresh_tokens SET revoked_at = now() WHERE family_id = $1 AND revoked_at IS NULL", familyId)
+	if err != nil {
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return pkg.CustomError{}
+}
+
+func (r *AuthRepositoryImpl) RevokeUserRefreshTokens(c context.Context, userId uuid.UUID, role string) pkg.CustomError {
+	_, err := r.DB.ExecContext(c, "UPDATE refresh_tokens SET revoked_at = now() WHERE user_id = $1 AND role = $2 AND revoked_at IS NULL", userId, role)
+	if err != nil {
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return pkg.CustomError{}
+}
+
+func (r *AuthRepositoryImpl) CreatePasswordResetToken(c context.Context, token *models.PasswordResetToken) pkg.CustomError {
+	_, err := r.DB.NamedExecContext(c, "INSERT INTO password_reset_tokens(user_id, role, token_hash, expires_at, created_at) VALUES(:userid, :role, :tokenhash, :expiresat, now())", token)
+	if err != nil {
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return pkg.CustomError{}
+}
+
+// UsePasswordResetToken consumes a valid reset token in a single statement so it
+// can only ever be redeemed once.
+func (r *AuthRepositoryImpl) UsePasswordResetToken(c context.Context, tokenHash string) (*models.PasswordResetToken, pkg.CustomError) {
+	var token models.PasswordResetToken
+
+	rows, err := r.DB.QueryxContext(c, "UPDATE password_reset_tokens SET used_at = now() WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now() RETURNING id, user_id AS userid, role, token_hash AS tokenhash, expires_at AS expiresat", tokenHash)
+	if err != nil {
+		return nil, pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, pkg.CustomError{
+				Code:    utils.INTERNAL_SERVER_ERROR,
+				Cause:   err,
+				Service: utils.REPOSITORY_SERVICE,
+			}
+		}
+		return nil, pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("reset token is invalid or expired"),
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	err = rows.StructScan(&token)
+	if err != nil {
+		return nil, pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return &token, pkg.CustomError{}
+}
+
+func NewAuthRepository(db *sqlx.DB) AuthRepository {
+	return &AuthRepositoryImpl{
+		DB: db,
+	}
+}
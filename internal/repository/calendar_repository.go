@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/rifkhia/lms-remake/internal/models"
+	"github.com/rifkhia/lms-remake/internal/pkg"
+	"github.com/rifkhia/lms-remake/internal/utils"
+)
+
+type CalendarRepositoryImpl struct {
+	DB *sqlx.DB
+}
+
+// SetCalendarFeed gives the user a feed token, replacing the old one.
+func (r *CalendarRepositoryImpl) SetCalendarFeed(c context.Context, userId uuid.UUID, role string, tokenHash string) pkg.CustomError {
+	_, err := r.DB.ExecContext(c, "INSERT INTO calendar_feeds(user_id, role, token_hash, created_at) VALUES($1, $2, $3, now()) ON CONFLICT (user_id, role) DO UPDATE SET token_hash = EXCLUDED.token_hash, last_used_at = NULL, created_at = now()", userId, role, tokenHash)
+	if err != nil {
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return pkg.CustomError{}
+}
+
+func (r *CalendarRepositoryImpl) DeleteCalendarFeed(c context.Context, userId uuid.UUID, role string) pkg.CustomError {
+	_, err := r.DB.ExecContext(c, "DELETE FROM calendar_feeds WHERE user_id = $1 AND role = $2", userId, role)
+	if err != nil {
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return pkg.CustomError{}
+}
+
+// UseCalendarFeed returns whose feed a token opens and records the use. The
+// feed of a suspended or deleted account doesn't open.
+func (r *CalendarRepositoryImpl) UseCalendarFeed(c context.Context, tokenHash string) (uuid.UUID, string, pkg.CustomError) {
+	var feeds []struct {
+		UserId uuid.UUID
+		Role   string
+	}
+
+	err := r.DB.SelectContext(c, &feeds, `UPDATE calendar_feeds f SET last_used_at = now() WHERE token_hash = $1 AND (
+		(role = $2 AND EXISTS (SELECT 1 FROM students s WHERE s.id = f.user_id AND s.suspended_at IS NULL AND s.deleted_at IS NULL)) OR
+		(role = $3 AND EXISTS (SELECT 1 FROM teachers t WHERE t.id = f.user_id AND t.suspended_at IS NULL AND t.deleted_at IS NULL)))
+		RETURNING user_id AS userid, role`, tokenHash, utils.STUDENT_ROLE, utils.TEACHER_ROLE)
+	if err != nil {
+		return uuid.Nil, "", pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	if len(feeds) == 0 {
+		return uuid.Nil, "", pkg.CustomError{
+			Code:    utils.UNAUTHORIZED,
+			Cause:   errors.New("calendar feed is invalid or was replaced"),
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return feeds[0].UserId, feeds[0].Role, pkg.CustomError{}
+}
+
+// GetStudentDeadlines returns the assignments with a deadline in the classes
+// the student is enrolled in.
+func (r *CalendarRepositoryImpl) GetStudentDeadlines(c context.Context, studentId uuid.UUID) ([]*models.Deadline, pkg.CustomError) {
+	return r.getDeadlines(c, `SELECT s.id AS submissionid, c.id AS classid, c.name AS classname, s.title, COALESCE(s.description, '') AS description, s.deadline
+		FROM student_class sc JOIN classes c ON c.id = sc.class_id JOIN class_sections cs ON cs.class_id = c.id JOIN submissions s ON s.class_section_id = cs.id
+		WHERE sc.student_id = $1 AND sc.deleted_at IS NULL AND c.deleted_at IS NULL AND cs.deleted_at IS NULL AND s.deleted_at IS NULL AND s.deadline IS NOT NULL
+		ORDER BY s.deadline, s.id`, studentId)
+}
+
+// GetTeacherDeadlines returns the assignments with a deadline in the classes
+// the teacher is on the staff of.
+func (r *CalendarRepositoryImpl) GetTeacherDeadlines(c context.Context, teacherId uuid.UUID) ([]*models.Deadline, pkg.CustomError) {
+	return r.getDeadlines(c, `SELECT s.id AS submissionid, c.id AS classid, c.name AS classname, s.title, COALESCE(s.description, '') AS description, s.deadline
+		FROM class_staff st JOIN classes c ON c.id = st.class_id JOIN class_sections cs ON cs.class_id = c.id JOIN submissions s ON s.class_section_id = cs.id
+		WHERE st.teacher_id = $1 AND st.accepted_at IS NOT NULL AND c.deleted_at IS NULL AND cs.deleted_at IS NULL AND s.deleted_at IS NULL AND s.deadline IS NOT NULL
+		ORDER BY s.deadline, s.id`, teacherId)
+}
+
+func (r *CalendarRepositoryImpl) getDeadlines(c context.Context, query string, userId uuid.UUID) ([]*models.Deadline, pkg.CustomError) {
+	deadlines := []*models.Deadline{}
+
+	err := r.DB.SelectContext(c, &deadlines, query, userId)
+	if err != nil {
+		return nil, pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return deadlines, pkg.CustomError{}
+}
+
+func NewCalendarRepository(db *sqlx.DB) CalendarRepository {
+	return &CalendarRepositoryImpl{
+		DB: db,
+	}
+}
@@ -9,6 +9,8 @@ import (
 	"github.com/rifkhia/lms-remake/internal/models"
 	"github.com/rifkhia/lms-remake/internal/pkg"
 	"github.com/rifkhia/lms-remake/internal/utils"
+	"strings"
+	"time"
 )
 
 type ClassRepositoryImpl struct {
@@ -31,7 +33,7 @@ func (r *ClassRepositoryImpl) CheckStudentClassExists(c context.Context, classId
 
 func (r *ClassRepositoryImpl) CheckTeacherClassExists(c context.Context, teacherId uuid.UUID, classId int) (bool, pkg.CustomError) {
 	var count int
-	err := r.DB.Get(&count, "SELECT COUNT(*) FROM classes WHERE teacher_id = $1 AND id = $2 AND deleted_at IS NULL", teacherId, classId)
+	err := r.DB.Get(&count, "SELECT COUNT(*) FROM class_staff WHERE teacher_id = $1 AND class_id = $2 AND accepted_at IS NOT NULL", teacherId, classId)
 	if err != nil {
 		return false, pkg.CustomError{
 			Cause:   err,
@@ -46,7 +48,7 @@ func (r *ClassRepositoryImpl) CheckTeacherClassExists(c context.Context, teacher
 func (r *ClassRepositoryImpl) GetClassByID(c context.Context, id int) (*models.Class, pkg.CustomError) {
 	var class models.Class
 
-	rows, err := r.DB.QueryxContext(c, "SELECT id, name, description, key, teacher_id AS teacherid, day, start_time as starttime, end_time as endtime FROM classes WHERE id = $1 AND deleted_at IS NULL", id)
+	rows, err := r.DB.QueryxContext(c, "SELECT id, name, description, key, teacher_id AS teacherid, term_id AS termid, enrollment_mode AS enrollmentmode, capacity, key_expires_at AS keyexpiresat, archived_at AS archivedat FROM classes WHERE id = $1 AND deleted_at IS NULL", id)
 	if err != nil {
 		return nil, pkg.CustomError{
 			Cause:   err,
@@ -127,10 +129,11 @@ func (r *ClassRepositoryImpl) GetClassByTeacherID(c context.Context, teacherId i
 	return classes, pkg.CustomError{}
 }
 
-func (r *ClassRepositoryImpl) GetClassByName(c context.Context, name string) ([]*models.Class, pkg.CustomError) {
+// GetClassByName only returns classes offered in termId, unless it is nil.
+func (r *ClassRepositoryImpl) GetClassByName(c context.Context, name string, termId *int) ([]*models.Class, pkg.CustomError) {
 	var classes []*models.Class
 
-	rows, err := r.DB.QueryxContext(c, "SELECT id, name, description, key, teacher_id AS teacherid, day, start_time as starttime, end_time as endtime FROM classes WHERE name LIKE '%'||$1||'%' AND deleted_at IS NULL", name)
+	rows, err := r.DB.QueryxContext(c, "SELECT id, name, description, key, teacher_id AS teacherid, term_id AS termid FROM classes WHERE name LIKE '%'||$1||'%' AND ($2::int IS NULL OR term_id = $2) AND deleted_at IS NULL", name, termId)
 	if err != nil {
 		return nil, pkg.CustomError{
 			Cause:   err,
@@ -168,8 +171,86 @@ func (r *ClassRepositoryImpl) GetClassByName(c context.Context, name string) ([]
 	return classes, pkg.CustomError{}
 }
 
+// CreateClass also makes the creating teacher the owner in class_staff, and
+// stores the class's meetings.
 func (r *ClassRepositoryImpl) CreateClass(c context.Context, class *models.Class) pkg.CustomError {
-	_, err := r.DB.NamedExecContext(c, "INSERT INTO classes VALUES(DEFAULT, :name, :description, :key, :teacherid, now(), now(), null, :day, :starttime, :endtime)", class)
+	tx, err := r.DB.BeginTxx(c, nil)
+	if err != nil {
+		return pkg.CustomError{
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+			Code:    utils.INTERNAL_SERVER_ERROR,
+		}
+	}
+
+	defer tx.Rollback()
+
+	err = insertClass(c, tx, class)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		return pkg.CustomError{
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+			Code:    utils.INTERNAL_SERVER_ERROR,
+		}
+	}
+
+	return pkg.CustomError{}
+}
+
+func insertClass(c context.Context, tx *sqlx.Tx, class *models.Class) error {
+	query, args, err := tx.BindNamed("INSERT INTO classes(name, description, key, teacher_id, term_id, enrollment_mode, capacity, created_at, updated_at) VALUES(:name, :description, :key, :teacherid, :termid, COALESCE(NULLIF(:enrollmentmode, ''), 'open'), :capacity, now(), now()) RETURNING id", class)
+	if err == nil {
+		err = tx.QueryRowxContext(c, query, args...).Scan(&class.ID)
+	}
+	if err == nil {
+		_, err = tx.ExecContext(c, "INSERT INTO class_staff(class_id, teacher_id, role, accepted_at, created_at) VALUES($1, $2, $3, now(), now())", class.ID, class.TeacherId, utils.CLASS_STAFF_OWNER)
+	}
+	if err == nil {
+		err = insertClassMeetings(c, tx, class.ID, class.Meetings)
+	}
+
+	return err
+}
+
+// CloneClass creates class as a copy of the sections, materials and
+// assignments of sourceId. Assignment deadlines move by deadlineShiftDays, or
+// are cleared when it is nil. Student work is not copied.
+func (r *ClassRepositoryImpl) CloneClass(c context.Context, sourceId int, class *models.Class, deadlineShiftDays *int) pkg.CustomError {
+	tx, err := r.DB.BeginTxx(c, nil)
+	if err != nil {
+		return pkg.CustomError{
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+			Code:    utils.INTERNAL_SERVER_ERROR,
+		}
+	}
+
+	defer tx.Rollback()
+
+	var sectionIds []int
+	err = insertClass(c, tx, class)
+	if err == nil {
+		err = tx.SelectContext(c, &sectionIds, "SELECT id FROM class_sections WHERE class_id = $1 AND deleted_at IS NULL ORDER BY \"order\"", sourceId)
+	}
+	for _, sectionId := range sectionIds {
+		var newSectionId int
+		err = tx.QueryRowxContext(c, "INSERT INTO class_sections(title, description, \"order\", class_id, created_at, updated_at) SELECT title, description, \"order\", $2, now(), now() FROM class_sections WHERE id = $1 RETURNING id", sectionId, class.ID).Scan(&newSectionId)
+		if err == nil {
+			_, err = tx.ExecContext(c, "INSERT INTO materials(title, description, file, class_section_id, created_at, updated_at) SELECT title, description, file, $2, now(), now() FROM materials WHERE class_section_id = $1 AND deleted_at IS NULL ORDER BY id", sectionId, newSectionId)
+		}
+		if err == nil {
+			_, err = tx.ExecContext(c, "INSERT INTO submissions(title, description, file, deadline, class_section_id, created_at, updated_at) SELECT title, description, file, deadline + make_interval(days => $3), $2, now(), now() FROM submissions WHERE class_section_id = $1 AND deleted_at IS NULL ORDER BY id", sectionId, newSectionId, deadlineShiftDays)
+		}
+		if err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
 	if err != nil {
 		return pkg.CustomError{
 			Cause:   err,
@@ -199,11 +280,67 @@ func (r *ClassRepositoryImpl) JoinClass(c context.Context, classId int, studentI
 }
 
 func (r *ClassRepositoryImpl) LeftCLass(c context.Context, classId int, studentId uuid.UUID) pkg.CustomError {
-	tempMap := map[string]interface{}{
-		"classId":   classId,
-		"studentId": studentId,
+	_, err := r.DB.ExecContext(c, "UPDATE student_class SET deleted_at = now() WHERE student_id = $1 AND class_id = $2 AND deleted_at IS NULL", studentId, classId)
+	if err != nil {
+		return pkg.CustomError{
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+			Code:    utils.INTERNAL_SERVER_ERROR,
+		}
+	}
+
+	return pkg.CustomError{}
+}
+
+// UpdateClass leaves the meetings alone when meetings is nil, and replaces
+// them otherwise.
+func (r *ClassRepositoryImpl) UpdateClass(c context.Context, classId int, request *dto.UpdateClassRequest, meetings []*models.ClassMeeting) pkg.CustomError {
+	tx, err := r.DB.BeginTxx(c, nil)
+	if err != nil {
+		return pkg.CustomError{
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+			Code:    utils.INTERNAL_SERVER_ERROR,
+		}
+	}
+
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(c, "UPDATE classes SET name = COALESCE($1, name), description = COALESCE($2, description), term_id = COALESCE($3, term_id), updated_at = now() WHERE id = $4 AND deleted_at IS NULL", request.Name, request.Description, request.TermId, classId)
+	if err != nil {
+		return pkg.CustomError{
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+			Code:    utils.INTERNAL_SERVER_ERROR,
+		}
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return pkg.CustomError{
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+			Code:    utils.INTERNAL_SERVER_ERROR,
+		}
+	}
+
+	if affected == 0 {
+		return pkg.CustomError{
+			Cause:   errors.New("no class with that id"),
+			Service: utils.REPOSITORY_SERVICE,
+			Code:    utils.BAD_REQUEST,
+		}
+	}
+
+	if meetings != nil {
+		_, err = tx.ExecContext(c, "DELETE FROM class_meetings WHERE class_id = $1", classId)
+		if err == nil {
+			err = insertClassMeetings(c, tx, classId, meetings)
+		}
+	}
+	if err == nil {
+		err = tx.Commit()
 	}
-	_, err := r.DB.NamedExecContext(c, "UPDATE student_class SET deleted_at = now() WHERE student_id = :studentid AND class_id = :classid", tempMap)
 	if err != nil {
 		return pkg.CustomError{
 			Cause:   err,
@@ -215,8 +352,139 @@ func (r *ClassRepositoryImpl) LeftCLass(c context.Context, classId int, studentI
 	return pkg.CustomError{}
 }
 
+func insertClassMeetings(c context.Context, tx *sqlx.Tx, classId int, meetings []*models.ClassMeeting) error {
+	for _, meeting := range meetings {
+		err := tx.QueryRowxContext(c, "INSERT INTO class_meetings(class_id, weekday, start_time, end_time, room, timezone, created_at) VALUES($1, $2, $3, $4, $5, $6, now()) RETURNING id", classId, meeting.Weekday, meeting.StartTime, meeting.EndTime, meeting.Room, meeting.Timezone).Scan(&meeting.ID)
+		if err != nil {
+			return err
+		}
+		meeting.ClassId = classId
+	}
+
+	return nil
+}
+
+// GetTeacherSchedule returns the meetings of the live classes the teacher is
+// on the staff of, only those offered in termId unless it is nil.
+func (r *ClassRepositoryImpl) GetTeacherSchedule(c context.Context, teacherId uuid.UUID, termId *int) ([]*models.ScheduleEntry, pkg.CustomError) {
+	schedule := []*models.ScheduleEntry{}
+
+	err := r.DB.SelectContext(c, &schedule, `SELECT c.name, c.term_id AS termid, m.id, m.class_id AS classid, m.weekday, m.start_time AS starttime, m.end_time AS endtime, m.room, m.timezone
+		FROM class_staff cs JOIN classes c ON c.id = cs.class_id JOIN class_meetings m ON m.class_id = c.id
+		WHERE cs.teacher_id = $1 AND cs.accepted_at IS NOT NULL AND c.deleted_at IS NULL AND c.archived_at IS NULL AND ($2::int IS NULL OR c.term_id = $2)
+		ORDER BY m.weekday, m.start_time, c.name`, teacherId, termId)
+	if err != nil {
+		return nil, pkg.CustomError{
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+			Code:    utils.INTERNAL_SERVER_ERROR,
+		}
+	}
+
+	return schedule, pkg.CustomError{}
+}
+
+// GetClassMeetings returns the meetings of all the given classes, sorted by
+// weekday and start time.
+func (r *ClassRepositoryImpl) GetClassMeetings(c context.Context, classIds []int) ([]*models.ClassMeeting, pkg.CustomError) {
+	meetings := []*models.ClassMeeting{}
+	if len(classIds) == 0 {
+		return meetings, pkg.CustomError{}
+	}
+
+	query, args, err := sqlx.In("SELECT id, class_id AS classid, weekday, start_time AS starttime, end_time AS endtime, room, timezone FROM class_meetings WHERE class_id IN (?) ORDER BY weekday, start_time, id", classIds)
+	if err == nil {
+		err = r.DB.SelectContext(c, &meetings, r.DB.Rebind(query), args...)
+	}
+	if err != nil {
+		return nil, pkg.CustomError{
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+			Code:    utils.INTERNAL_SERVER_ERROR,
+		}
+	}
+
+	return meetings, pkg.CustomError{}
+}
+
+func (r *ClassRepositoryImpl) ArchiveClass(c context.Context, classId int) pkg.CustomError {
+	return r.execOrNotFound(c, "class is already archived", "UPDATE classes SET archived_at = now(), updated_at = now() WHERE id = $1 AND deleted_at IS NULL AND archived_at IS NULL", classId)
+}
+
+func (r *ClassRepositoryImpl) UnarchiveClass(c context.Context, classId int) pkg.CustomError {
+	return r.execOrNotFound(c, "class is not archived", "UPDATE classes SET archived_at = NULL, updated_at = now() WHERE id = $1 AND deleted_at IS NULL AND archived_at IS NOT NULL", classId)
+}
+
+// classCascade is what deleting a class takes with it: enrollments end, and
+// sections and their assignments are hidden. Student submissions hang off the
+// sections and are kept as they are. Every row gets the class's deleted_at,
+// so a restore brings back exactly these rows.
+var classCascade = []string{
+	"UPDATE student_class SET deleted_at = $2 WHERE class_id = $1 AND deleted_at IS NULL",
+	"UPDATE submissions SET deleted_at = $2 WHERE class_section_id IN (SELECT id FROM class_sections WHERE class_id = $1) AND deleted_at IS NULL",
+	"UPDATE class_sections SET deleted_at = $2 WHERE class_id = $1 AND deleted_at IS NULL",
+}
+
+var classRestoreCascade = []string{
+	"UPDATE student_class SET deleted_at = NULL WHERE class_id = $1 AND deleted_at = $2 AND student_id IN (SELECT id FROM students WHERE deleted_at IS NULL)",
+	"UPDATE class_sections SET deleted_at = NULL WHERE class_id = $1 AND deleted_at = $2",
+	"UPDATE submissions SET deleted_at = NULL WHERE class_section_id IN (SELECT id FROM class_sections WHERE class_id = $1) AND deleted_at = $2",
+}
+
 func (r *ClassRepositoryImpl) DeleteClass(c context.Context, id int) pkg.CustomError {
-	_, err := r.DB.NamedExecContext(c, "UPDATE classes SET deleted_at = now() WHERE id = ?", id)
+	return r.cascadeClass(c, id, "no class with that id", "UPDATE classes SET deleted_at = now(), updated_at = now() WHERE id = $1 AND deleted_at IS NULL RETURNING deleted_at", []interface{}{id}, classCascade)
+}
+
+// RestoreClass undoes a delete made less than retentionDays ago.
+func (r *ClassRepositoryImpl) RestoreClass(c context.Context, id int, retentionDays int) pkg.CustomError {
+	return r.cascadeClass(c, id, "no class deleted with that id in the restore window", `WITH deleted AS (SELECT id, deleted_at FROM classes WHERE id = $1 AND deleted_at > now() - make_interval(days => $2) FOR UPDATE)
+		UPDATE classes SET deleted_at = NULL, updated_at = now() FROM deleted WHERE classes.id = deleted.id RETURNING deleted.deleted_at`, []interface{}{id, retentionDays}, classRestoreCascade)
+}
+
+// cascadeClass runs query, which changes the class and returns its deletion
+// time, then the cascade statements with that time, all in one transaction.
+func (r *ClassRepositoryImpl) cascadeClass(c context.Context, id int, notFound string, query string, args []interface{}, cascade []string) pkg.CustomError {
+	tx, err := r.DB.BeginTxx(c, nil)
+	if err != nil {
+		return pkg.CustomError{
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+			Code:    utils.INTERNAL_SERVER_ERROR,
+		}
+	}
+
+	defer tx.Rollback()
+
+	var deletedAt []time.Time
+	err = tx.SelectContext(c, &deletedAt, query, args...)
+	if err != nil {
+		return pkg.CustomError{
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+			Code:    utils.INTERNAL_SERVER_ERROR,
+		}
+	}
+
+	if len(deletedAt) == 0 {
+		return pkg.CustomError{
+			Cause:   errors.New(notFound),
+			Service: utils.REPOSITORY_SERVICE,
+			Code:    utils.BAD_REQUEST,
+		}
+	}
+
+	for _, statement := range cascade {
+		_, err = tx.ExecContext(c, statement, id, deletedAt[0])
+		if err != nil {
+			return pkg.CustomError{
+				Cause:   err,
+				Service: utils.REPOSITORY_SERVICE,
+				Code:    utils.INTERNAL_SERVER_ERROR,
+			}
+		}
+	}
+
+	err = tx.Commit()
 	if err != nil {
 		return pkg.CustomError{
 			Cause:   err,
@@ -380,6 +648,477 @@ func (r *ClassRepositoryImpl) GetSubmissionByClassSection(c context.Context, cla
 	return results, pkg.CustomError{}
 }
 
+// UpdateClassTeacher hands the class over to another teacher, who becomes its
+// owner in class_staff in place of the previous one.
+func (r *ClassRepositoryImpl) UpdateClassTeacher(c context.Context, classId int, teacherId uuid.UUID) pkg.CustomError {
+	tx, err := r.DB.BeginTxx(c, nil)
+	if err != nil {
+		return pkg.CustomError{
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+			Code:    utils.INTERNAL_SERVER_ERROR,
+		}
+	}
+
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(c, "UPDATE classes SET teacher_id = $1, updated_at = now() WHERE id = $2 AND deleted_at IS NULL", teacherId, classId)
+	if err != nil {
+		return pkg.CustomError{
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+			Code:    utils.INTERNAL_SERVER_ERROR,
+		}
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return pkg.CustomError{
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+			Code:    utils.INTERNAL_SERVER_ERROR,
+		}
+	}
+
+	if affected == 0 {
+		return pkg.CustomError{
+			Cause:   errors.New("no class with that id"),
+			Service: utils.REPOSITORY_SERVICE,
+			Code:    utils.BAD_REQUEST,
+		}
+	}
+
+	_, err = tx.ExecContext(c, "DELETE FROM class_staff WHERE class_id = $1 AND role = $2", classId, utils.CLASS_STAFF_OWNER)
+	if err == nil {
+		_, err = tx.ExecContext(c, "INSERT INTO class_staff(class_id, teacher_id, role, accepted_at, created_at) VALUES($1, $2, $3, now(), now()) ON CONFLICT (class_id, teacher_id) DO UPDATE SET role = EXCLUDED.role, accepted_at = now()", classId, teacherId, utils.CLASS_STAFF_OWNER)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		return pkg.CustomError{
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+			Code:    utils.INTERNAL_SERVER_ERROR,
+		}
+	}
+
+	return pkg.CustomError{}
+}
+
+// GetClassStaffRole returns the teacher's role in the class, or "" when the
+// teacher is not staff or has not accepted the invitation yet.
+func (r *ClassRepositoryImpl) GetClassStaffRole(c context.Context, classId int, teacherId uuid.UUID) (string, pkg.CustomError) {
+	var roles []string
+	err := r.DB.SelectContext(c, &roles, "SELECT role FROM class_staff WHERE class_id = $1 AND teacher_id = $2 AND accepted_at IS NOT NULL", classId, teacherId)
+	if err != nil {
+		return "", pkg.CustomError{
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+			Code:    utils.INTERNAL_SERVER_ERROR,
+		}
+	}
+
+	if len(roles) == 0 {
+		return "", pkg.CustomError{}
+	}
+
+	return roles[0], pkg.CustomError{}
+}
+
+func (r *ClassRepositoryImpl) GetClassStaff(c context.Context, classId int) ([]*models.ClassStaff, pkg.CustomError) {
+	staff := []*models.ClassStaff{}
+	err := r.DB.SelectContext(c, &staff, "SELECT cs.class_id AS classid, cs.teacher_id AS teacherid, t.name, t.email, cs.role, cs.invited_by AS invitedby, cs.accepted_at AS acceptedat FROM class_staff cs JOIN teachers t ON t.id = cs.teacher_id WHERE cs.class_id = $1 ORDER BY cs.created_at", classId)
+	if err != nil {
+		return nil, pkg.CustomError{
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+			Code:    utils.INTERNAL_SERVER_ERROR,
+		}
+	}
+
+	return staff, pkg.CustomError{}
+}
+
+func (r *ClassRepositoryImpl) InviteClassStaff(c context.Context, staff *models.ClassStaff) pkg.CustomError {
+	result, err := r.DB.ExecContext(c, "INSERT INTO class_staff(class_id, teacher_id, role, invited_by, created_at) VALUES($1, $2, $3, $4, now()) ON CONFLICT (class_id, teacher_id) DO NOTHING", staff.ClassId, staff.TeacherId, staff.Role, staff.InvitedBy)
+	if err != nil {
+		return pkg.CustomError{
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+			Code:    utils.INTERNAL_SERVER_ERROR,
+		}
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return pkg.CustomError{
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+			Code:    utils.INTERNAL_SERVER_ERROR,
+		}
+	}
+
+	if affected == 0 {
+		return pkg.CustomError{
+			Cause:   errors.New("teacher is already invited to this class"),
+			Service: utils.REPOSITORY_SERVICE,
+			Code:    utils.BAD_REQUEST,
+		}
+	}
+
+	return pkg.CustomError{}
+}
+
+func (r *ClassRepositoryImpl) AcceptClassStaffInvite(c context.Context, classId int, teacherId uuid.UUID) pkg.CustomError {
+	return r.execOrNotFound(c, "no pending invitation for this class", "UPDATE class_staff SET accepted_at = now() WHERE class_id = $1 AND teacher_id = $2 AND accepted_at IS NULL", classId, teacherId)
+}
+
+// RemoveClassStaff never removes the owner, a class always keeps one.
+func (r *ClassRepositoryImpl) RemoveClassStaff(c context.Context, classId int, teacherId uuid.UUID) pkg.CustomError {
+	return r.execOrNotFound(c, "teacher is not removable staff of this class", "DELETE FROM class_staff WHERE class_id = $1 AND teacher_id = $2 AND role <> $3", classId, teacherId, utils.CLASS_STAFF_OWNER)
+}
+
+func (r *ClassRepositoryImpl) execOrNotFound(c context.Context, notFound string, query string, args ...interface{}) pkg.CustomError {
+	result, err := r.DB.ExecContext(c, query, args...)
+	if err != nil {
+		return pkg.CustomError{
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+			Code:    utils.INTERNAL_SERVER_ERROR,
+		}
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return pkg.CustomError{
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+			Code:    utils.INTERNAL_SERVER_ERROR,
+		}
+	}
+
+	if affected == 0 {
+		return pkg.CustomError{
+			Cause:   errors.New(notFound),
+			Service: utils.REPOSITORY_SERVICE,
+			Code:    utils.BAD_REQUEST,
+		}
+	}
+
+	return pkg.CustomError{}
+}
+
+func (r *ClassRepositoryImpl) UpdateClassEnrollment(c context.Context, classId int, mode string, capacity *int) pkg.CustomError {
+	_, err := r.DB.ExecContext(c, "UPDATE classes SET enrollment_mode = $1, capacity = $2, updated_at = now() WHERE id = $3 AND deleted_at IS NULL", mode, capacity, classId)
+	if err != nil {
+		return pkg.CustomError{
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+			Code:    utils.INTERNAL_SERVER_ERROR,
+		}
+	}
+
+	return pkg.CustomError{}
+}
+
+func (r *ClassRepositoryImpl) CreateEnrollmentRequest(c context.Context, classId int, studentId uuid.UUID, status string) pkg.CustomError {
+	_, err := r.DB.ExecContext(c, "INSERT INTO enrollment_requests(class_id, student_id, status, created_at) VALUES($1, $2, $3, now())", classId, studentId, status)
+	if err != nil {
+		if strings.Contains(err.Error(), "violates unique constraint") {
+			return pkg.CustomError{
+				Cause:   errors.New("you already asked to join this class"),
+				Service: utils.REPOSITORY_SERVICE,
+				Code:    utils.BAD_REQUEST,
+			}
+		}
+		return pkg.CustomError{
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+			Code:    utils.INTERNAL_SERVER_ERROR,
+		}
+	}
+
+	return pkg.CustomError{}
+}
+
+// GetEnrollmentRequests lists a class's requests with the given statuses, the
+// waitlist in the order seats will be handed out.
+func (r *ClassRepositoryImpl) GetEnrollmentRequests(c context.Context, classId int, statuses []string) ([]*models.EnrollmentRequest, pkg.CustomError) {
+	requests := []*models.EnrollmentRequest{}
+
+	query, args, err := sqlx.In(`SELECT er.id, er.class_id AS classid, er.student_id AS studentid, s.name, s.email, er.status,
+		CASE WHEN er.status = ? THEN ROW_NUMBER() OVER (PARTITION BY er.status ORDER BY er.created_at, er.id) END AS position,
+		er.decided_by AS decidedby, er.decided_at AS decidedat, er.created_at AS createdat
+		FROM enrollment_requests er JOIN students s ON s.id = er.student_id
+		WHERE er.class_id = ? AND er.status IN (?) ORDER BY er.created_at, er.id`, utils.ENROLLMENT_WAITLISTED, classId, statuses)
+	if err == nil {
+		err = r.DB.SelectContext(c, &requests, r.DB.Rebind(query), args...)
+	}
+	if err != nil {
+		return nil, pkg.CustomError{
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+			Code:    utils.INTERNAL_SERVER_ERROR,
+		}
+	}
+
+	return requests, pkg.CustomError{}
+}
+
+func (r *ClassRepositoryImpl) GetEnrollmentRequest(c context.Context, classId int, id int) (*models.EnrollmentRequest, pkg.CustomError) {
+	var requests []*models.EnrollmentRequest
+
+	err := r.DB.SelectContext(c, &requests, "SELECT er.id, er.class_id AS classid, er.student_id AS studentid, s.name, s.email, er.status, er.decided_by AS decidedby, er.decided_at AS decidedat, er.created_at AS createdat FROM enrollment_requests er JOIN students s ON s.id = er.student_id WHERE er.id = $1 AND er.class_id = $2", id, classId)
+	if err != nil {
+		return nil, pkg.CustomError{
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+			Code:    utils.INTERNAL_SERVER_ERROR,
+		}
+	}
+
+	if len(requests) == 0 {
+		return nil, pkg.CustomError{
+			Cause:   errors.New("no enrollment request with that id"),
+			Service: utils.REPOSITORY_SERVICE,
+			Code:    utils.BAD_REQUEST,
+		}
+	}
+
+	return requests[0], pkg.CustomError{}
+}
+
+// CloseEnrollmentRequest ends the student's open request for the class, by a
+// teacher rejecting it or by the student withdrawing.
+func (r *ClassRepositoryImpl) CloseEnrollmentRequest(c context.Context, classId int, studentId uuid.UUID, status string, decidedBy *uuid.UUID) pkg.CustomError {
+	return r.execOrNotFound(c, "no open enrollment request for this class", "UPDATE enrollment_requests SET status = $1, decided_by = $2, decided_at = now() WHERE class_id = $3 AND student_id = $4 AND status IN ($5, $6)", status, decidedBy, classId, studentId, utils.ENROLLMENT_PENDING, utils.ENROLLMENT_WAITLISTED)
+}
+
+var errAlreadyEnrolled = errors.New("student already joined this class")
+var errInvalidInvite = errors.New("invite is invalid, expired or used up")
+
+// AdmitStudent enrolls the student when the class has a free seat and puts
+// them on the waitlist otherwise, closing any pending request they have. The
+// class row is locked so that concurrent joins can't overfill it.
+func (r *ClassRepositoryImpl) AdmitStudent(c context.Context, classId int, studentId uuid.UUID, decidedBy *uuid.UUID) (string, pkg.CustomError) {
+	return r.admitStudent(c, classId, studentId, decidedBy, "")
+}
+
+// AdmitInvitedStudent is AdmitStudent for a student with an invite, which must
+// be valid. The invite is only used up when the student gets a seat, in the
+// same transaction, so a failed or waitlisted join leaves it as it was.
+func (r *ClassRepositoryImpl) AdmitInvitedStudent(c context.Context, classId int, studentId uuid.UUID, codeHash string) (string, pkg.CustomError) {
+	return r.admitStudent(c, classId, studentId, nil, codeHash)
+}
+
+func (r *ClassRepositoryImpl) admitStudent(c context.Context, classId int, studentId uuid.UUID, decidedBy *uuid.UUID, inviteCodeHash string) (string, pkg.CustomError) {
+	status := utils.ENROLLMENT_ENROLLED
+
+	err := r.inClassLock(c, classId, func(tx *sqlx.Tx, seats *int) error {
+		// checked again under the lock, as two joins of the same student may
+		// both have passed the check before it
+		var enrolled bool
+		err := tx.GetContext(c, &enrolled, "SELECT EXISTS(SELECT 1 FROM student_class WHERE class_id = $1 AND student_id = $2 AND deleted_at IS NULL)", classId, studentId)
+		if err != nil {
+			return err
+		}
+		if enrolled {
+			return errAlreadyEnrolled
+		}
+
+		var inviteIds []int
+		if inviteCodeHash != "" {
+			err = tx.SelectContext(c, &inviteIds, "SELECT id FROM class_invites WHERE class_id = $1 AND code_hash = $2 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > now()) AND (max_uses IS NULL OR uses < max_uses) FOR UPDATE", classId, inviteCodeHash)
+			if err != nil {
+				return err
+			}
+			if len(inviteIds) == 0 {
+				return errInvalidInvite
+			}
+		}
+
+		if seats != nil && *seats <= 0 {
+			status = utils.ENROLLMENT_WAITLISTED
+			result, err := tx.ExecContext(c, "UPDATE enrollment_requests SET status = $1, decided_by = $2, decided_at = now() WHERE class_id = $3 AND student_id = $4 AND status = $5", utils.ENROLLMENT_WAITLISTED, decidedBy, classId, studentId, utils.ENROLLMENT_PENDING)
+			if err != nil {
+				return err
+			}
+			affected, err := result.RowsAffected()
+			if err != nil || affected > 0 {
+				return err
+			}
+			_, err = tx.ExecContext(c, "INSERT INTO enrollment_requests(class_id, student_id, status, created_at) VALUES($1, $2, $3, now())", classId, studentId, utils.ENROLLMENT_WAITLISTED)
+			return err
+		}
+
+		_, err = tx.ExecContext(c, "UPDATE enrollment_requests SET status = $1, decided_by = $2, decided_at = now() WHERE class_id = $3 AND student_id = $4 AND status IN ($5, $6)", utils.ENROLLMENT_APPROVED, decidedBy, classId, studentId, utils.ENROLLMENT_PENDING, utils.ENROLLMENT_WAITLISTED)
+		if err == nil {
+			_, err = tx.ExecContext(c, "INSERT INTO student_class(student_id, class_id, created_at) VALUES($1, $2, now())", studentId, classId)
+		}
+		if err == nil && len(inviteIds) > 0 {
+			_, err = tx.ExecContext(c, "UPDATE class_invites SET uses = uses + 1 WHERE id = $1", inviteIds[0])
+		}
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, errAlreadyEnrolled) || strings.Contains(err.Error(), "student_class_active_idx") {
+			return "", pkg.CustomError{
+				Cause:   errAlreadyEnrolled,
+				Service: utils.REPOSITORY_SERVICE,
+				Code:    utils.BAD_REQUEST,
+			}
+		}
+		if errors.Is(err, errInvalidInvite) {
+			return "", pkg.CustomError{
+				Cause:   errInvalidInvite,
+				Service: utils.REPOSITORY_SERVICE,
+				Code:    utils.BAD_REQUEST,
+			}
+		}
+		if strings.Contains(err.Error(), "violates unique constraint") {
+			return "", pkg.CustomError{
+				Cause:   errors.New("student is already waiting to join this class"),
+				Service: utils.REPOSITORY_SERVICE,
+				Code:    utils.BAD_REQUEST,
+			}
+		}
+		return "", pkg.CustomError{
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+			Code:    utils.INTERNAL_SERVER_ERROR,
+		}
+	}
+
+	return status, pkg.CustomError{}
+}
+
+// PromoteWaitlist fills the free seats of the class from its waitlist, first
+// come first served, and returns how many students got in. Students whose
+// account is deleted are passed over.
+func (r *ClassRepositoryImpl) PromoteWaitlist(c context.Context, classId int) (int, pkg.CustomError) {
+	promoted := 0
+
+	err := r.inClassLock(c, classId, func(tx *sqlx.Tx, seats *int) error {
+		if seats != nil && *seats <= 0 {
+			return nil
+		}
+
+		var waiting []struct {
+			ID        int
+			StudentId uuid.UUID `db:"studentid"`
+		}
+		err := tx.SelectContext(c, &waiting, "SELECT er.id, er.student_id AS studentid FROM enrollment_requests er JOIN students s ON s.id = er.student_id AND s.deleted_at IS NULL WHERE er.class_id = $1 AND er.status = $2 ORDER BY er.created_at, er.id LIMIT $3", classId, utils.ENROLLMENT_WAITLISTED, seats)
+		if err != nil {
+			return err
+		}
+
+		for _, request := range waiting {
+			_, err = tx.ExecContext(c, "UPDATE enrollment_requests SET status = $1, decided_at = now() WHERE id = $2", utils.ENROLLMENT_APPROVED, request.ID)
+			if err == nil {
+				_, err = tx.ExecContext(c, "INSERT INTO student_class(student_id, class_id, created_at) VALUES($1, $2, now())", request.StudentId, classId)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		promoted = len(waiting)
+		return nil
+	})
+	if err != nil {
+		return 0, pkg.CustomError{
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+			Code:    utils.INTERNAL_SERVER_ERROR,
+		}
+	}
+
+	return promoted, pkg.CustomError{}
+}
+
+// inClassLock runs fn in a transaction holding the class row, passing the
+// number of free seats, nil when the class has no capacity.
+func (r *ClassRepositoryImpl) inClassLock(c context.Context, classId int, fn func(tx *sqlx.Tx, seats *int) error) error {
+	tx, err := r.DB.BeginTxx(c, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	var capacity []*int
+	err = tx.SelectContext(c, &capacity, "SELECT capacity FROM classes WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", classId)
+	if err != nil {
+		return err
+	}
+	if len(capacity) == 0 {
+		return errors.New("no class with that id")
+	}
+
+	var seats *int
+	if capacity[0] != nil {
+		var enrolled int
+		err = tx.GetContext(c, &enrolled, "SELECT COUNT(*) FROM student_class WHERE class_id = $1 AND deleted_at IS NULL", classId)
+		if err != nil {
+			return err
+		}
+		free := *capacity[0] - enrolled
+		seats = &free
+	}
+
+	err = fn(tx, seats)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *ClassRepositoryImpl) UpdateClassKey(c context.Context, classId int, key string, expiresAt *time.Time) pkg.CustomError {
+	_, err := r.DB.ExecContext(c, "UPDATE classes SET key = $1, key_expires_at = $2, updated_at = now() WHERE id = $3 AND deleted_at IS NULL", key, expiresAt, classId)
+	if err != nil {
+		return pkg.CustomError{
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+			Code:    utils.INTERNAL_SERVER_ERROR,
+		}
+	}
+
+	return pkg.CustomError{}
+}
+
+func (r *ClassRepositoryImpl) CreateClassInvite(c context.Context, invite *models.ClassInvite) pkg.CustomError {
+	err := r.DB.QueryRowxContext(c, "INSERT INTO class_invites(class_id, code_hash, created_by, max_uses, uses, expires_at, created_at) VALUES($1, $2, $3, $4, 0, $5, now()) RETURNING id, created_at", invite.ClassId, invite.CodeHash, invite.CreatedBy, invite.MaxUses, invite.ExpiresAt).Scan(&invite.ID, &invite.CreatedAt)
+	if err != nil {
+		return pkg.CustomError{
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+			Code:    utils.INTERNAL_SERVER_ERROR,
+		}
+	}
+
+	return pkg.CustomError{}
+}
+
+func (r *ClassRepositoryImpl) GetClassInvites(c context.Context, classId int) ([]*models.ClassInvite, pkg.CustomError) {
+	invites := []*models.ClassInvite{}
+	err := r.DB.SelectContext(c, &invites, "SELECT id, class_id AS classid, created_by AS createdby, max_uses AS maxuses, uses, expires_at AS expiresat, revoked_at AS revokedat, created_at AS createdat FROM class_invites WHERE class_id = $1 ORDER BY created_at DESC", classId)
+	if err != nil {
+		return nil, pkg.CustomError{
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+			Code:    utils.INTERNAL_SERVER_ERROR,
+		}
+	}
+
+	return invites, pkg.CustomError{}
+}
+
+func (r *ClassRepositoryImpl) RevokeClassInvite(c context.Context, classId int, id int) pkg.CustomError {
+	return r.execOrNotFound(c, "no active invite with that id", "UPDATE class_invites SET revoked_at = now() WHERE id = $1 AND class_id = $2 AND revoked_at IS NULL", id, classId)
+}
+
 func NewClassRepository(db *sqlx.DB) ClassRepository {
 	return &ClassRepositoryImpl{
 		DB: db,
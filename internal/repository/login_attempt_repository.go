@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"github.com/jmoiron/sqlx"
+	"github.com/rifkhia/lms-remake/internal/models"
+	"github.com/rifkhia/lms-remake/internal/pkg"
+	"github.com/rifkhia/lms-remake/internal/utils"
+	"time"
+)
+
+type LoginAttemptRepositoryImpl struct {
+	DB *sqlx.DB
+}
+
+func (r *LoginAttemptRepositoryImpl) CreateLoginAttempt(c context.Context, attempt *models.LoginAttempt) pkg.CustomError {
+	_, err := r.DB.NamedExecContext(c, "INSERT INTO login_attempts(email, role, ip_address, success, reason, created_at) VALUES(:email, :role, :ipaddress, :success, :reason, now())", attempt)
+	if err != nil {
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return pkg.CustomError{}
+}
+
+// GetAccountFailures counts wrong passwords and two-factor codes on the account
+// since the later of since and its last successful login, along with the time
+// of the latest one.
+func (r *LoginAttemptRepositoryImpl) GetAccountFailures(c context.Context, email string, role string, since time.Time) (int, time.Time, pkg.CustomError) {
+	query := `SELECT count(*), max(created_at) FROM login_attempts
+		WHERE email = $1 AND role = $2 AND reason IN ($3, $4) AND created_at > $5
+		AND created_at > COALESCE((SELECT max(created_at) FROM login_attempts WHERE email = $1 AND role = $2 AND success), '-infinity')`
+
+	return r.countFailures(c, query, email, role, utils.LOGIN_FAILURE_INVALID_CREDENTIALS, utils.LOGIN_FAILURE_INVALID_MFA_CODE, since)
+}
+
+// GetIPFailures is not reset by a successful login, otherwise signing in to a
+// throwaway account would clear the counter for the whole address.
+func (r *LoginAttemptRepositoryImpl) GetIPFailures(c context.Context, ipAddress string, since time.Time) (int, time.Time, pkg.CustomError) {
+	query := "SELECT count(*), max(created_at) FROM login_attempts WHERE ip_address = $1 AND reason IN ($2, $3) AND created_at > $4"
+
+	return r.countFailures(c, query, ipAddress, utils.LOGIN_FAILURE_INVALID_CREDENTIALS, utils.LOGIN_FAILURE_INVALID_MFA_CODE, since)
+}
+
+func (r *LoginAttemptRepositoryImpl) countFailures(c context.Context, query string, args ...interface{}) (int, time.Time, pkg.CustomError) {
+	var count int
+	var lastFailure sql.NullTime
+
+	err := r.DB.QueryRowxContext(c, query, args...).Scan(&count, &lastFailure)
+	if err != nil {
+		return 0, time.Time{}, pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return count, lastFailure.Time, pkg.CustomError{}
+}
+
+func NewLoginAttemptRepository(db *sqlx.DB) LoginAttemptRepository {
+	return &LoginAttemptRepositoryImpl{
+		DB: db,
+	}
+}
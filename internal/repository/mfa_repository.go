@@ -0,0 +1,193 @@
+package repository
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/rifkhia/lms-remake/internal/models"
+	"github.com/rifkhia/lms-remake/internal/pkg"
+	"github.com/rifkhia/lms-remake/internal/utils"
+)
+
+type MFARepositoryImpl struct {
+	DB *sqlx.DB
+}
+
+// GetTOTPSecret returns nil when the user never started an enrollment.
+func (r *MFARepositoryImpl) GetTOTPSecret(c context.Context, userId uuid.UUID, role string) (*models.TOTPSecret, pkg.CustomError) {
+	secrets := []*models.TOTPSecret{}
+
+	err := r.DB.SelectContext(c, &secrets, "SELECT user_id AS userid, role, secret, confirmed_at AS confirmedat, last_used_step AS lastusedstep FROM totp_secrets WHERE user_id = $1 AND role = $2", userId, role)
+	if err != nil {
+		return nil, pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	if len(secrets) == 0 {
+		return nil, pkg.CustomError{}
+	}
+
+	return secrets[0], pkg.CustomError{}
+}
+
+// SaveTOTPSecret starts an enrollment, replacing one that was never confirmed.
+// A confirmed secret is left alone.
+func (r *MFARepositoryImpl) SaveTOTPSecret(c context.Context, userId uuid.UUID, role string, secret string) pkg.CustomError {
+	_, err := r.DB.ExecContext(c, `INSERT INTO totp_secrets(user_id, role, secret, created_at) VALUES($1, $2, $3, now())
+		ON CONFLICT (user_id, role) DO UPDATE SET secret = EXCLUDED.secret, last_used_step = NULL, created_at = now()
+		WHERE totp_secrets.confirmed_at IS NULL`, userId, role, secret)
+	if err != nil {
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return pkg.CustomError{}
+}
+
+// ConfirmTOTPSecret turns two-factor login on and stores the first recovery
+// codes in one transaction.
+func (r *MFARepositoryImpl) ConfirmTOTPSecret(c context.Context, userId uuid.UUID, role string, step int64, recoveryCodeHashes []string) pkg.CustomError {
+	return r.inTransaction(c, func(tx *sqlx.Tx) error {
+		_, err := tx.ExecContext(c, "UPDATE totp_secrets SET confirmed_at = now(), last_used_step = $3 WHERE user_id = $1 AND role = $2", userId, role, step)
+		if err != nil {
+			return err
+		}
+
+		return replaceRecoveryCodes(c, tx, userId, role, recoveryCodeHashes)
+	})
+}
+
+// UseTOTPStep records the step of an accepted code. It returns false when that
+// step, or a later one, was already used, so every code works only once.
+func (r *MFARepositoryImpl) UseTOTPStep(c context.Context, userId uuid.UUID, role string, step int64) (bool, pkg.CustomError) {
+	result, err := r.DB.ExecContext(c, "UPDATE totp_secrets SET last_used_step = $3 WHERE user_id = $1 AND role = $2 AND (last_used_step IS NULL OR last_used_step < $3)", userId, role, step)
+	if err != nil {
+		return false, pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return affected > 0, pkg.CustomError{}
+}
+
+func (r *MFARepositoryImpl) ReplaceRecoveryCodes(c context.Context, userId uuid.UUID, role string, codeHashes []string) pkg.CustomError {
+	return r.inTransaction(c, func(tx *sqlx.Tx) error {
+		return replaceRecoveryCodes(c, tx, userId, role, codeHashes)
+	})
+}
+
+func (r *MFARepositoryImpl) UseRecoveryCode(c context.Context, userId uuid.UUID, role string, codeHash string) (bool, pkg.CustomError) {
+	result, err := r.DB.ExecContext(c, "UPDATE mfa_recovery_codes SET used_at = now() WHERE user_id = $1 AND role = $2 AND code_hash = $3 AND used_at IS NULL", userId, role, codeHash)
+	if err != nil {
+		return false, pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return affected > 0, pkg.CustomError{}
+}
+
+func (r *MFARepositoryImpl) CountRecoveryCodes(c context.Context, userId uuid.UUID, role string) (int, pkg.CustomError) {
+	var count int
+
+	err := r.DB.GetContext(c, &count, "SELECT count(*) FROM mfa_recovery_codes WHERE user_id = $1 AND role = $2 AND used_at IS NULL", userId, role)
+	if err != nil {
+		return 0, pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return count, pkg.CustomError{}
+}
+
+// DeleteMFA turns two-factor login off and drops the recovery codes.
+func (r *MFARepositoryImpl) DeleteMFA(c context.Context, userId uuid.UUID, role string) pkg.CustomError {
+	return r.inTransaction(c, func(tx *sqlx.Tx) error {
+		_, err := tx.ExecContext(c, "DELETE FROM totp_secrets WHERE user_id = $1 AND role = $2", userId, role)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(c, "DELETE FROM mfa_recovery_codes WHERE user_id = $1 AND role = $2", userId, role)
+		return err
+	})
+}
+
+func replaceRecoveryCodes(c context.Context, tx *sqlx.Tx, userId uuid.UUID, role string, codeHashes []string) error {
+	_, err := tx.ExecContext(c, "DELETE FROM mfa_recovery_codes WHERE user_id = $1 AND role = $2", userId, role)
+	if err != nil {
+		return err
+	}
+
+	for _, codeHash := range codeHashes {
+		_, err = tx.ExecContext(c, "INSERT INTO mfa_recovery_codes(user_id, role, code_hash, created_at) VALUES($1, $2, $3, now())", userId, role, codeHash)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *MFARepositoryImpl) inTransaction(c context.Context, fn func(tx *sqlx.Tx) error) pkg.CustomError {
+	tx, err := r.DB.BeginTxx(c, nil)
+	if err != nil {
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	defer tx.Rollback()
+
+	err = fn(tx)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return pkg.CustomError{}
+}
+
+func NewMFARepository(db *sqlx.DB) MFARepository {
+	return &MFARepositoryImpl{
+		DB: db,
+	}
+}
@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/rifkhia/lms-remake/internal/models"
+	"github.com/rifkhia/lms-remake/internal/pkg"
+	"github.com/rifkhia/lms-remake/internal/utils"
+)
+
+type OIDCRepositoryImpl struct {
+	DB *sqlx.DB
+}
+
+func (r *OIDCRepositoryImpl) CreateLoginRequest(c context.Context, request *models.OIDCLoginRequest) pkg.CustomError {
+	_, err := r.DB.NamedExecContext(c, "INSERT INTO oidc_login_requests(state_hash, role, nonce, code_verifier, expires_at, created_at) VALUES(:statehash, :role, :nonce, :codeverifier, :expiresat, now())", request)
+	if err != nil {
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return pkg.CustomError{}
+}
+
+// UseLoginRequest consumes a pending login in a single statement so a state can
+// only ever be redeemed once.
+func (r *OIDCRepositoryImpl) UseLoginRequest(c context.Context, stateHash string) (*models.OIDCLoginRequest, pkg.CustomError) {
+	var request models.OIDCLoginRequest
+
+	rows, err := r.DB.QueryxContext(c, "UPDATE oidc_login_requests SET used_at = now() WHERE state_hash = $1 AND used_at IS NULL AND expires_at > now() RETURNING id, state_hash AS statehash, role, nonce, code_verifier AS codeverifier, expires_at AS expiresat", stateHash)
+	if err != nil {
+		return nil, pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, pkg.CustomError{
+				Code:    utils.INTERNAL_SERVER_ERROR,
+				Cause:   err,
+				Service: utils.REPOSITORY_SERVICE,
+			}
+		}
+		return nil, pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("login state is invalid or expired, please start again"),
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	err = rows.StructScan(&request)
+	if err != nil {
+		return nil, pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return &request, pkg.CustomError{}
+}
+
+// GetIdentityUserId returns uuid.Nil when the identity is not linked yet.
+func (r *OIDCRepositoryImpl) GetIdentityUserId(c context.Context, issuer string, subject string, role string) (uuid.UUID, pkg.CustomError) {
+	var userIds []uuid.UUID
+
+	err := r.DB.SelectContext(c, &userIds, "SELECT user_id FROM oidc_identities WHERE issuer = $1 AND subject = $2 AND role = $3", issuer, subject, role)
+	if err != nil {
+		return uuid.Nil, pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	if len(userIds) == 0 {
+		return uuid.Nil, pkg.CustomError{}
+	}
+
+	return userIds[0], pkg.CustomError{}
+}
+
+func (r *OIDCRepositoryImpl) CreateIdentity(c context.Context, identity *models.OIDCIdentity) pkg.CustomError {
+	_, err := r.DB.NamedExecContext(c, "INSERT INTO oidc_identities(issuer, subject, role, user_id, created_at) VALUES(:issuer, :subject, :role, :userid, now()) ON CONFLICT DO NOTHING", identity)
+	if err != nil {
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return pkg.CustomError{}
+}
+
+func NewOIDCRepository(db *sqlx.DB) OIDCRepository {
+	return &OIDCRepositoryImpl{
+		DB: db,
+	}
+}
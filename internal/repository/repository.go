@@ -6,27 +6,45 @@ import (
 	"github.com/rifkhia/lms-remake/internal/dto"
 	"github.com/rifkhia/lms-remake/internal/models"
 	"github.com/rifkhia/lms-remake/internal/pkg"
+	"time"
 )
 
 type StudentRepository interface {
 	GetStudentByID(c context.Context, id uuid.UUID) (*models.Student, pkg.CustomError)
 	GetStudentByEmail(c context.Context, email string) (*models.Student, pkg.CustomError)
+	GetStudentByNIM(c context.Context, nim int) (*models.Student, pkg.CustomError)
 	GetStudentByName(c context.Context, name string) ([]*models.Student, pkg.CustomError)
 	CreateStudent(c context.Context, student *models.Student) pkg.CustomError
-	DeleteStudent(c context.Context, id uuid.UUID) pkg.CustomError
+	ScheduleStudentDeletion(c context.Context, id uuid.UUID, scheduledAt time.Time) pkg.CustomError
+	CancelStudentDeletion(c context.Context, id uuid.UUID) pkg.CustomError
+	GetStudentsDueForDeletion(c context.Context) ([]uuid.UUID, pkg.CustomError)
+	AnonymizeStudent(c context.Context, id uuid.UUID) pkg.CustomError
+	GetStudentEnrollments(c context.Context, id uuid.UUID) ([]*models.StudentEnrollment, pkg.CustomError)
+	GetStudentSubmissions(c context.Context, id uuid.UUID) ([]*models.StudentSubmissionRecord, pkg.CustomError)
 	GetStudentByClassId(c context.Context, classId int) ([]*models.StudentClass, pkg.CustomError)
 	GetStudentProfile(c context.Context, id uuid.UUID) (*dto.StudentProfileRequest, pkg.CustomError)
 	AddStudentProfile(c context.Context, student *dto.StudentProfileRequest) pkg.CustomError
 	EditStudentProfile(c context.Context, student *dto.StudentProfileRequest) pkg.CustomError
-	FetchStudentClass(c context.Context, id uuid.UUID) ([]*models.StudentSchedule, pkg.CustomError)
+	FetchStudentClass(c context.Context, id uuid.UUID, termId *int) ([]*models.ScheduleEntry, pkg.CustomError)
+	VerifyStudent(c context.Context, id uuid.UUID) pkg.CustomError
+	UpdateStudentPassword(c context.Context, id uuid.UUID, password string) pkg.CustomError
+	UpdateStudentName(c context.Context, id uuid.UUID, name string) pkg.CustomError
+	UpdateStudentEmail(c context.Context, id uuid.UUID, email string) pkg.CustomError
 }
 
 type ClassRepository interface {
 	GetClassByID(c context.Context, id int) (*models.Class, pkg.CustomError)
 	GetClassByTeacherID(c context.Context, teacherId int) ([]*models.Class, pkg.CustomError)
-	GetClassByName(c context.Context, name string) ([]*models.Class, pkg.CustomError)
+	GetClassByName(c context.Context, name string, termId *int) ([]*models.Class, pkg.CustomError)
 	CreateClass(c context.Context, class *models.Class) pkg.CustomError
+	UpdateClass(c context.Context, classId int, request *dto.UpdateClassRequest, meetings []*models.ClassMeeting) pkg.CustomError
+	GetClassMeetings(c context.Context, classIds []int) ([]*models.ClassMeeting, pkg.CustomError)
+	GetTeacherSchedule(c context.Context, teacherId uuid.UUID, termId *int) ([]*models.ScheduleEntry, pkg.CustomError)
+	CloneClass(c context.Context, sourceId int, class *models.Class, deadlineShiftDays *int) pkg.CustomError
+	ArchiveClass(c context.Context, classId int) pkg.CustomError
+	UnarchiveClass(c context.Context, classId int) pkg.CustomError
 	DeleteClass(c context.Context, id int) pkg.CustomError
+	RestoreClass(c context.Context, id int, retentionDays int) pkg.CustomError
 	JoinClass(c context.Context, classId int, studentId uuid.UUID) pkg.CustomError
 	LeftCLass(c context.Context, classId int, studentId uuid.UUID) pkg.CustomError
 	CheckStudentClassExists(c context.Context, classId int, studentId uuid.UUID) (bool, pkg.CustomError)
@@ -38,10 +56,131 @@ type ClassRepository interface {
 	UpdateClassSection(c context.Context, classSection *models.SectionClass) pkg.CustomError
 	InsertSubmissionStudent(c context.Context, request *dto.StudentSubmissionRequest) pkg.CustomError
 	GetSubmissionByClassSection(c context.Context, classSecctionId int) ([]*models.StudentSubmission, pkg.CustomError)
+	UpdateClassTeacher(c context.Context, classId int, teacherId uuid.UUID) pkg.CustomError
+	GetClassStaffRole(c context.Context, classId int, teacherId uuid.UUID) (string, pkg.CustomError)
+	GetClassStaff(c context.Context, classId int) ([]*models.ClassStaff, pkg.CustomError)
+	InviteClassStaff(c context.Context, staff *models.ClassStaff) pkg.CustomError
+	AcceptClassStaffInvite(c context.Context, classId int, teacherId uuid.UUID) pkg.CustomError
+	RemoveClassStaff(c context.Context, classId int, teacherId uuid.UUID) pkg.CustomError
+	UpdateClassEnrollment(c context.Context, classId int, mode string, capacity *int) pkg.CustomError
+	CreateEnrollmentRequest(c context.Context, classId int, studentId uuid.UUID, status string) pkg.CustomError
+	GetEnrollmentRequests(c context.Context, classId int, statuses []string) ([]*models.EnrollmentRequest, pkg.CustomError)
+	GetEnrollmentRequest(c context.Context, classId int, id int) (*models.EnrollmentRequest, pkg.CustomError)
+	CloseEnrollmentRequest(c context.Context, classId int, studentId uuid.UUID, status string, decidedBy *uuid.UUID) pkg.CustomError
+	AdmitStudent(c context.Context, classId int, studentId uuid.UUID, decidedBy *uuid.UUID) (string, pkg.CustomError)
+	AdmitInvitedStudent(c context.Context, classId int, studentId uuid.UUID, codeHash string) (string, pkg.CustomError)
+	PromoteWaitlist(c context.Context, classId int) (int, pkg.CustomError)
+	UpdateClassKey(c context.Context, classId int, key string, expiresAt *time.Time) pkg.CustomError
+	CreateClassInvite(c context.Context, invite *models.ClassInvite) pkg.CustomError
+	GetClassInvites(c context.Context, classId int) ([]*models.ClassInvite, pkg.CustomError)
+	RevokeClassInvite(c context.Context, classId int, id int) pkg.CustomError
 }
 
 type TeacherRepository interface {
 	GetTeacherByEmail(c context.Context, email string) (*models.Teacher, pkg.CustomError)
 	GetTeacherById(c context.Context, id uuid.UUID) (*models.Teacher, pkg.CustomError)
+	GetTeacherByNPM(c context.Context, npm int) (*models.Teacher, pkg.CustomError)
 	CreateTeacher(c context.Context, request *models.Teacher) pkg.CustomError
+	VerifyTeacher(c context.Context, id uuid.UUID) pkg.CustomError
+	UpdateTeacherPassword(c context.Context, id uuid.UUID, password string) pkg.CustomError
+	UpdateTeacherName(c context.Context, id uuid.UUID, name string) pkg.CustomError
+	UpdateTeacherEmail(c context.Context, id uuid.UUID, email string) pkg.CustomError
+	GetTeacherProfile(c context.Context, id uuid.UUID) (*models.TeacherProfile, pkg.CustomError)
+	SaveTeacherProfile(c context.Context, profile *models.TeacherProfile) pkg.CustomError
+}
+
+type AuthRepository interface {
+	CreateRefreshToken(c context.Context, token *models.RefreshToken) pkg.CustomError
+	GetRefreshTokenById(c context.Context, id uuid.UUID) (*models.RefreshToken, pkg.CustomError)
+	MarkRefreshTokenUsed(c context.Context, id uuid.UUID) (bool, pkg.CustomError)
+	RevokeRefreshTokenFamily(c context.Context, familyId uuid.UUID) pkg.CustomError
+	RevokeUserRefreshTokens(c context.Context, userId uuid.UUID, role string) pkg.CustomError
+	CreatePasswordResetToken(c context.Context, token *models.PasswordResetToken) pkg.CustomError
+	UsePasswordResetToken(c context.Context, tokenHash string) (*models.PasswordResetToken, pkg.CustomError)
+}
+
+type RevocationRepository interface {
+	RevokeToken(c context.Context, jti string, expiresAt time.Time) pkg.CustomError
+	IsTokenRevoked(c context.Context, jti string) (bool, pkg.CustomError)
+	RevokeUserTokens(c context.Context, userId uuid.UUID, role string, notBefore time.Time, keepSessionId uuid.UUID) pkg.CustomError
+	IsUserTokenRevoked(c context.Context, userId uuid.UUID, role string, sessionId uuid.UUID, issuedAt time.Time) (bool, pkg.CustomError)
+}
+
+type VerificationRepository interface {
+	CreateVerificationCode(c context.Context, code *models.VerificationCode) pkg.CustomError
+	GetLatestVerificationCode(c context.Context, userId uuid.UUID, role string) (*models.VerificationCode, pkg.CustomError)
+	UseVerificationAttempt(c context.Context, id int) (bool, pkg.CustomError)
+	ConsumeVerificationCodes(c context.Context, userId uuid.UUID, role string) pkg.CustomError
+}
+
+type AdminRepository interface {
+	GetAdminByEmail(c context.Context, email string) (*models.Admin, pkg.CustomError)
+	GetAdminById(c context.Context, id uuid.UUID) (*models.Admin, pkg.CustomError)
+	CreateAdmin(c context.Context, admin *models.Admin) pkg.CustomError
+	CountAdmins(c context.Context) (int, pkg.CustomError)
+	UpdateAdminPassword(c context.Context, id uuid.UUID, password string) pkg.CustomError
+	UpdateAdminName(c context.Context, id uuid.UUID, name string) pkg.CustomError
+	UpdateAdminEmail(c context.Context, id uuid.UUID, email string) pkg.CustomError
+	FetchStudentAccounts(c context.Context, search string, status string) ([]*models.StudentAccount, pkg.CustomError)
+	FetchTeacherAccounts(c context.Context, search string, status string) ([]*models.TeacherAccount, pkg.CustomError)
+	ApproveUser(c context.Context, role string, id uuid.UUID) pkg.CustomError
+	SuspendUser(c context.Context, role string, id uuid.UUID) pkg.CustomError
+	RestoreUser(c context.Context, role string, id uuid.UUID) pkg.CustomError
+	DeleteUser(c context.Context, role string, id uuid.UUID) pkg.CustomError
+}
+
+type TermRepository interface {
+	GetTerms(c context.Context) ([]*models.Term, pkg.CustomError)
+	GetTermById(c context.Context, id int) (*models.Term, pkg.CustomError)
+	GetCurrentTerm(c context.Context) (*models.Term, pkg.CustomError)
+	CreateTerm(c context.Context, term *models.Term) pkg.CustomError
+	UpdateTerm(c context.Context, term *models.Term) pkg.CustomError
+	DeleteTerm(c context.Context, id int) pkg.CustomError
+}
+
+type CalendarRepository interface {
+	SetCalendarFeed(c context.Context, userId uuid.UUID, role string, tokenHash string) pkg.CustomError
+	DeleteCalendarFeed(c context.Context, userId uuid.UUID, role string) pkg.CustomError
+	UseCalendarFeed(c context.Context, tokenHash string) (uuid.UUID, string, pkg.CustomError)
+	GetStudentDeadlines(c context.Context, studentId uuid.UUID) ([]*models.Deadline, pkg.CustomError)
+	GetTeacherDeadlines(c context.Context, teacherId uuid.UUID) ([]*models.Deadline, pkg.CustomError)
+}
+
+type LoginAttemptRepository interface {
+	CreateLoginAttempt(c context.Context, attempt *models.LoginAttempt) pkg.CustomError
+	GetAccountFailures(c context.Context, email string, role string, since time.Time) (int, time.Time, pkg.CustomError)
+	GetIPFailures(c context.Context, ipAddress string, since time.Time) (int, time.Time, pkg.CustomError)
+}
+
+type OIDCRepository interface {
+	CreateLoginRequest(c context.Context, request *models.OIDCLoginRequest) pkg.CustomError
+	UseLoginRequest(c context.Context, stateHash string) (*models.OIDCLoginRequest, pkg.CustomError)
+	GetIdentityUserId(c context.Context, issuer string, subject string, role string) (uuid.UUID, pkg.CustomError)
+	CreateIdentity(c context.Context, identity *models.OIDCIdentity) pkg.CustomError
+}
+
+type APITokenRepository interface {
+	CreateAPIToken(c context.Context, token *models.APIToken) pkg.CustomError
+	UseAPIToken(c context.Context, tokenHash string) (*models.APIToken, pkg.CustomError)
+	GetAPITokensByUser(c context.Context, userId uuid.UUID, role string) ([]*models.APIToken, pkg.CustomError)
+	RevokeAPIToken(c context.Context, id uuid.UUID, userId uuid.UUID, role string) pkg.CustomError
+	RevokeUserAPITokens(c context.Context, userId uuid.UUID, role string) pkg.CustomError
+}
+
+type MFARepository interface {
+	GetTOTPSecret(c context.Context, userId uuid.UUID, role string) (*models.TOTPSecret, pkg.CustomError)
+	SaveTOTPSecret(c context.Context, userId uuid.UUID, role string, secret string) pkg.CustomError
+	ConfirmTOTPSecret(c context.Context, userId uuid.UUID, role string, step int64, recoveryCodeHashes []string) pkg.CustomError
+	UseTOTPStep(c context.Context, userId uuid.UUID, role string, step int64) (bool, pkg.CustomError)
+	ReplaceRecoveryCodes(c context.Context, userId uuid.UUID, role string, codeHashes []string) pkg.CustomError
+	UseRecoveryCode(c context.Context, userId uuid.UUID, role string, codeHash string) (bool, pkg.CustomError)
+	CountRecoveryCodes(c context.Context, userId uuid.UUID, role string) (int, pkg.CustomError)
+	DeleteMFA(c context.Context, userId uuid.UUID, role string) pkg.CustomError
+}
+
+type SessionRepository interface {
+	SaveSession(c context.Context, session *models.Session) pkg.CustomError
+	GetActiveSessions(c context.Context, userId uuid.UUID, role string) ([]*models.Session, pkg.CustomError)
+	RevokeSession(c context.Context, id uuid.UUID) pkg.CustomError
+	RevokeUserSessions(c context.Context, userId uuid.UUID, role string) pkg.CustomError
 }
@@ -0,0 +1,232 @@
+package repository
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/rifkhia/lms-remake/internal/pkg"
+	"github.com/rifkhia/lms-remake/internal/utils"
+	"sync"
+	"time"
+)
+
+// revocationSyncInterval bounds how long a token revoked by another instance
+// can still be accepted here.
+const revocationSyncInterval = 30 * time.Second
+
+type RevocationRepositoryImpl struct {
+	DB         *sqlx.DB
+	mutex      sync.RWMutex
+	revoked    map[string]time.Time
+	cutoffs    map[string]userTokenCutoff
+	lastSynced time.Time
+}
+
+// userTokenCutoff revokes every token of a user issued up to notBefore, except
+// those of keepSessionId when it is set.
+type userTokenCutoff struct {
+	notBefore     time.Time
+	keepSessionId uuid.UUID
+}
+
+func cutoffKey(userId uuid.UUID, role string) string {
+	return role + ":" + userId.String()
+}
+
+func (r *RevocationRepositoryImpl) RevokeToken(c context.Context, jti string, expiresAt time.Time) pkg.CustomError {
+	_, err := r.DB.ExecContext(c, "INSERT INTO revoked_tokens(jti, expires_at, created_at) VALUES($1, $2, now()) ON CONFLICT (jti) DO NOTHING", jti, expiresAt)
+	if err != nil {
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	r.mutex.Lock()
+	r.revoked[jti] = expiresAt
+	r.mutex.Unlock()
+
+	return pkg.CustomError{}
+}
+
+func (r *RevocationRepositoryImpl) IsTokenRevoked(c context.Context, jti string) (bool, pkg.CustomError) {
+	customError := r.syncRevokedTokens(c)
+	if customError.Cause != nil {
+		return false, customError
+	}
+
+	r.mutex.RLock()
+	_, revoked := r.revoked[jti]
+	r.mutex.RUnlock()
+
+	return revoked, pkg.CustomError{}
+}
+
+// RevokeUserTokens invalidates every token of the user issued up to notBefore,
+// apart from those of keepSessionId unless it is uuid.Nil. Token issue times
+// only have whole seconds, so the cutoff is rounded up to the next second: a
+// token minted in the same second as the revoke is revoked with it.
+func (r *RevocationRepositoryImpl) RevokeUserTokens(c context.Context, userId uuid.UUID, role string, notBefore time.Time, keepSessionId uuid.UUID) pkg.CustomError {
+	notBefore = notBefore.Truncate(time.Second).Add(time.Second)
+
+	var keep *uuid.UUID
+	if keepSessionId != uuid.Nil {
+		keep = &keepSessionId
+	}
+
+	_, err := r.DB.ExecContext(c, "INSERT INTO user_token_cutoffs(user_id, role, not_before, keep_session_id, updated_at) VALUES($1, $2, $3, $4, now()) ON CONFLICT (user_id, role) DO UPDATE SET not_before = EXCLUDED.not_before, keep_session_id = EXCLUDED.keep_session_id, updated_at = now()", userId, role, notBefore, keep)
+	if err != nil {
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	r.mutex.Lock()
+	r.cutoffs[cutoffKey(userId, role)] = userTokenCutoff{notBefore: notBefore, keepSessionId: keepSessionId}
+	r.mutex.Unlock()
+
+	return pkg.CustomError{}
+}
+
+func (r *RevocationRepositoryImpl) IsUserTokenRevoked(c context.Context, userId uuid.UUID, role string, sessionId uuid.UUID, issuedAt time.Time) (bool, pkg.CustomError) {
+	customError := r.syncRevokedTokens(c)
+	if customError.Cause != nil {
+		return false, customError
+	}
+
+	r.mutex.RLock()
+	cutoff, ok := r.cutoffs[cutoffKey(userId, role)]
+	r.mutex.RUnlock()
+
+	if !ok || issuedAt.After(cutoff.notBefore) {
+		return false, pkg.CustomError{}
+	}
+
+	return cutoff.keepSessionId == uuid.Nil || sessionId != cutoff.keepSessionId, pkg.CustomError{}
+}
+
+// syncRevokedTokens reloads the cache from the database once it is older than
+// revocationSyncInterval, dropping entries whose token already expired.
+func (r *RevocationRepositoryImpl) syncRevokedTokens(c context.Context) pkg.CustomError {
+	r.mutex.RLock()
+	fresh := time.Since(r.lastSynced) < revocationSyncInterval
+	r.mutex.RUnlock()
+	if fresh {
+		return pkg.CustomError{}
+	}
+
+	_, err := r.DB.ExecContext(c, "DELETE FROM revoked_tokens WHERE expires_at < now()")
+	if err != nil {
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	revoked := make(map[string]time.Time)
+	rows, err := r.DB.QueryxContext(c, "SELECT jti, expires_at FROM revoked_tokens")
+	if err != nil {
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var jti string
+		var expiresAt time.Time
+		err = rows.Scan(&jti, &expiresAt)
+		if err != nil {
+			return pkg.CustomError{
+				Code:    utils.INTERNAL_SERVER_ERROR,
+				Cause:   err,
+				Service: utils.REPOSITORY_SERVICE,
+			}
+		}
+		revoked[jti] = expiresAt
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	cutoffs := make(map[string]userTokenCutoff)
+	cutoffRows, err := r.DB.QueryxContext(c, "SELECT user_id, role, not_before, keep_session_id FROM user_token_cutoffs")
+	if err != nil {
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	defer cutoffRows.Close()
+
+	for cutoffRows.Next() {
+		var userId uuid.UUID
+		var role string
+		var notBefore time.Time
+		var keepSessionId *uuid.UUID
+		err = cutoffRows.Scan(&userId, &role, &notBefore, &keepSessionId)
+		if err != nil {
+			return pkg.CustomError{
+				Code:    utils.INTERNAL_SERVER_ERROR,
+				Cause:   err,
+				Service: utils.REPOSITORY_SERVICE,
+			}
+		}
+		cutoff := userTokenCutoff{notBefore: notBefore}
+		if keepSessionId != nil {
+			cutoff.keepSessionId = *keepSessionId
+		}
+		cutoffs[cutoffKey(userId, role)] = cutoff
+	}
+
+	err = cutoffRows.Err()
+	if err != nil {
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	now := time.Now()
+	r.mutex.Lock()
+	for jti, expiresAt := range r.revoked {
+		if _, ok := revoked[jti]; !ok && expiresAt.After(now) {
+			revoked[jti] = expiresAt
+		}
+	}
+	for key, cutoff := range r.cutoffs {
+		if current, ok := cutoffs[key]; !ok || current.notBefore.Before(cutoff.notBefore) {
+			cutoffs[key] = cutoff
+		}
+	}
+	r.revoked = revoked
+	r.cutoffs = cutoffs
+	r.lastSynced = now
+	r.mutex.Unlock()
+
+	return pkg.CustomError{}
+}
+
+func NewRevocationRepository(db *sqlx.DB) RevocationRepository {
+	return &RevocationRepositoryImpl{
+		DB:      db,
+		revoked: make(map[string]time.Time),
+		cutoffs: make(map[string]userTokenCutoff),
+	}
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/rifkhia/lms-remake/internal/models"
+	"github.com/rifkhia/lms-remake/internal/pkg"
+	"github.com/rifkhia/lms-remake/internal/utils"
+)
+
+type SessionRepositoryImpl struct {
+	DB *sqlx.DB
+}
+
+// SaveSession records a login, or the activity of a session whose refresh
+// token was rotated. A revoked session is left as it is.
+func (r *SessionRepositoryImpl) SaveSession(c context.Context, session *models.Session) pkg.CustomError {
+	_, err := r.DB.NamedExecContext(c, `INSERT INTO sessions(id, user_id, role, user_agent, ip_address, last_active_at, expires_at, created_at)
+		VALUES(:id, :userid, :role, :useragent, :ipaddress, now(), :expiresat, now())
+		ON CONFLICT (id) DO UPDATE SET user_agent = excluded.user_agent, ip_address = excluded.ip_address, last_active_at = now(), expires_at = excluded.expires_at
+		WHERE sessions.revoked_at IS NULL`, session)
+	if err != nil {
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return pkg.CustomError{}
+}
+
+// GetActiveSessions lists the sessions that can still be refreshed, most
+// recently used first.
+func (r *SessionRepositoryImpl) GetActiveSessions(c context.Context, userId uuid.UUID, role string) ([]*models.Session, pkg.CustomError) {
+	sessions := []*models.Session{}
+
+	err := r.DB.SelectContext(c, &sessions, "SELECT id, user_agent AS useragent, ip_address AS ipaddress, last_active_at AS lastactiveat, expires_at AS expiresat, created_at AS createdat FROM sessions WHERE user_id = $1 AND role = $2 AND revoked_at IS NULL AND expires_at > now() ORDER BY last_active_at DESC", userId, role)
+	if err != nil {
+		return nil, pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return sessions, pkg.CustomError{}
+}
+
+func (r *SessionRepositoryImpl) RevokeSession(c context.Context, id uuid.UUID) pkg.CustomError {
+	_, err := r.DB.ExecContext(c, "UPDATE sessions SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL", id)
+	if err != nil {
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return pkg.CustomError{}
+}
+
+func (r *SessionRepositoryImpl) RevokeUserSessions(c context.Context, userId uuid.UUID, role string) pkg.CustomError {
+	_, err := r.DB.ExecContext(c, "UPDATE sessions SET revoked_at = now() WHERE user_id = $1 AND role = $2 AND revoked_at IS NULL", userId, role)
+	if err != nil {
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return pkg.CustomError{}
+}
+
+func NewSessionRepository(db *sqlx.DB) SessionRepository {
+	return &SessionRepositoryImpl{
+		DB: db,
+	}
+}
@@ -9,7 +9,9 @@ import (
 	"github.com/rifkhia/lms-remake/internal/models"
 	"github.com/rifkhia/lms-remake/internal/pkg"
 	"github.com/rifkhia/lms-remake/internal/utils"
+	"log"
 	"strings"
+	"time"
 )
 
 type StudentRepositoryImpl struct {
@@ -19,7 +21,7 @@ type StudentRepositoryImpl struct {
 func (r *StudentRepositoryImpl) GetStudentByID(c context.Context, id uuid.UUID) (*models.Student, pkg.CustomError) {
 	var student models.Student
 
-	rows, err := r.DB.QueryxContext(c, "SELECT id, name, nim, email, password FROM students WHERE id = $1 AND deleted_at IS NULL", id)
+	rows, err := r.DB.QueryxContext(c, "SELECT id, name, nim, email, password, verified_at AS verifiedat, suspended_at AS suspendedat, deletion_scheduled_at AS deletionscheduledat FROM students WHERE id = $1 AND deleted_at IS NULL", id)
 	if err != nil {
 		return nil, pkg.CustomError{
 			Code:    utils.INTERNAL_SERVER_ERROR,
@@ -66,10 +68,60 @@ func (r *StudentRepositoryImpl) GetStudentByID(c context.Context, id uuid.UUID)
 	return &student, pkg.CustomError{}
 }
 
+func (r *StudentRepositoryImpl) GetStudentByNIM(c context.Context, nim int) (*models.Student, pkg.CustomError) {
+	var student models.Student
+
+	rows, err := r.DB.QueryxContext(c, "SELECT id, name, nim, email, password, verified_at AS verifiedat, suspended_at AS suspendedat FROM students WHERE nim = $1 AND deleted_at IS NULL", nim)
+	if err != nil {
+		return nil, pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, pkg.CustomError{
+				Code:    utils.INTERNAL_SERVER_ERROR,
+				Cause:   err,
+				Service: utils.REPOSITORY_SERVICE,
+			}
+		}
+		return nil, pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("no student found using current nim"),
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	err = rows.StructScan(&student)
+	if err != nil {
+		return nil, pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return &student, pkg.CustomError{}
+}
+
 func (r *StudentRepositoryImpl) GetStudentByEmail(c context.Context, email string) (*models.Student, pkg.CustomError) {
 	var student models.Student
 
-	rows, err := r.DB.QueryxContext(c, "SELECT id, name, nim, email, password FROM students WHERE email = $1 AND deleted_at IS NULL", email)
+	rows, err := r.DB.QueryxContext(c, "SELECT id, name, nim, email, password, verified_at AS verifiedat, suspended_at AS suspendedat FROM students WHERE email = $1 AND deleted_at IS NULL", email)
 	if err != nil {
 		return nil, pkg.CustomError{
 			Code:    utils.INTERNAL_SERVER_ERROR,
@@ -221,8 +273,103 @@ func (r *StudentRepositoryImpl) CreateStudent(c context.Context, student *models
 	return pkg.CustomError{}
 }
 
-func (r *StudentRepositoryImpl) DeleteStudent(c context.Context, id uuid.UUID) pkg.CustomError {
-	_, err := r.DB.NamedExecContext(c, "UPDATE students SET deleted_at = now() WHERE id = ?", id)
+func (r *StudentRepositoryImpl) ScheduleStudentDeletion(c context.Context, id uuid.UUID, scheduledAt time.Time) pkg.CustomError {
+	_, err := r.DB.ExecContext(c, "UPDATE students SET deletion_scheduled_at = $1, updated_at = now() WHERE id = $2 AND deleted_at IS NULL", scheduledAt, id)
+	if err != nil {
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return pkg.CustomError{}
+}
+
+func (r *StudentRepositoryImpl) CancelStudentDeletion(c context.Context, id uuid.UUID) pkg.CustomError {
+	_, err := r.DB.ExecContext(c, "UPDATE students SET deletion_scheduled_at = NULL, updated_at = now() WHERE id = $1 AND deleted_at IS NULL", id)
+	if err != nil {
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return pkg.CustomError{}
+}
+
+func (r *StudentRepositoryImpl) GetStudentsDueForDeletion(c context.Context) ([]uuid.UUID, pkg.CustomError) {
+	ids := []uuid.UUID{}
+
+	err := r.DB.SelectContext(c, &ids, "SELECT id FROM students WHERE deletion_scheduled_at <= now() AND anonymized_at IS NULL")
+	if err != nil {
+		return nil, pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return ids, pkg.CustomError{}
+}
+
+// AnonymizeStudent carries out an account closure. The student row is kept so
+// that submissions and class history stay consistent for teachers, but
+// everything identifying is replaced, the profile and sign-in data are removed
+// and the student leaves every class, handing their seats to the waitlist.
+func (r *StudentRepositoryImpl) AnonymizeStudent(c context.Context, id uuid.UUID) pkg.CustomError {
+	tx, err := r.DB.BeginTxx(c, nil)
+	if err != nil {
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	defer tx.Rollback()
+
+	role := utils.STUDENT_ROLE
+	statements := []struct {
+		query string
+		args  []interface{}
+	}{
+		{"DELETE FROM login_attempts WHERE role = $1 AND email = (SELECT email FROM students WHERE id = $2)", []interface{}{role, id}},
+		{"DELETE FROM student_profile WHERE id = $1", []interface{}{id}},
+		{"UPDATE student_submissions SET linkfile = '' WHERE student_id = $1", []interface{}{id}},
+		{"DELETE FROM oidc_identities WHERE user_id = $1 AND role = $2", []interface{}{id, role}},
+		{"DELETE FROM totp_secrets WHERE user_id = $1 AND role = $2", []interface{}{id, role}},
+		{"DELETE FROM mfa_recovery_codes WHERE user_id = $1 AND role = $2", []interface{}{id, role}},
+		{"DELETE FROM verification_codes WHERE user_id = $1 AND role = $2", []interface{}{id, role}},
+		{"DELETE FROM password_reset_tokens WHERE user_id = $1 AND role = $2", []interface{}{id, role}},
+		{"DELETE FROM calendar_feeds WHERE user_id = $1 AND role = $2", []interface{}{id, role}},
+		{`UPDATE students SET name = 'Deleted student', nim = (-nextval('anonymized_student_nim'))::varchar,
+			email = 'deleted-' || id || '@deleted.invalid', password = '', deletion_scheduled_at = NULL,
+			deleted_at = COALESCE(deleted_at, now()), anonymized_at = now(), updated_at = now()
+			WHERE id = $1 AND anonymized_at IS NULL`, []interface{}{id}},
+	}
+	for _, statement := range statements {
+		_, err = tx.ExecContext(c, statement.query, statement.args...)
+		if err != nil {
+			return pkg.CustomError{
+				Code:    utils.INTERNAL_SERVER_ERROR,
+				Cause:   err,
+				Service: utils.REPOSITORY_SERVICE,
+			}
+		}
+	}
+
+	freedClassIds, err := withdrawStudent(c, tx, id)
+	if err != nil {
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	err = tx.Commit()
 	if err != nil {
 		return pkg.CustomError{
 			Code:    utils.INTERNAL_SERVER_ERROR,
@@ -231,9 +378,43 @@ func (r *StudentRepositoryImpl) DeleteStudent(c context.Context, id uuid.UUID) p
 		}
 	}
 
+	promoteWaitlists(c, r.DB, freedClassIds)
+
 	return pkg.CustomError{}
 }
 
+// withdrawStudent takes a student whose account is going away out of every
+// class within tx: their enrollments end and their open enrollment requests
+// are cancelled. It returns the classes where a seat was freed.
+func withdrawStudent(c context.Context, tx *sqlx.Tx, id uuid.UUID) ([]int, error) {
+	classIds := []int{}
+
+	err := tx.SelectContext(c, &classIds, "UPDATE student_class SET deleted_at = now() WHERE student_id = $1 AND deleted_at IS NULL RETURNING class_id", id)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(c, "UPDATE enrollment_requests SET status = $1, decided_at = now() WHERE student_id = $2 AND status IN ($3, $4)", utils.ENROLLMENT_CANCELLED, id, utils.ENROLLMENT_PENDING, utils.ENROLLMENT_WAITLISTED)
+	if err != nil {
+		return nil, err
+	}
+
+	return classIds, nil
+}
+
+// promoteWaitlists hands out the seats freed by withdrawStudent once its
+// transaction has committed. The withdrawal already succeeded, so a failure is
+// only logged and the seats go out on the next change to the class.
+func promoteWaitlists(c context.Context, db *sqlx.DB, classIds []int) {
+	classRepo := &ClassRepositoryImpl{DB: db}
+	for _, classId := range classIds {
+		_, customError := classRepo.PromoteWaitlist(c, classId)
+		if customError.Cause != nil {
+			log.Printf("failed to promote waitlist of class %d: %s", classId, customError.Cause)
+		}
+	}
+}
+
 func (r *StudentRepositoryImpl) GetStudentProfile(c context.Context, id uuid.UUID) (*dto.StudentProfileRequest, pkg.CustomError) {
 	var student dto.StudentProfileRequest
 	rows, err := r.DB.QueryxContext(c, "SELECT id, dateofbirth, gender, address, phone FROM student_profile WHERE id = $1", id)
@@ -291,9 +472,15 @@ func (r *StudentRepositoryImpl) EditStudentProfile(c context.Context, student *d
 	return pkg.CustomError{}
 }
 
-func (r *StudentRepositoryImpl) FetchStudentClass(c context.Context, id uuid.UUID) ([]*models.StudentSchedule, pkg.CustomError) {
-	var schedules []*models.StudentSchedule
-	rows, err := r.DB.QueryxContext(c, "SELECT c.name, c.day, c.start_time as starttime, c.end_time as endtime FROM students LEFT JOIN public.student_class sc on students.id = sc.student_id LEFT JOIN public.classes c on c.id = sc.class_id WHERE students.id=$1 ORDER BY day, start_time", id)
+// FetchStudentClass returns the meetings of the classes the student is
+// enrolled in, only those offered in termId unless it is nil. Archived classes
+// are left out, as they are from a teacher's schedule.
+func (r *StudentRepositoryImpl) FetchStudentClass(c context.Context, id uuid.UUID, termId *int) ([]*models.ScheduleEntry, pkg.CustomError) {
+	var schedules []*models.ScheduleEntry
+	rows, err := r.DB.QueryxContext(c, `SELECT c.name, c.term_id AS termid, m.id, m.class_id AS classid, m.weekday, m.start_time AS starttime, m.end_time AS endtime, m.room, m.timezone
+		FROM student_class sc JOIN classes c ON c.id = sc.class_id JOIN class_meetings m ON m.class_id = c.id
+		WHERE sc.student_id = $1 AND sc.deleted_at IS NULL AND c.deleted_at IS NULL AND c.archived_at IS NULL AND ($2::int IS NULL OR c.term_id = $2)
+		ORDER BY m.weekday, m.start_time, c.name`, id, termId)
 	if err != nil {
 		return nil, pkg.CustomError{
 			Code:    utils.INTERNAL_SERVER_ERROR,
@@ -305,7 +492,7 @@ func (r *StudentRepositoryImpl) FetchStudentClass(c context.Context, id uuid.UUI
 	defer rows.Close()
 
 	for rows.Next() {
-		var schedule = new(models.StudentSchedule)
+		var schedule = new(models.ScheduleEntry)
 		err = rows.StructScan(&schedule)
 		if err != nil {
 			return nil, pkg.CustomError{
@@ -329,6 +516,95 @@ func (r *StudentRepositoryImpl) FetchStudentClass(c context.Context, id uuid.UUI
 	return schedules, pkg.CustomError{}
 }
 
+func (r *StudentRepositoryImpl) VerifyStudent(c context.Context, id uuid.UUID) pkg.CustomError {
+	_, err := r.DB.ExecContext(c, "UPDATE students SET verified_at = now(), updated_at = now() WHERE id = $1 AND verified_at IS NULL", id)
+	if err != nil {
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return pkg.CustomError{}
+}
+
+func (r *StudentRepositoryImpl) UpdateStudentPassword(c context.Context, id uuid.UUID, password string) pkg.CustomError {
+	_, err := r.DB.ExecContext(c, "UPDATE students SET password = $1, updated_at = now() WHERE id = $2", password, id)
+	if err != nil {
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return pkg.CustomError{}
+}
+
+func (r *StudentRepositoryImpl) UpdateStudentName(c context.Context, id uuid.UUID, name string) pkg.CustomError {
+	_, err := r.DB.ExecContext(c, "UPDATE students SET name = $1, updated_at = now() WHERE id = $2", name, id)
+	if err != nil {
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return pkg.CustomError{}
+}
+
+func (r *StudentRepositoryImpl) UpdateStudentEmail(c context.Context, id uuid.UUID, email string) pkg.CustomError {
+	_, err := r.DB.ExecContext(c, "UPDATE students SET email = $1, updated_at = now() WHERE id = $2", email, id)
+	if err != nil {
+		if strings.Contains(err.Error(), "violates unique constraint") {
+			return pkg.CustomError{
+				Code:    utils.BAD_REQUEST,
+				Cause:   errors.New("email is already used by another account"),
+				Service: utils.REPOSITORY_SERVICE,
+			}
+		}
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return pkg.CustomError{}
+}
+
+func (r *StudentRepositoryImpl) GetStudentEnrollments(c context.Context, id uuid.UUID) ([]*models.StudentEnrollment, pkg.CustomError) {
+	enrollments := []*models.StudentEnrollment{}
+
+	err := r.DB.SelectContext(c, &enrollments, "SELECT c.id AS classid, c.name, sc.created_at AS joinedat, sc.deleted_at AS leftat FROM student_class sc INNER JOIN classes c ON c.id = sc.class_id WHERE sc.student_id = $1 ORDER BY sc.created_at", id)
+	if err != nil {
+		return nil, pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return enrollments, pkg.CustomError{}
+}
+
+func (r *StudentRepositoryImpl) GetStudentSubmissions(c context.Context, id uuid.UUID) ([]*models.StudentSubmissionRecord, pkg.CustomError) {
+	submissions := []*models.StudentSubmissionRecord{}
+
+	err := r.DB.SelectContext(c, &submissions, "SELECT ss.class_section_id AS classsectionid, cs.title AS sectiontitle, ss.linkfile AS file, ss.created_at AS submittedat FROM student_submissions ss INNER JOIN class_sections cs ON cs.id = ss.class_section_id WHERE ss.student_id = $1 ORDER BY ss.created_at", id)
+	if err != nil {
+		return nil, pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return submissions, pkg.CustomError{}
+}
+
 func NewStudentRepository(db *sqlx.DB) StudentRepository {
 	return &StudentRepositoryImpl{
 		DB: db,
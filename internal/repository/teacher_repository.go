@@ -18,7 +18,14 @@ type TeacherRepositoryImpl struct {
 func (r *TeacherRepositoryImpl) GetTeacherById(c context.Context, id uuid.UUID) (*models.Teacher, error2.CustomError) {
 	var teacher models.Teacher
 
-	rows, err := r.DB.QueryxContext(c, "SELECT * FROM teachers WHERE id = $1 AND deleted_at IS NULL", id)
+	rows, err := r.DB.QueryxContext(c, "SELECT id, name, npm, email, password, verified_at AS verifiedat, approved_at AS approvedat, suspended_at AS suspendedat FROM teachers WHERE id = $1 AND deleted_at IS NULL", id)
+	if err != nil {
+		return nil, error2.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
 
 	defer rows.Close()
 
@@ -37,15 +44,64 @@ func (r *TeacherRepositoryImpl) GetTeacherById(c context.Context, id uuid.UUID)
 		}
 	}
 
-	for rows.Next() {
-		err := rows.StructScan(&teacher)
-		if err != nil {
+	err = rows.StructScan(&teacher)
+	if err != nil {
+		return nil, error2.CustomError{
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+			Code:    utils.INTERNAL_SERVER_ERROR,
+		}
+	}
+
+	err = rows.Err()
+
+	if err != nil {
+		return nil, error2.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return &teacher, error2.CustomError{}
+}
+
+func (r *TeacherRepositoryImpl) GetTeacherByNPM(c context.Context, npm int) (*models.Teacher, error2.CustomError) {
+	var teacher models.Teacher
+
+	rows, err := r.DB.QueryxContext(c, "SELECT id, name, npm, email, password, verified_at AS verifiedat, approved_at AS approvedat, suspended_at AS suspendedat FROM teachers WHERE npm = $1 AND deleted_at IS NULL", npm)
+	if err != nil {
+		return nil, error2.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
 			return nil, error2.CustomError{
+				Code:    utils.INTERNAL_SERVER_ERROR,
 				Cause:   err,
 				Service: utils.REPOSITORY_SERVICE,
-				Code:    utils.INTERNAL_SERVER_ERROR,
 			}
 		}
+		return nil, error2.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("no teacher found using current npm"),
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	err = rows.StructScan(&teacher)
+	if err != nil {
+		return nil, error2.CustomError{
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+			Code:    utils.INTERNAL_SERVER_ERROR,
+		}
 	}
 
 	err = rows.Err()
@@ -64,7 +120,14 @@ func (r *TeacherRepositoryImpl) GetTeacherById(c context.Context, id uuid.UUID)
 func (r *TeacherRepositoryImpl) GetTeacherByEmail(c context.Context, email string) (*models.Teacher, error2.CustomError) {
 	var teacher models.Teacher
 
-	rows, err := r.DB.QueryxContext(c, "SELECT id, name, npm, email, password FROM teachers WHERE email = $1 AND deleted_at IS NULL", email)
+	rows, err := r.DB.QueryxContext(c, "SELECT id, name, npm, email, password, verified_at AS verifiedat, approved_at AS approvedat, suspended_at AS suspendedat FROM teachers WHERE email = $1 AND deleted_at IS NULL", email)
+	if err != nil {
+		return nil, error2.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
 
 	defer rows.Close()
 
@@ -126,6 +189,118 @@ func (r *TeacherRepositoryImpl) CreateTeacher(c context.Context, request *models
 	return error2.CustomError{}
 }
 
+func (r *TeacherRepositoryImpl) VerifyTeacher(c context.Context, id uuid.UUID) error2.CustomError {
+	_, err := r.DB.ExecContext(c, "UPDATE teachers SET verified_at = now(), updated_at = now() WHERE id = $1 AND verified_at IS NULL", id)
+	if err != nil {
+		return error2.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return error2.CustomError{}
+}
+
+func (r *TeacherRepositoryImpl) UpdateTeacherPassword(c context.Context, id uuid.UUID, password string) error2.CustomError {
+	_, err := r.DB.ExecContext(c, "UPDATE teachers SET password = $1, updated_at = now() WHERE id = $2", password, id)
+	if err != nil {
+		return error2.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return error2.CustomError{}
+}
+
+func (r *TeacherRepositoryImpl) UpdateTeacherName(c context.Context, id uuid.UUID, name string) error2.CustomError {
+	_, err := r.DB.ExecContext(c, "UPDATE teachers SET name = $1, updated_at = now() WHERE id = $2", name, id)
+	if err != nil {
+		return error2.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return error2.CustomError{}
+}
+
+func (r *TeacherRepositoryImpl) UpdateTeacherEmail(c context.Context, id uuid.UUID, email string) error2.CustomError {
+	_, err := r.DB.ExecContext(c, "UPDATE teachers SET email = $1, updated_at = now() WHERE id = $2", email, id)
+	if err != nil {
+		if strings.Contains(err.Error(), "violates unique constraint") {
+			return error2.CustomError{
+				Code:    utils.BAD_REQUEST,
+				Cause:   errors.New("email is already used by another account"),
+				Service: utils.REPOSITORY_SERVICE,
+			}
+		}
+		return error2.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return error2.CustomError{}
+}
+
+// GetTeacherProfile returns nil when the teacher has not filled in a profile.
+func (r *TeacherRepositoryImpl) GetTeacherProfile(c context.Context, id uuid.UUID) (*models.TeacherProfile, error2.CustomError) {
+	var profile models.TeacherProfile
+
+	rows, err := r.DB.QueryxContext(c, "SELECT id, title, department, office_hours AS officehours, bio, avatar_url AS avatarurl FROM teacher_profile WHERE id = $1", id)
+	if err != nil {
+		return nil, error2.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, error2.CustomError{
+				Code:    utils.INTERNAL_SERVER_ERROR,
+				Cause:   err,
+				Service: utils.REPOSITORY_SERVICE,
+			}
+		}
+		return nil, error2.CustomError{}
+	}
+
+	err = rows.StructScan(&profile)
+	if err != nil {
+		return nil, error2.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return &profile, error2.CustomError{}
+}
+
+func (r *TeacherRepositoryImpl) SaveTeacherProfile(c context.Context, profile *models.TeacherProfile) error2.CustomError {
+	_, err := r.DB.NamedExecContext(c, `INSERT INTO teacher_profile(id, title, department, office_hours, bio, avatar_url, created_at, updated_at)
+		VALUES(:id, :title, :department, :officehours, :bio, :avatarurl, now(), now())
+		ON CONFLICT (id) DO UPDATE SET title = excluded.title, department = excluded.department, office_hours = excluded.office_hours, bio = excluded.bio, avatar_url = excluded.avatar_url, updated_at = now()`, profile)
+	if err != nil {
+		return error2.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return error2.CustomError{}
+}
+
 func NewTeacherRepository(db *sqlx.DB) TeacherRepository {
 	return &TeacherRepositoryImpl{
 		DB: db,
@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/rifkhia/lms-remake/internal/models"
+	"github.com/rifkhia/lms-remake/internal/pkg"
+	"github.com/rifkhia/lms-remake/internal/utils"
+	"strings"
+)
+
+type TermRepositoryImpl struct {
+	DB *sqlx.DB
+}
+
+const termColumns = "id, name, starts_on AS startson, ends_on AS endson, enrollment_opens_at AS enrollmentopensat, enrollment_closes_at AS enrollmentclosesat"
+
+func (r *TermRepositoryImpl) GetTerms(c context.Context) ([]*models.Term, pkg.CustomError) {
+	terms := []*models.Term{}
+
+	err := r.DB.SelectContext(c, &terms, "SELECT "+termColumns+" FROM academic_terms ORDER BY starts_on DESC, id DESC")
+	if err != nil {
+		return nil, pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return terms, pkg.CustomError{}
+}
+
+func (r *TermRepositoryImpl) GetTermById(c context.Context, id int) (*models.Term, pkg.CustomError) {
+	terms, customError := r.getTerms(c, "SELECT "+termColumns+" FROM academic_terms WHERE id = $1", id)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	if len(terms) == 0 {
+		return nil, pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("no term with that id"),
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return terms[0], pkg.CustomError{}
+}
+
+// GetCurrentTerm returns the term in progress, or the next one between terms.
+// It returns nil when no term has yet to end.
+func (r *TermRepositoryImpl) GetCurrentTerm(c context.Context) (*models.Term, pkg.CustomError) {
+	terms, customError := r.getTerms(c, "SELECT "+termColumns+" FROM academic_terms WHERE ends_on >= CURRENT_DATE ORDER BY starts_on, id LIMIT 1")
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	if len(terms) == 0 {
+		return nil, pkg.CustomError{}
+	}
+
+	return terms[0], pkg.CustomError{}
+}
+
+func (r *TermRepositoryImpl) getTerms(c context.Context, query string, args ...interface{}) ([]*models.Term, pkg.CustomError) {
+	var terms []*models.Term
+
+	err := r.DB.SelectContext(c, &terms, query, args...)
+	if err != nil {
+		return nil, pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return terms, pkg.CustomError{}
+}
+
+func (r *TermRepositoryImpl) CreateTerm(c context.Context, term *models.Term) pkg.CustomError {
+	err := r.DB.QueryRowxContext(c, "INSERT INTO academic_terms(name, starts_on, ends_on, enrollment_opens_at, enrollment_closes_at, created_at, updated_at) VALUES($1, $2, $3, $4, $5, now(), now()) RETURNING id", term.Name, term.StartsOn, term.EndsOn, term.EnrollmentOpensAt, term.EnrollmentClosesAt).Scan(&term.ID)
+	if err != nil {
+		return termWriteError(err)
+	}
+
+	return pkg.CustomError{}
+}
+
+func (r *TermRepositoryImpl) UpdateTerm(c context.Context, term *models.Term) pkg.CustomError {
+	result, err := r.DB.ExecContext(c, "UPDATE academic_terms SET name = $1, starts_on = $2, ends_on = $3, enrollment_opens_at = $4, enrollment_closes_at = $5, updated_at = now() WHERE id = $6", term.Name, term.StartsOn, term.EndsOn, term.EnrollmentOpensAt, term.EnrollmentClosesAt, term.ID)
+	if err != nil {
+		return termWriteError(err)
+	}
+
+	return termAffected(result.RowsAffected())
+}
+
+// DeleteTerm only removes a term no class is offered in.
+func (r *TermRepositoryImpl) DeleteTerm(c context.Context, id int) pkg.CustomError {
+	result, err := r.DB.ExecContext(c, "DELETE FROM academic_terms WHERE id = $1", id)
+	if err != nil {
+		if strings.Contains(err.Error(), "violates foreign key constraint") {
+			return pkg.CustomError{
+				Code:    utils.BAD_REQUEST,
+				Cause:   errors.New("classes are still offered in this term"),
+				Service: utils.REPOSITORY_SERVICE,
+			}
+		}
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return termAffected(result.RowsAffected())
+}
+
+func termWriteError(err error) pkg.CustomError {
+	if strings.Contains(err.Error(), "violates unique constraint") {
+		return pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("a term with that name already exists"),
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return pkg.CustomError{
+		Code:    utils.INTERNAL_SERVER_ERROR,
+		Cause:   err,
+		Service: utils.REPOSITORY_SERVICE,
+	}
+}
+
+func termAffected(affected int64, err error) pkg.CustomError {
+	if err != nil {
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	if affected == 0 {
+		return pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("no term with that id"),
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return pkg.CustomError{}
+}
+
+func NewTermRepository(db *sqlx.DB) TermRepository {
+	return &TermRepositoryImpl{
+		DB: db,
+	}
+}
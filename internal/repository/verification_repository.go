@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/rifkhia/lms-remake/internal/models"
+	"github.com/rifkhia/lms-remake/internal/pkg"
+	"github.com/rifkhia/lms-remake/internal/utils"
+)
+
+type VerificationRepositoryImpl struct {
+	DB *sqlx.DB
+}
+
+func (r *VerificationRepositoryImpl) CreateVerificationCode(c context.Context, code *models.VerificationCode) pkg.CustomError {
+	_, err := r.DB.NamedExecContext(c, "INSERT INTO verification_codes(user_id, role, code_hash, attempts, new_email, expires_at, created_at) VALUES(:userid, :role, :codehash, 0, :newemail, :expiresat, now())", code)
+	if err != nil {
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return pkg.CustomError{}
+}
+
+// GetLatestVerificationCode returns the newest code that has not been consumed,
+// whether or not it already expired, so callers can also enforce resend cooldowns.
+func (r *VerificationRepositoryImpl) GetLatestVerificationCode(c context.Context, userId uuid.UUID, role string) (*models.VerificationCode, pkg.CustomError) {
+	var code models.VerificationCode
+
+	rows, err := r.DB.QueryxContext(c, "SELECT id, user_id AS userid, role, code_hash AS codehash, attempts, new_email AS newemail, expires_at AS expiresat, created_at AS createdat FROM verification_codes WHERE user_id = $1 AND role = $2 AND consumed_at IS NULL ORDER BY created_at DESC LIMIT 1", userId, role)
+	if err != nil {
+		return nil, pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, pkg.CustomError{
+				Code:    utils.INTERNAL_SERVER_ERROR,
+				Cause:   err,
+				Service: utils.REPOSITORY_SERVICE,
+			}
+		}
+		return nil, pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("no verification code found, please request a new one"),
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	err = rows.StructScan(&code)
+	if err != nil {
+		return nil, pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return &code, pkg.CustomError{}
+}
+
+// UseVerificationAttempt counts one attempt against the code and reports
+// whether the attempt was still allowed.
+func (r *VerificationRepositoryImpl) UseVerificationAttempt(c context.Context, id int) (bool, pkg.CustomError) {
+	result, err := r.DB.ExecContext(c, "UPDATE verification_codes SET attempts = attempts + 1 WHERE id = $1 AND attempts < $2 AND consumed_at IS NULL", id, utils.OTP_MAX_ATTEMPTS)
+	if err != nil {
+		return false, pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return affected > 0, pkg.CustomError{}
+}
+
+func (r *VerificationRepositoryImpl) ConsumeVerificationCodes(c context.Context, userId uuid.UUID, role string) pkg.CustomError {
+	_, err := r.DB.ExecContext(c, "UPDATE verification_codes SET consumed_at = now() WHERE user_id = $1 AND role = $2 AND consumed_at IS NULL", userId, role)
+	if err != nil {
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.REPOSITORY_SERVICE,
+		}
+	}
+
+	return pkg.CustomError{}
+}
+
+func NewVerificationRepository(db *sqlx.DB) VerificationRepository {
+	return &VerificationRepositoryImpl{
+		DB: db,
+	}
+}
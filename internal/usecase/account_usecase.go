@@ -0,0 +1,337 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/rifkhia/lms-remake/internal/authz"
+	"github.com/rifkhia/lms-remake/internal/dto"
+	"github.com/rifkhia/lms-remake/internal/models"
+	"github.com/rifkhia/lms-remake/internal/pkg"
+	"github.com/rifkhia/lms-remake/internal/repository"
+	"github.com/rifkhia/lms-remake/internal/utils"
+	"strings"
+)
+
+// AccountUsecase backs /v1/me, where users of every role read and change
+// their own account.
+type AccountUsecase interface {
+	FetchMe(c context.Context, subject authz.Subject) (*dto.MeResponse, pkg.CustomError)
+	UpdateMe(c context.Context, subject authz.Subject, request *dto.UpdateMeRequest) (*dto.MeResponse, pkg.CustomError)
+	ConfirmEmailChange(c context.Context, subject authz.Subject, request *dto.ConfirmEmailChangeRequest) (*dto.MeResponse, pkg.CustomError)
+}
+
+type accountUsecaseImpl struct {
+	studentRepo         repository.StudentRepository
+	teacherRepo         repository.TeacherRepository
+	adminRepo           repository.AdminRepository
+	verificationService VerificationService
+}
+
+func (s *accountUsecaseImpl) FetchMe(c context.Context, subject authz.Subject) (*dto.MeResponse, pkg.CustomError) {
+	customError := authz.Authorize(subject, authz.READ, authz.Resource{Type: authz.PROFILE, OwnerID: subject.UserID})
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	return s.me(c, subject)
+}
+
+// UpdateMe applies the profile changes right away. A new email only replaces
+// the current one once ConfirmEmailChange gets the code sent to it.
+func (s *accountUsecaseImpl) UpdateMe(c context.Context, subject authz.Subject, request *dto.UpdateMeRequest) (*dto.MeResponse, pkg.CustomError) {
+	customError := authz.Authorize(subject, authz.UPDATE, authz.Resource{Type: authz.PROFILE, OwnerID: subject.UserID})
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	customError = request.Validate(subject.Role)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	// the email is what a password reset is sent to, so only a login session
+	// may change it
+	if request.Email != nil && subject.Scopes != nil {
+		return nil, pkg.CustomError{
+			Code:    utils.FORBIDDEN,
+			Cause:   errors.New("email can't be changed with an api token"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	me, customError := s.me(c, subject)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	if request.Name != nil && *request.Name != me.Name {
+		customError = s.updateName(c, subject, *request.Name)
+		if customError.Cause != nil {
+			return nil, customError
+		}
+	}
+
+	if request.HasStudentProfile() {
+		customError = s.updateStudentProfile(c, subject.UserID, request)
+		if customError.Cause != nil {
+			return nil, customError
+		}
+	}
+
+	if request.HasTeacherProfile() {
+		customError = s.updateTeacherProfile(c, subject.UserID, request)
+		if customError.Cause != nil {
+			return nil, customError
+		}
+	}
+
+	pendingEmail := ""
+	if request.Email != nil && !strings.EqualFold(*request.Email, me.Email) {
+		customError = s.checkEmailAvailable(c, subject, *request.Email)
+		if customError.Cause != nil {
+			return nil, customError
+		}
+
+		customError = s.verificationService.SendEmailChangeCode(c, subject.UserID, subject.Role, *request.Email)
+		if customError.Cause != nil {
+			return nil, customError
+		}
+		pendingEmail = *request.Email
+	}
+
+	me, customError = s.me(c, subject)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+	me.PendingEmail = pendingEmail
+
+	return me, pkg.CustomError{}
+}
+
+func (s *accountUsecaseImpl) ConfirmEmailChange(c context.Context, subject authz.Subject, request *dto.ConfirmEmailChangeRequest) (*dto.MeResponse, pkg.CustomError) {
+	if request.Code == "" {
+		return nil, pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("code can't be blank"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	newEmail, customError := s.verificationService.CheckEmailChangeCode(c, subject.UserID, subject.Role, request.Code)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	switch subject.Role {
+	case utils.STUDENT_ROLE:
+		customError = s.studentRepo.UpdateStudentEmail(c, subject.UserID, newEmail)
+	case utils.TEACHER_ROLE:
+		customError = s.teacherRepo.UpdateTeacherEmail(c, subject.UserID, newEmail)
+	case utils.ADMIN_ROLE:
+		customError = s.adminRepo.UpdateAdminEmail(c, subject.UserID, newEmail)
+	default:
+		customError = unknownAccountTypeError()
+	}
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	return s.me(c, subject)
+}
+
+func (s *accountUsecaseImpl) me(c context.Context, subject authz.Subject) (*dto.MeResponse, pkg.CustomError) {
+	switch subject.Role {
+	case utils.STUDENT_ROLE:
+		student, customError := s.studentRepo.GetStudentByID(c, subject.UserID)
+		if customError.Cause != nil {
+			return nil, customError
+		}
+
+		me := &dto.MeResponse{
+			ID:    student.ID,
+			Role:  subject.Role,
+			Name:  student.Name,
+			Email: student.Email,
+			NIM:   student.NIM,
+
+			DeletionScheduledAt: student.DeletionScheduledAt,
+		}
+
+		profile, customError := s.studentRepo.GetStudentProfile(c, student.ID)
+		if customError.Cause != nil && !strings.Contains(customError.Cause.Error(), "no student profile found") {
+			return nil, customError
+		}
+		if profile != nil {
+			me.Profile = &dto.StudentProfileResponse{
+				DateOfBirth: profile.DateOfBirth,
+				Gender:      profile.Gender,
+				Address:     profile.Address,
+				Phone:       profile.Phone,
+			}
+		}
+
+		return me, pkg.CustomError{}
+	case utils.TEACHER_ROLE:
+		teacher, customError := s.teacherRepo.GetTeacherById(c, subject.UserID)
+		if customError.Cause != nil {
+			return nil, customError
+		}
+
+		profile, customError := s.teacherRepo.GetTeacherProfile(c, teacher.ID)
+		if customError.Cause != nil {
+			return nil, customError
+		}
+
+		me := &dto.MeResponse{
+			ID:    teacher.ID,
+			Role:  subject.Role,
+			Name:  teacher.Name,
+			Email: teacher.Email,
+			NPM:   teacher.NPM,
+		}
+		if profile != nil {
+			me.Profile = profile
+		}
+
+		return me, pkg.CustomError{}
+	case utils.ADMIN_ROLE:
+		admin, customError := s.adminRepo.GetAdminById(c, subject.UserID)
+		if customError.Cause != nil {
+			return nil, customError
+		}
+
+		return &dto.MeResponse{
+			ID:    admin.ID,
+			Role:  subject.Role,
+			Name:  admin.Name,
+			Email: admin.Email,
+		}, pkg.CustomError{}
+	default:
+		return nil, unknownAccountTypeError()
+	}
+}
+
+func (s *accountUsecaseImpl) updateName(c context.Context, subject authz.Subject, name string) pkg.CustomError {
+	switch subject.Role {
+	case utils.STUDENT_ROLE:
+		return s.studentRepo.UpdateStudentName(c, subject.UserID, name)
+	case utils.TEACHER_ROLE:
+		return s.teacherRepo.UpdateTeacherName(c, subject.UserID, name)
+	case utils.ADMIN_ROLE:
+		return s.adminRepo.UpdateAdminName(c, subject.UserID, name)
+	default:
+		return unknownAccountTypeError()
+	}
+}
+
+func (s *accountUsecaseImpl) updateStudentProfile(c context.Context, id uuid.UUID, request *dto.UpdateMeRequest) pkg.CustomError {
+	profile, customError := s.studentRepo.GetStudentProfile(c, id)
+	exists := customError.Cause == nil
+	if !exists {
+		if !strings.Contains(customError.Cause.Error(), "no student profile found") {
+			return customError
+		}
+		profile = &dto.StudentProfileRequest{ID: id}
+	}
+
+	if request.DateOfBirth != nil {
+		profile.DateOfBirth = *request.DateOfBirth
+	}
+	if request.Gender != nil {
+		profile.Gender = *request.Gender
+	}
+	if request.Address != nil {
+		profile.Address = *request.Address
+	}
+	if request.Phone != nil {
+		profile.Phone = *request.Phone
+	}
+
+	if !exists {
+		return s.studentRepo.AddStudentProfile(c, profile)
+	}
+
+	return s.studentRepo.EditStudentProfile(c, profile)
+}
+
+func (s *accountUsecaseImpl) updateTeacherProfile(c context.Context, id uuid.UUID, request *dto.UpdateMeRequest) pkg.CustomError {
+	profile, customError := s.teacherRepo.GetTeacherProfile(c, id)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	if profile == nil {
+		profile = &models.TeacherProfile{}
+	}
+	profile.ID = id
+
+	if request.Title != nil {
+		profile.Title = *request.Title
+	}
+	if request.Department != nil {
+		profile.Department = *request.Department
+	}
+	if request.OfficeHours != nil {
+		profile.OfficeHours = *request.OfficeHours
+	}
+	if request.Bio != nil {
+		profile.Bio = *request.Bio
+	}
+	if request.AvatarURL != nil {
+		profile.AvatarURL = *request.AvatarURL
+	}
+
+	return s.teacherRepo.SaveTeacherProfile(c, profile)
+}
+
+// checkEmailAvailable refuses an address another account of the same role
+// already uses, before a code is mailed to it.
+func (s *accountUsecaseImpl) checkEmailAvailable(c context.Context, subject authz.Subject, email string) pkg.CustomError {
+	var ownerId uuid.UUID
+	var customError pkg.CustomError
+	switch subject.Role {
+	case utils.STUDENT_ROLE:
+		var student *models.Student
+		student, customError = s.studentRepo.GetStudentByEmail(c, email)
+		if student != nil {
+			ownerId = student.ID
+		}
+	case utils.TEACHER_ROLE:
+		var teacher *models.Teacher
+		teacher, customError = s.teacherRepo.GetTeacherByEmail(c, email)
+		if teacher != nil {
+			ownerId = teacher.ID
+		}
+	case utils.ADMIN_ROLE:
+		var admin *models.Admin
+		admin, customError = s.adminRepo.GetAdminByEmail(c, email)
+		if admin != nil {
+			ownerId = admin.ID
+		}
+	default:
+		return unknownAccountTypeError()
+	}
+	if customError.Cause != nil && customError.Code != utils.BAD_REQUEST {
+		return customError
+	}
+
+	if ownerId != uuid.Nil && ownerId != subject.UserID {
+		return pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("email is already used by another account"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	return pkg.CustomError{}
+}
+
+func NewAccountUsecase(studentRepo repository.StudentRepository, teacherRepo repository.TeacherRepository, adminRepo repository.AdminRepository, verificationService VerificationService) AccountUsecase {
+	return &accountUsecaseImpl{
+		studentRepo:         studentRepo,
+		teacherRepo:         teacherRepo,
+		adminRepo:           adminRepo,
+		verificationService: verificationService,
+	}
+}
@@ -0,0 +1,291 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/rifkhia/lms-remake/internal/dto"
+	"github.com/rifkhia/lms-remake/internal/models"
+	"github.com/rifkhia/lms-remake/internal/pkg"
+	"github.com/rifkhia/lms-remake/internal/repository"
+	"github.com/rifkhia/lms-remake/internal/utils"
+	"github.com/spf13/viper"
+	"log"
+	"net/mail"
+)
+
+type AdminUsecase interface {
+	BootstrapAdmin(c context.Context) pkg.CustomError
+	Login(c context.Context, request *dto.AdminLoginRequest, client dto.ClientInfo) (interface{}, pkg.CustomError)
+	FetchUsers(c context.Context, role string, search string, status string) (interface{}, pkg.CustomError)
+	ApproveUser(c context.Context, role string, id uuid.UUID) pkg.CustomError
+	SuspendUser(c context.Context, role string, id uuid.UUID) pkg.CustomError
+	RestoreUser(c context.Context, role string, id uuid.UUID) pkg.CustomError
+	DeleteUser(c context.Context, role string, id uuid.UUID) pkg.CustomError
+	ResetUserPassword(c context.Context, role string, id uuid.UUID, request *dto.AdminResetPasswordRequest) (interface{}, pkg.CustomError)
+	ResetUserMFA(c context.Context, role string, id uuid.UUID) pkg.CustomError
+	ReassignClassTeacher(c context.Context, classId int, request *dto.ReassignClassTeacherRequest) pkg.CustomError
+}
+
+type adminUsecaseImpl struct {
+	adminRepo    repository.AdminRepository
+	studentRepo  repository.StudentRepository
+	teacherRepo  repository.TeacherRepository
+	classRepo    repository.ClassRepository
+	tokenService TokenService
+	loginGuard   LoginGuard
+	mfaUsecase   MFAUsecase
+}
+
+// BootstrapAdmin creates the first admin from ADMIN_EMAIL and ADMIN_PASSWORD
+// when the admins table is still empty. It does nothing once any admin exists.
+func (s *adminUsecaseImpl) BootstrapAdmin(c context.Context) pkg.CustomError {
+	email := viper.GetString("ADMIN_EMAIL")
+	password := viper.GetString("ADMIN_PASSWORD")
+	if email == "" || password == "" {
+		return pkg.CustomError{}
+	}
+
+	count, customError := s.adminRepo.CountAdmins(c)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	if count > 0 {
+		return pkg.CustomError{}
+	}
+
+	_, err := mail.ParseAddress(email)
+	if err != nil {
+		return pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("ADMIN_EMAIL is not a valid email"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	passwordHash, customError := hashNewPassword(password)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	admin := &models.Admin{
+		ID:       uuid.New(),
+		Name:     viper.GetString("ADMIN_NAME"),
+		Email:    email,
+		Password: passwordHash,
+	}
+
+	customError = s.adminRepo.CreateAdmin(c, admin)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	log.Printf("created bootstrap admin %s", email)
+
+	return pkg.CustomError{}
+}
+
+func (s *adminUsecaseImpl) Login(c context.Context, request *dto.AdminLoginRequest, client dto.ClientInfo) (interface{}, pkg.CustomError) {
+	if request.Email == "" || request.Password == "" {
+		return nil, pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("email or password can't be blank"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	customError := s.loginGuard.CheckLoginAllowed(c, request.Email, utils.ADMIN_ROLE, client.IPAddress)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	passwordHash := dummyPasswordHash()
+	admin, customError := s.adminRepo.GetAdminByEmail(c, request.Email)
+	if customError.Cause != nil && customError.Code != utils.BAD_REQUEST {
+		return nil, customError
+	}
+	if admin != nil {
+		passwordHash = admin.Password
+	}
+
+	customError = utils.ValidatePassword(passwordHash, request.Password)
+	if customError.Cause != nil || admin == nil {
+		customError = s.loginGuard.RecordLoginFailure(c, request.Email, utils.ADMIN_ROLE, client.IPAddress, utils.LOGIN_FAILURE_INVALID_CREDENTIALS)
+		if customError.Cause != nil {
+			return nil, customError
+		}
+		return nil, invalidCredentialsError()
+	}
+
+	customError = s.loginGuard.RecordLoginSuccess(c, request.Email, utils.ADMIN_ROLE, client.IPAddress)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	if utils.PasswordNeedsRehash(admin.Password) {
+		passwordHash, err := utils.GeneratePassword(request.Password)
+		if err == nil {
+			customError = s.adminRepo.UpdateAdminPassword(c, admin.ID, passwordHash)
+		}
+		if err != nil || customError.Cause != nil {
+			log.Printf("failed to rehash password for admin %s", admin.ID)
+		}
+	}
+
+	accessToken, refreshToken, customError := s.tokenService.IssueTokens(c, admin.ID, utils.ADMIN_ROLE, uuid.New(), client)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	return map[string]interface{}{
+		"access token":  accessToken,
+		"refresh token": refreshToken,
+	}, pkg.CustomError{}
+}
+
+func (s *adminUsecaseImpl) FetchUsers(c context.Context, role string, search string, status string) (interface{}, pkg.CustomError) {
+	switch role {
+	case utils.STUDENT_ROLE:
+		return s.adminRepo.FetchStudentAccounts(c, search, status)
+	case utils.TEACHER_ROLE:
+		return s.adminRepo.FetchTeacherAccounts(c, search, status)
+	default:
+		return nil, unknownAccountTypeError()
+	}
+}
+
+func (s *adminUsecaseImpl) ApproveUser(c context.Context, role string, id uuid.UUID) pkg.CustomError {
+	return s.adminRepo.ApproveUser(c, role, id)
+}
+
+// SuspendUser also signs the user out, so a suspension takes effect at once.
+func (s *adminUsecaseImpl) SuspendUser(c context.Context, role string, id uuid.UUID) pkg.CustomError {
+	customError := s.adminRepo.SuspendUser(c, role, id)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	return s.tokenService.RevokeAllUserTokens(c, id, role)
+}
+
+func (s *adminUsecaseImpl) RestoreUser(c context.Context, role string, id uuid.UUID) pkg.CustomError {
+	return s.adminRepo.RestoreUser(c, role, id)
+}
+
+func (s *adminUsecaseImpl) DeleteUser(c context.Context, role string, id uuid.UUID) pkg.CustomError {
+	customError := s.adminRepo.DeleteUser(c, role, id)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	return s.tokenService.RevokeAllUserTokens(c, id, role)
+}
+
+func (s *adminUsecaseImpl) ResetUserPassword(c context.Context, role string, id uuid.UUID, request *dto.AdminResetPasswordRequest) (interface{}, pkg.CustomError) {
+	password := request.Password
+	generated := password == ""
+	if generated {
+		token, err := utils.GenerateToken(12)
+		if err != nil {
+			return nil, pkg.CustomError{
+				Code:    utils.INTERNAL_SERVER_ERROR,
+				Cause:   err,
+				Service: utils.USECASE_SERVICE,
+			}
+		}
+		password = token
+	}
+
+	passwordHash, customError := hashNewPassword(password)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	switch role {
+	case utils.STUDENT_ROLE:
+		_, customError = s.studentRepo.GetStudentByID(c, id)
+		if customError.Cause == nil {
+			customError = s.studentRepo.UpdateStudentPassword(c, id, passwordHash)
+		}
+	case utils.TEACHER_ROLE:
+		_, customError = s.teacherRepo.GetTeacherById(c, id)
+		if customError.Cause == nil {
+			customError = s.teacherRepo.UpdateTeacherPassword(c, id, passwordHash)
+		}
+	default:
+		customError = unknownAccountTypeError()
+	}
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	customError = s.tokenService.RevokeAllUserTokens(c, id, role)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	if !generated {
+		return nil, pkg.CustomError{}
+	}
+
+	return map[string]interface{}{
+		"temporary_password": password,
+	}, pkg.CustomError{}
+}
+
+// ResetUserMFA turns two-factor login off for a user who lost both the
+// authenticator and the recovery codes. They can enroll again after logging in.
+func (s *adminUsecaseImpl) ResetUserMFA(c context.Context, role string, id uuid.UUID) pkg.CustomError {
+	var customError pkg.CustomError
+	switch role {
+	case utils.STUDENT_ROLE:
+		_, customError = s.studentRepo.GetStudentByID(c, id)
+	case utils.TEACHER_ROLE:
+		_, customError = s.teacherRepo.GetTeacherById(c, id)
+	default:
+		customError = unknownAccountTypeError()
+	}
+	if customError.Cause != nil {
+		return customError
+	}
+
+	return s.mfaUsecase.ResetMFA(c, id, role)
+}
+
+func (s *adminUsecaseImpl) ReassignClassTeacher(c context.Context, classId int, request *dto.ReassignClassTeacherRequest) pkg.CustomError {
+	teacher, customError := s.teacherRepo.GetTeacherById(c, request.TeacherID)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	if teacher.ApprovedAt == nil || teacher.SuspendedAt != nil {
+		return pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("teacher is not an active account"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	return s.classRepo.UpdateClassTeacher(c, classId, teacher.ID)
+}
+
+func unknownAccountTypeError() pkg.CustomError {
+	return pkg.CustomError{
+		Code:    utils.BAD_REQUEST,
+		Cause:   errors.New("account type must be students or teachers"),
+		Service: utils.USECASE_SERVICE,
+	}
+}
+
+func NewAdminUsecase(adminRepo repository.AdminRepository, studentRepo repository.StudentRepository, teacherRepo repository.TeacherRepository, classRepo repository.ClassRepository, tokenService TokenService, loginGuard LoginGuard, mfaUsecase MFAUsecase) AdminUsecase {
+	return &adminUsecaseImpl{
+		adminRepo:    adminRepo,
+		studentRepo:  studentRepo,
+		teacherRepo:  teacherRepo,
+		classRepo:    classRepo,
+		tokenService: tokenService,
+		loginGuard:   loginGuard,
+		mfaUsecase:   mfaUsecase,
+	}
+}
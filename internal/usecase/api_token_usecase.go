@@ -0,0 +1,156 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/rifkhia/lms-remake/internal/authz"
+	"github.com/rifkhia/lms-remake/internal/dto"
+	"github.com/rifkhia/lms-remake/internal/models"
+	"github.com/rifkhia/lms-remake/internal/pkg"
+	"github.com/rifkhia/lms-remake/internal/repository"
+	"github.com/rifkhia/lms-remake/internal/utils"
+	"strings"
+	"time"
+)
+
+// APITokenUsecase manages the personal API tokens teachers and admins use for
+// scripts. Students can't create them.
+type APITokenUsecase interface {
+	CreateAPIToken(c context.Context, subject authz.Subject, request *dto.CreateAPITokenRequest) (interface{}, pkg.CustomError)
+	FetchAPITokens(c context.Context, subject authz.Subject) ([]*models.APIToken, pkg.CustomError)
+	RevokeAPIToken(c context.Context, subject authz.Subject, id uuid.UUID) pkg.CustomError
+}
+
+type apiTokenUsecaseImpl struct {
+	apiTokenRepo repository.APITokenRepository
+	tokenService TokenService
+}
+
+func (s *apiTokenUsecaseImpl) CreateAPIToken(c context.Context, subject authz.Subject, request *dto.CreateAPITokenRequest) (interface{}, pkg.CustomError) {
+	if subject.Role != utils.TEACHER_ROLE && subject.Role != utils.ADMIN_ROLE {
+		return nil, pkg.CustomError{
+			Code:    utils.FORBIDDEN,
+			Cause:   errors.New("only teachers and admins can create api tokens"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	request.Name = strings.TrimSpace(request.Name)
+	if request.Name == "" || len(request.Name) > 100 {
+		return nil, pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("name must be between 1 and 100 characters"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	customError := validateScopes(subject.Role, request.Scopes)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	if request.ExpiresInDays < 0 {
+		return nil, pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("expires_in_days can't be negative"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	tokens, customError := s.apiTokenRepo.GetAPITokensByUser(c, subject.UserID, subject.Role)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	active := 0
+	for _, token := range tokens {
+		if token.RevokedAt == nil && (token.ExpiresAt == nil || token.ExpiresAt.After(time.Now())) {
+			active++
+		}
+	}
+
+	if active >= utils.API_TOKEN_MAX_PER_USER {
+		return nil, pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   fmt.Errorf("you can't have more than %d active api tokens", utils.API_TOKEN_MAX_PER_USER),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	token := &models.APIToken{
+		UserID: subject.UserID,
+		Role:   subject.Role,
+		Name:   request.Name,
+		Scopes: request.Scopes,
+	}
+	if request.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, request.ExpiresInDays)
+		token.ExpiresAt = &expiresAt
+	}
+
+	plainToken, customError := s.tokenService.IssueAPIToken(c, token)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	return map[string]interface{}{
+		"token":     plainToken,
+		"api_token": token,
+	}, pkg.CustomError{}
+}
+
+func (s *apiTokenUsecaseImpl) FetchAPITokens(c context.Context, subject authz.Subject) ([]*models.APIToken, pkg.CustomError) {
+	return s.apiTokenRepo.GetAPITokensByUser(c, subject.UserID, subject.Role)
+}
+
+func (s *apiTokenUsecaseImpl) RevokeAPIToken(c context.Context, subject authz.Subject, id uuid.UUID) pkg.CustomError {
+	return s.apiTokenRepo.RevokeAPIToken(c, id, subject.UserID, subject.Role)
+}
+
+// validateScopes rejects unknown scopes and users:manage for anyone but admins.
+func validateScopes(role string, scopes []string) pkg.CustomError {
+	if len(scopes) == 0 {
+		return pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   fmt.Errorf("scopes can't be empty, choose from %s", strings.Join(authz.Scopes, ", ")),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	for _, scope := range scopes {
+		known := false
+		for _, candidate := range authz.Scopes {
+			if scope == candidate {
+				known = true
+				break
+			}
+		}
+
+		if !known {
+			return pkg.CustomError{
+				Code:    utils.BAD_REQUEST,
+				Cause:   fmt.Errorf("unknown scope %q", scope),
+				Service: utils.USECASE_SERVICE,
+			}
+		}
+
+		if scope == authz.USERS_MANAGE && role != utils.ADMIN_ROLE {
+			return pkg.CustomError{
+				Code:    utils.FORBIDDEN,
+				Cause:   fmt.Errorf("only admins can use the %s scope", authz.USERS_MANAGE),
+				Service: utils.USECASE_SERVICE,
+			}
+		}
+	}
+
+	return pkg.CustomError{}
+}
+
+func NewAPITokenUsecase(apiTokenRepo repository.APITokenRepository, tokenService TokenService) APITokenUsecase {
+	return &apiTokenUsecaseImpl{
+		apiTokenRepo: apiTokenRepo,
+		tokenService: tokenService,
+	}
+}
@@ -0,0 +1,354 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/rifkhia/lms-remake/internal/dto"
+	"github.com/rifkhia/lms-remake/internal/mailer"
+	"github.com/rifkhia/lms-remake/internal/models"
+	"github.com/rifkhia/lms-remake/internal/pkg"
+	"github.com/rifkhia/lms-remake/internal/repository"
+	"github.com/rifkhia/lms-remake/internal/utils"
+	"github.com/spf13/viper"
+	"strings"
+	"time"
+)
+
+type AuthUsecase interface {
+	RefreshToken(c context.Context, request *dto.RefreshTokenRequest, client dto.ClientInfo) (interface{}, pkg.CustomError)
+	Logout(c context.Context, userId uuid.UUID, tokenId string, expiresAt time.Time, sessionId uuid.UUID, request *dto.LogoutRequest) pkg.CustomError
+	ForgotPassword(c context.Context, request *dto.ForgotPasswordRequest) pkg.CustomError
+	ResetPassword(c context.Context, request *dto.ResetPasswordRequest) pkg.CustomError
+	ChangePassword(c context.Context, userId uuid.UUID, role string, request *dto.ChangePasswordRequest, client dto.ClientInfo) (interface{}, pkg.CustomError)
+}
+
+type authUsecaseImpl struct {
+	authRepo     repository.AuthRepository
+	adminRepo    repository.AdminRepository
+	studentRepo  repository.StudentRepository
+	teacherRepo  repository.TeacherRepository
+	tokenService TokenService
+	mailer       mailer.Mailer
+}
+
+func (s *authUsecaseImpl) RefreshToken(c context.Context, request *dto.RefreshTokenRequest, client dto.ClientInfo) (interface{}, pkg.CustomError) {
+	if request.RefreshToken == "" {
+		return nil, pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("refresh token can't be blank"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	claims, customError := s.tokenService.VerifyRefreshToken(c, request.RefreshToken)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	tokenId, err := uuid.Parse(claims.RegisteredClaims.ID)
+	if err != nil {
+		return nil, pkg.CustomError{
+			Code:    utils.UNAUTHORIZED,
+			Cause:   errors.New("invalid refresh token"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	storedToken, customError := s.authRepo.GetRefreshTokenById(c, tokenId)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	if storedToken.RevokedAt != nil || storedToken.ExpiresAt.Before(time.Now()) ||
+		storedToken.UserID != claims.UserID || storedToken.Role != claims.Role {
+		return nil, pkg.CustomError{
+			Code:    utils.UNAUTHORIZED,
+			Cause:   errors.New("refresh token is no longer valid"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	consumed, customError := s.authRepo.MarkRefreshTokenUsed(c, storedToken.ID)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	// a refresh token that was already exchanged is being replayed, so whoever
+	// holds this family can't be trusted anymore
+	if !consumed {
+		customError = s.tokenService.RevokeRefreshTokenFamily(c, storedToken.FamilyID)
+		if customError.Cause != nil {
+			return nil, customError
+		}
+		return nil, pkg.CustomError{
+			Code:    utils.UNAUTHORIZED,
+			Cause:   errors.New("refresh token reuse detected, please login again"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	accessToken, refreshToken, customError := s.tokenService.IssueTokens(c, storedToken.UserID, storedToken.Role, storedToken.FamilyID, client)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	return map[string]interface{}{
+		"access token":  accessToken,
+		"refresh token": refreshToken,
+	}, pkg.CustomError{}
+}
+
+func (s *authUsecaseImpl) Logout(c context.Context, userId uuid.UUID, tokenId string, expiresAt time.Time, sessionId uuid.UUID, request *dto.LogoutRequest) pkg.CustomError {
+	customError := s.tokenService.RevokeAccessToken(c, tokenId, expiresAt)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	// access tokens issued before sessions existed carry no session id, those
+	// still need the refresh token to end their session
+	if sessionId != uuid.Nil {
+		return s.tokenService.RevokeRefreshTokenFamily(c, sessionId)
+	}
+
+	if request.RefreshToken == "" {
+		return pkg.CustomError{}
+	}
+
+	// an expired or forged refresh token can't be exchanged anyway, so there is
+	// nothing left to revoke for it
+	refreshClaims, customError := s.tokenService.VerifyRefreshToken(c, request.RefreshToken)
+	if customError.Cause != nil {
+		return pkg.CustomError{}
+	}
+
+	if refreshClaims.UserID != userId {
+		return pkg.CustomError{
+			Code:    utils.FORBIDDEN,
+			Cause:   errors.New("refresh token belongs to another user"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	customError = s.tokenService.RevokeRefreshTokenFamily(c, refreshClaims.FamilyID)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	return pkg.CustomError{}
+}
+
+// ForgotPassword mails a single-use reset token. It answers the same way whether
+// or not the email is registered, so it can't be used to probe for accounts.
+func (s *authUsecaseImpl) ForgotPassword(c context.Context, request *dto.ForgotPasswordRequest) pkg.CustomError {
+	role := strings.ToUpper(request.Role)
+	if request.Email == "" || (role != utils.STUDENT_ROLE && role != utils.TEACHER_ROLE) {
+		return pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("email and role (student or teacher) are required"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	userId, _, customError := s.findUserByEmail(c, request.Email, role)
+	if customError.Cause != nil {
+		if customError.Code == utils.BAD_REQUEST {
+			return pkg.CustomError{}
+		}
+		return customError
+	}
+
+	token, err := utils.GenerateToken(32)
+	if err != nil {
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	expiry := time.Duration(viper.GetInt("PASSWORD_RESET_EXPIRY")) * time.Minute
+	customError = s.authRepo.CreatePasswordResetToken(c, &models.PasswordResetToken{
+		UserID:    userId,
+		Role:      role,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: time.Now().Add(expiry),
+	})
+	if customError.Cause != nil {
+		return customError
+	}
+
+	resetLink := token
+	if resetUrl := viper.GetString("PASSWORD_RESET_URL"); resetUrl != "" {
+		resetLink = fmt.Sprintf("%s?token=%s", resetUrl, token)
+	}
+
+	body := fmt.Sprintf("Use the following to reset your password:\n\n%s\n\nIt expires in %d minutes and can only be used once. If you did not ask for a reset, you can ignore this email.", resetLink, int(expiry.Minutes()))
+	err = s.mailer.Send(request.Email, "Reset your password", body)
+	if err != nil {
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	return pkg.CustomError{}
+}
+
+func (s *authUsecaseImpl) ResetPassword(c context.Context, request *dto.ResetPasswordRequest) pkg.CustomError {
+	if request.Token == "" {
+		return pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("reset token can't be blank"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	passwordHash, customError := hashNewPassword(request.Password)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	resetToken, customError := s.authRepo.UsePasswordResetToken(c, utils.HashToken(request.Token))
+	if customError.Cause != nil {
+		return customError
+	}
+
+	customError = s.updatePassword(c, resetToken.UserID, resetToken.Role, passwordHash)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	return s.tokenService.RevokeAllUserTokens(c, resetToken.UserID, resetToken.Role)
+}
+
+// ChangePassword signs out every other session and hands back a fresh token
+// pair for the caller.
+func (s *authUsecaseImpl) ChangePassword(c context.Context, userId uuid.UUID, role string, request *dto.ChangePasswordRequest, client dto.ClientInfo) (interface{}, pkg.CustomError) {
+	currentHash, customError := s.findUserPassword(c, userId, role)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	customError = utils.ValidatePassword(currentHash, request.CurrentPassword)
+	if customError.Cause != nil {
+		return nil, pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("current password is incorrect"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	passwordHash, customError := hashNewPassword(request.NewPassword)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	customError = s.updatePassword(c, userId, role, passwordHash)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	sessionId := uuid.New()
+	customError = s.tokenService.RevokeUserTokensExcept(c, userId, role, sessionId)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	accessToken, refreshToken, customError := s.tokenService.IssueTokens(c, userId, role, sessionId, client)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	return map[string]interface{}{
+		"access token":  accessToken,
+		"refresh token": refreshToken,
+	}, pkg.CustomError{}
+}
+
+func hashNewPassword(password string) (string, pkg.CustomError) {
+	err := utils.ValidatePasswordPolicy(password)
+	if err != nil {
+		return "", pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   err,
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	passwordHash, err := utils.GeneratePassword(password)
+	if err != nil {
+		return "", pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	return passwordHash, pkg.CustomError{}
+}
+
+func (s *authUsecaseImpl) findUserByEmail(c context.Context, email string, role string) (uuid.UUID, string, pkg.CustomError) {
+	if role == utils.TEACHER_ROLE {
+		teacher, customError := s.teacherRepo.GetTeacherByEmail(c, email)
+		if customError.Cause != nil {
+			return uuid.Nil, "", customError
+		}
+		return teacher.ID, teacher.Password, pkg.CustomError{}
+	}
+
+	student, customError := s.studentRepo.GetStudentByEmail(c, email)
+	if customError.Cause != nil {
+		return uuid.Nil, "", customError
+	}
+	return student.ID, student.Password, pkg.CustomError{}
+}
+
+func (s *authUsecaseImpl) findUserPassword(c context.Context, userId uuid.UUID, role string) (string, pkg.CustomError) {
+	if role == utils.ADMIN_ROLE {
+		admin, customError := s.adminRepo.GetAdminById(c, userId)
+		if customError.Cause != nil {
+			return "", customError
+		}
+		return admin.Password, pkg.CustomError{}
+	}
+
+	if role == utils.TEACHER_ROLE {
+		teacher, customError := s.teacherRepo.GetTeacherById(c, userId)
+		if customError.Cause != nil {
+			return "", customError
+		}
+		return teacher.Password, pkg.CustomError{}
+	}
+
+	student, customError := s.studentRepo.GetStudentByID(c, userId)
+	if customError.Cause != nil {
+		return "", customError
+	}
+	return student.Password, pkg.CustomError{}
+}
+
+func (s *authUsecaseImpl) updatePassword(c context.Context, userId uuid.UUID, role string, passwordHash string) pkg.CustomError {
+	if role == utils.ADMIN_ROLE {
+		return s.adminRepo.UpdateAdminPassword(c, userId, passwordHash)
+	}
+
+	if role == utils.TEACHER_ROLE {
+		return s.teacherRepo.UpdateTeacherPassword(c, userId, passwordHash)
+	}
+
+	return s.studentRepo.UpdateStudentPassword(c, userId, passwordHash)
+}
+
+func NewAuthUsecase(authRepo repository.AuthRepository, adminRepo repository.AdminRepository, studentRepo repository.StudentRepository, teacherRepo repository.TeacherRepository, tokenService TokenService, mailer mailer.Mailer) AuthUsecase {
+	return &authUsecaseImpl{
+		authRepo:     authRepo,
+		adminRepo:    adminRepo,
+		studentRepo:  studentRepo,
+		teacherRepo:  teacherRepo,
+		tokenService: tokenService,
+		mailer:       mailer,
+	}
+}
@@ -0,0 +1,260 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/rifkhia/lms-remake/internal/authz"
+	"github.com/rifkhia/lms-remake/internal/ical"
+	"github.com/rifkhia/lms-remake/internal/models"
+	"github.com/rifkhia/lms-remake/internal/pkg"
+	"github.com/rifkhia/lms-remake/internal/repository"
+	"github.com/rifkhia/lms-remake/internal/utils"
+	"github.com/spf13/viper"
+	"strings"
+	"time"
+)
+
+// CalendarUsecase exports the schedule of a student or teacher as an
+// iCalendar file: every class meeting as a weekly event bounded by its term,
+// and every assignment deadline. Calendar apps subscribe to it through a
+// private feed URL, since they can't send a bearer token.
+type CalendarUsecase interface {
+	ExportCalendar(c context.Context, subject authz.Subject, deadlines string) ([]byte, pkg.CustomError)
+	ExportCalendarFeed(c context.Context, token string, deadlines string) ([]byte, pkg.CustomError)
+	CreateCalendarFeed(c context.Context, subject authz.Subject) (interface{}, pkg.CustomError)
+	DeleteCalendarFeed(c context.Context, subject authz.Subject) pkg.CustomError
+}
+
+type calendarUsecaseImpl struct {
+	calendarRepo repository.CalendarRepository
+	classRepo    repository.ClassRepository
+	studentRepo  repository.StudentRepository
+	termRepo     repository.TermRepository
+}
+
+func (s *calendarUsecaseImpl) ExportCalendar(c context.Context, subject authz.Subject, deadlines string) ([]byte, pkg.CustomError) {
+	customError := requireScheduleOwner(subject.Role)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	return s.buildCalendar(c, subject.UserID, subject.Role, deadlines)
+}
+
+func (s *calendarUsecaseImpl) ExportCalendarFeed(c context.Context, token string, deadlines string) ([]byte, pkg.CustomError) {
+	userId, role, customError := s.calendarRepo.UseCalendarFeed(c, utils.HashToken(token))
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	return s.buildCalendar(c, userId, role, deadlines)
+}
+
+// CreateCalendarFeed returns a new feed URL, which replaces the old one. Only
+// a hash of its token is kept, so it is shown once.
+func (s *calendarUsecaseImpl) CreateCalendarFeed(c context.Context, subject authz.Subject) (interface{}, pkg.CustomError) {
+	customError := requireScheduleOwner(subject.Role)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	token, err := utils.GenerateToken(32)
+	if err != nil {
+		return nil, pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	customError = s.calendarRepo.SetCalendarFeed(c, subject.UserID, subject.Role, utils.HashToken(token))
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	link := fmt.Sprintf("/v1/calendar/%s.ics", token)
+	if feedUrl := viper.GetString("CALENDAR_FEED_URL"); feedUrl != "" {
+		link = fmt.Sprintf("%s/%s.ics", strings.TrimSuffix(feedUrl, "/"), token)
+	}
+
+	return map[string]interface{}{
+		"url": link,
+	}, pkg.CustomError{}
+}
+
+func (s *calendarUsecaseImpl) DeleteCalendarFeed(c context.Context, subject authz.Subject) pkg.CustomError {
+	customError := requireScheduleOwner(subject.Role)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	return s.calendarRepo.DeleteCalendarFeed(c, subject.UserID, subject.Role)
+}
+
+func requireScheduleOwner(role string) pkg.CustomError {
+	if role != utils.STUDENT_ROLE && role != utils.TEACHER_ROLE {
+		return pkg.CustomError{
+			Code:    utils.FORBIDDEN,
+			Cause:   errors.New("only students and teachers have a schedule"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	return pkg.CustomError{}
+}
+
+// buildCalendar writes deadlines as events unless deadlines is "todos", for
+// calendar apps that keep tasks apart.
+func (s *calendarUsecaseImpl) buildCalendar(c context.Context, userId uuid.UUID, role string, deadlines string) ([]byte, pkg.CustomError) {
+	if deadlines != "" && deadlines != "events" && deadlines != "todos" {
+		return nil, pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("deadlines must be events or todos"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	var schedule []*models.ScheduleEntry
+	var dues []*models.Deadline
+	var customError pkg.CustomError
+	if role == utils.STUDENT_ROLE {
+		schedule, customError = s.studentRepo.FetchStudentClass(c, userId, nil)
+		if customError.Cause == nil {
+			dues, customError = s.calendarRepo.GetStudentDeadlines(c, userId)
+		}
+	} else {
+		schedule, customError = s.classRepo.GetTeacherSchedule(c, userId, nil)
+		if customError.Cause == nil {
+			dues, customError = s.calendarRepo.GetTeacherDeadlines(c, userId)
+		}
+	}
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	meetings := make([]*models.ClassMeeting, len(schedule))
+	for i, entry := range schedule {
+		meetings[i] = &entry.ClassMeeting
+	}
+
+	customError = formatMeetings(meetings)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	terms, customError := s.termRepo.GetTerms(c)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	termsById := make(map[int]*models.Term)
+	for _, term := range terms {
+		termsById[term.ID] = term
+	}
+
+	now := time.Now()
+	calendar := &ical.Calendar{
+		Name:  "LMS schedule",
+		Stamp: now,
+	}
+
+	for _, entry := range schedule {
+		var term *models.Term
+		if entry.TermId != nil {
+			term = termsById[*entry.TermId]
+		}
+
+		event, ok := meetingEvent(entry, term, now)
+		if ok {
+			calendar.Events = append(calendar.Events, event)
+		}
+	}
+
+	for _, due := range dues {
+		uid := fmt.Sprintf("assignment-%d@lms-remake", due.SubmissionId)
+		summary := fmt.Sprintf("%s due (%s)", due.Title, due.ClassName)
+		if deadlines == "todos" {
+			calendar.Todos = append(calendar.Todos, ical.Todo{
+				UID:         uid,
+				Summary:     summary,
+				Description: due.Description,
+				Due:         due.Deadline.UTC(),
+			})
+			continue
+		}
+
+		calendar.Events = append(calendar.Events, ical.Event{
+			UID:         uid,
+			Summary:     summary,
+			Description: due.Description,
+			Start:       due.Deadline.UTC(),
+			End:         due.Deadline.UTC(),
+		})
+	}
+
+	return calendar.Encode(), pkg.CustomError{}
+}
+
+// meetingEvent repeats a meeting weekly from the first matching day of its
+// term until the term's last day. A class without a term repeats from this
+// week on, with no end. It reports false when the term has no such day.
+func meetingEvent(entry *models.ScheduleEntry, term *models.Term, now time.Time) (ical.Event, bool) {
+	location, err := time.LoadLocation(entry.Timezone)
+	if err != nil {
+		location = time.UTC
+	}
+
+	from := now.In(location)
+	from = from.AddDate(0, 0, -isoWeekday(from)+1)
+	var until *time.Time
+	if term != nil {
+		from = term.StartsOn
+		end := time.Date(term.EndsOn.Year(), term.EndsOn.Month(), term.EndsOn.Day(), 23, 59, 59, 0, location)
+		until = &end
+	}
+
+	day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, location)
+	day = day.AddDate(0, 0, (entry.Weekday-isoWeekday(day)+7)%7)
+
+	start := atClock(day, entry.StartTime)
+	if until != nil && start.After(*until) {
+		return ical.Event{}, false
+	}
+
+	return ical.Event{
+		UID:      fmt.Sprintf("class-meeting-%d@lms-remake", entry.ID),
+		Summary:  entry.Name,
+		Location: entry.Room,
+		Start:    start,
+		End:      atClock(day, entry.EndTime),
+		Weekly:   true,
+		Until:    until,
+	}, true
+}
+
+// isoWeekday is 1 for monday up to 7 for sunday, like ClassMeeting.Weekday.
+func isoWeekday(t time.Time) int {
+	if t.Weekday() == time.Sunday {
+		return 7
+	}
+
+	return int(t.Weekday())
+}
+
+// atClock is day at a "15:04" time.
+func atClock(day time.Time, clock string) time.Time {
+	minutes := clockMinutes(clock)
+
+	return time.Date(day.Year(), day.Month(), day.Day(), minutes/60, minutes%60, 0, 0, day.Location())
+}
+
+func NewCalendarUsecase(calendarRepo repository.CalendarRepository, classRepo repository.ClassRepository, studentRepo repository.StudentRepository, termRepo repository.TermRepository) CalendarUsecase {
+	return &calendarUsecaseImpl{
+		calendarRepo: calendarRepo,
+		classRepo:    classRepo,
+		studentRepo:  studentRepo,
+		termRepo:     termRepo,
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/google/uuid"
+	"github.com/rifkhia/lms-remake/internal/authz"
 	"github.com/rifkhia/lms-remake/internal/dto"
 	"github.com/rifkhia/lms-remake/internal/models"
 	"github.com/rifkhia/lms-remake/internal/pkg"
@@ -12,44 +13,174 @@ import (
 	"github.com/rifkhia/lms-remake/internal/utils"
 	"github.com/spf13/viper"
 	storage_go "github.com/supabase-community/storage-go"
+	"log"
 	"mime/multipart"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type ClassUsecase interface {
-	FetchClassById(c context.Context, id int) (*models.Class, pkg.CustomError)
+	FetchClassById(c context.Context, subject authz.Subject, id int) (*models.Class, pkg.CustomError)
 	FetchClassByTeacherId(c context.Context, teacherId int) ([]*models.Class, pkg.CustomError)
-	FetchClassByName(c context.Context, name string) ([]*dto.ClassByNameResponse, pkg.CustomError)
-	CreateClass(c context.Context, request *dto.ClassCreate, teacherId uuid.UUID) pkg.CustomError
+	FetchClassByName(c context.Context, name string, term string) ([]*dto.ClassByNameResponse, pkg.CustomError)
+	CreateClass(c context.Context, subject authz.Subject, request *dto.ClassCreate) pkg.CustomError
+	UpdateClass(c context.Context, subject authz.Subject, classId int, request *dto.UpdateClassRequest) pkg.CustomError
+	CloneClass(c context.Context, subject authz.Subject, classId int, request *dto.CloneClassRequest) (int, pkg.CustomError)
+	CheckScheduleConflicts(c context.Context, subject authz.Subject, classId int) ([]*models.ScheduleConflict, pkg.CustomError)
+	ArchiveClass(c context.Context, subject authz.Subject, classId int) pkg.CustomError
+	UnarchiveClass(c context.Context, subject authz.Subject, classId int) pkg.CustomError
+	DeleteClass(c context.Context, subject authz.Subject, classId int) pkg.CustomError
+	RestoreClass(c context.Context, subject authz.Subject, classId int) pkg.CustomError
 	CheckIfStudentInClass(c context.Context, studentId uuid.UUID, classId int) (bool, pkg.CustomError)
-	JoinClass(c context.Context, studentId uuid.UUID, classId int, key string) pkg.CustomError
-	LeftClass(c context.Context, classId int, studentId uuid.UUID) pkg.CustomError
-	CreateSectionClass(c context.Context, request *models.SectionClass) pkg.CustomError
-	AddSubmissionTeacher(c context.Context, request *models.Submission) pkg.CustomError
-	AddSubmissionStudent(c context.Context, request *dto.StudentSubmissionRequest, file *multipart.FileHeader) pkg.CustomError
-	FetchSectionClassById(c context.Context, id int) (*models.SectionClass, pkg.CustomError)
-	FetchSubmissionBySection(c context.Context, sectionClassId int, teacherId uuid.UUID) ([]*models.StudentSubmission, pkg.CustomError)
+	JoinClass(c context.Context, subject authz.Subject, classId int, request *dto.JoinClassRequest) (string, pkg.CustomError)
+	FetchClassInvite(c context.Context, subject authz.Subject, token string) (*dto.ClassByNameResponse, pkg.CustomError)
+	JoinClassByInvite(c context.Context, subject authz.Subject, token string) (string, pkg.CustomError)
+	RotateClassKey(c context.Context, subject authz.Subject, classId int, request *dto.RotateClassKeyRequest) (interface{}, pkg.CustomError)
+	DisableClassKey(c context.Context, subject authz.Subject, classId int) pkg.CustomError
+	CreateClassInvite(c context.Context, subject authz.Subject, classId int, request *dto.CreateClassInviteRequest) (interface{}, pkg.CustomError)
+	FetchClassInvites(c context.Context, subject authz.Subject, classId int) ([]*models.ClassInvite, pkg.CustomError)
+	RevokeClassInvite(c context.Context, subject authz.Subject, classId int, inviteId int) pkg.CustomError
+	WithdrawJoinRequest(c context.Context, subject authz.Subject, classId int) pkg.CustomError
+	LeftClass(c context.Context, subject authz.Subject, classId int) pkg.CustomError
+	UpdateClassEnrollment(c context.Context, subject authz.Subject, classId int, request *dto.UpdateEnrollmentRequest) pkg.CustomError
+	FetchEnrollmentRequests(c context.Context, subject authz.Subject, classId int, status string) ([]*models.EnrollmentRequest, pkg.CustomError)
+	ApproveEnrollmentRequest(c context.Context, subject authz.Subject, classId int, requestId int) (string, pkg.CustomError)
+	RejectEnrollmentRequest(c context.Context, subject authz.Subject, classId int, requestId int) pkg.CustomError
+	EnrollStudent(c context.Context, subject authz.Subject, classId int, request *dto.EnrollStudentRequest) (string, pkg.CustomError)
+	CreateSectionClass(c context.Context, subject authz.Subject, request *models.SectionClass) pkg.CustomError
+	AddSubmissionTeacher(c context.Context, subject authz.Subject, request *models.Submission, file *multipart.FileHeader) pkg.CustomError
+	AddSubmissionStudent(c context.Context, subject authz.Subject, request *dto.StudentSubmissionRequest, file *multipart.FileHeader) pkg.CustomError
+	FetchSectionClassById(c context.Context, subject authz.Subject, id int) (*models.SectionClass, pkg.CustomError)
+	FetchSubmissionBySection(c context.Context, subject authz.Subject, sectionClassId int) ([]*models.StudentSubmission, pkg.CustomError)
+	FetchClassStaff(c context.Context, subject authz.Subject, classId int) ([]*models.ClassStaff, pkg.CustomError)
+	InviteClassStaff(c context.Context, subject authz.Subject, classId int, request *dto.InviteClassStaffRequest) pkg.CustomError
+	AcceptClassStaffInvite(c context.Context, subject authz.Subject, classId int) pkg.CustomError
+	RemoveClassStaff(c context.Context, subject authz.Subject, classId int, teacherId uuid.UUID) pkg.CustomError
 }
 
 type classUsecaseImpl struct {
 	classRepo   repository.ClassRepository
 	studentRepo repository.StudentRepository
+	teacherRepo repository.TeacherRepository
+	termRepo    repository.TermRepository
 }
 
-func (s *classUsecaseImpl) FetchClassById(c context.Context, id int) (*models.Class, pkg.CustomError) {
-	classResult, err := s.classRepo.GetClassByID(c, id)
+// classResource loads the class facts the policy needs for a resource inside it.
+func (s *classUsecaseImpl) classResource(c context.Context, subject authz.Subject, resourceType authz.ResourceType, classId int) (*models.Class, authz.Resource, pkg.CustomError) {
+	classResult, customError := s.classRepo.GetClassByID(c, classId)
+	if customError.Cause != nil {
+		return nil, authz.Resource{}, customError
+	}
+
+	resource := authz.Resource{
+		Type: resourceType,
+	}
+
+	switch subject.Role {
+	case utils.STUDENT_ROLE:
+		resource.IsMember, customError = s.classRepo.CheckStudentClassExists(c, classId, subject.UserID)
+	case utils.TEACHER_ROLE:
+		resource.StaffRole, customError = s.classRepo.GetClassStaffRole(c, classId, subject.UserID)
+	}
+	if customError.Cause != nil {
+		return nil, authz.Resource{}, customError
+	}
+
+	return classResult, resource, pkg.CustomError{}
+}
+
+// sectionResource is classResource for the class a section belongs to.
+func (s *classUsecaseImpl) sectionResource(c context.Context, subject authz.Subject, resourceType authz.ResourceType, sectionId int) (*models.SectionClass, *models.Class, authz.Resource, pkg.CustomError) {
+	sectionClass, customError := s.classRepo.GetClassSectionById(c, sectionId)
+	if customError.Cause != nil {
+		return nil, nil, authz.Resource{}, customError
+	}
+
+	classResult, resource, customError := s.classResource(c, subject, resourceType, sectionClass.ClassId)
+	if customError.Cause != nil {
+		return nil, nil, authz.Resource{}, customError
+	}
+
+	return sectionClass, classResult, resource, pkg.CustomError{}
+}
+
+// classMeetings returns the meetings of the given classes by class id, ready
+// to be shown.
+func (s *classUsecaseImpl) classMeetings(c context.Context, classIds ...int) (map[int][]*models.ClassMeeting, pkg.CustomError) {
+	meetings, customError := s.classRepo.GetClassMeetings(c, classIds)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	customError = formatMeetings(meetings)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	byClass := make(map[int][]*models.ClassMeeting)
+	for _, meeting := range meetings {
+		byClass[meeting.ClassId] = append(byClass[meeting.ClassId], meeting)
+	}
+
+	return byClass, pkg.CustomError{}
+}
+
+// formatMeetings names the weekday of each meeting and cuts its times, which
+// the database returns as full timestamps, down to "15:04".
+func formatMeetings(meetings []*models.ClassMeeting) pkg.CustomError {
+	var customError pkg.CustomError
+	for _, meeting := range meetings {
+		meeting.Day = utils.ConvertIntToDay(strconv.Itoa(meeting.Weekday))
+		meeting.StartTime, customError = utils.ConvertTimes(meeting.StartTime)
+		if customError.Cause != nil {
+			return customError
+		}
+		meeting.EndTime, customError = utils.ConvertTimes(meeting.EndTime)
+		if customError.Cause != nil {
+			return customError
+		}
+	}
+
+	return pkg.CustomError{}
+}
+
+// requireActiveClass refuses changes to an archived class, which stays
+// readable but is frozen until it is unarchived.
+func requireActiveClass(class *models.Class) pkg.CustomError {
+	if class.ArchivedAt != nil {
+		return pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("class is archived and read-only"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	return pkg.CustomError{}
+}
+
+func (s *classUsecaseImpl) FetchClassById(c context.Context, subject authz.Subject, id int) (*models.Class, pkg.CustomError) {
+	classResult, resource, err := s.classResource(c, subject, authz.CLASS, id)
 	if err.Cause != nil {
 		return nil, err
 	}
 
-	classResult.Day = utils.ConvertIntToDay(classResult.Day)
-	classResult.StartTime, err = utils.ConvertTimes(classResult.StartTime)
+	err = authz.Authorize(subject, authz.READ, resource)
 	if err.Cause != nil {
 		return nil, err
 	}
-	classResult.EndTime, err = utils.ConvertTimes(classResult.EndTime)
+
+	// The key lets anyone join, so only those managing the class may see it.
+	if !authz.Can(subject, authz.MANAGE, resource) {
+		classResult.Key = ""
+		classResult.KeyExpiresAt = nil
+	}
+
+	meetings, err := s.classMeetings(c, id)
 	if err.Cause != nil {
 		return nil, err
 	}
+	classResult.Meetings = meetings[id]
 
 	classSection, err := s.classRepo.GetClassSectionByClassId(c, id)
 	if err.Cause != nil {
@@ -76,30 +207,35 @@ func (s *classUsecaseImpl) FetchClassByTeacherId(c context.Context, teacherId in
 	return classResult, pkg.CustomError{}
 }
 
-func (s *classUsecaseImpl) FetchClassByName(c context.Context, name string) ([]*dto.ClassByNameResponse, pkg.CustomError) {
+func (s *classUsecaseImpl) FetchClassByName(c context.Context, name string, term string) ([]*dto.ClassByNameResponse, pkg.CustomError) {
 	var classes []*dto.ClassByNameResponse
-	classResult, err := s.classRepo.GetClassByName(c, name)
+	termId, err := resolveTermFilter(c, s.termRepo, term)
+	if err.Cause != nil {
+		return nil, err
+	}
+
+	classResult, err := s.classRepo.GetClassByName(c, name, termId)
+	if err.Cause != nil {
+		return nil, err
+	}
+
+	var classIds []int
+	for _, class := range classResult {
+		classIds = append(classIds, class.ID)
+	}
+
+	meetings, err := s.classMeetings(c, classIds...)
 	if err.Cause != nil {
 		return nil, err
 	}
 
 	for _, c := range classResult {
-		c.Day = utils.ConvertIntToDay(c.Day)
-		c.StartTime, err = utils.ConvertTimes(c.StartTime)
-		if err.Cause != nil {
-			return nil, err
-		}
-		c.EndTime, err = utils.ConvertTimes(c.EndTime)
-		if err.Cause != nil {
-			return nil, err
-		}
 		class := dto.ClassByNameResponse{
 			ID:          c.ID,
 			Name:        c.Name,
 			Description: c.Description,
-			Day:         c.Day,
-			StartTime:   c.StartTime,
-			EndTime:     c.EndTime,
+			TermId:      c.TermId,
+			Meetings:    meetings[c.ID],
 		}
 		classes = append(classes, &class)
 	}
@@ -107,12 +243,29 @@ func (s *classUsecaseImpl) FetchClassByName(c context.Context, name string) ([]*
 	return classes, pkg.CustomError{}
 }
 
-func (s *classUsecaseImpl) CreateClass(c context.Context, request *dto.ClassCreate, teacherId uuid.UUID) pkg.CustomError {
-	class, err := request.NewClass(teacherId)
+func (s *classUsecaseImpl) CreateClass(c context.Context, subject authz.Subject, request *dto.ClassCreate) pkg.CustomError {
+	err := authz.Authorize(subject, authz.CREATE, authz.Resource{Type: authz.CLASS})
 	if err.Cause != nil {
 		return err
 	}
 
+	class, err := request.NewClass(subject.UserID, viper.GetString("CLASS_TIMEZONE"))
+	if err.Cause != nil {
+		return err
+	}
+
+	class.TermId, err = s.classTerm(c, class.TermId)
+	if err.Cause != nil {
+		return err
+	}
+
+	if !request.AllowConflicts {
+		err = s.refuseScheduleConflicts(c, subject, class.Meetings, class.TermId)
+		if err.Cause != nil {
+			return err
+		}
+	}
+
 	err = s.classRepo.CreateClass(c, class)
 	if err.Cause != nil {
 		return err
@@ -121,157 +274,1056 @@ func (s *classUsecaseImpl) CreateClass(c context.Context, request *dto.ClassCrea
 	return pkg.CustomError{}
 }
 
-func (s *classUsecaseImpl) CheckIfStudentInClass(c context.Context, studentId uuid.UUID, classId int) (bool, pkg.CustomError) {
-	exists, err := s.classRepo.CheckStudentClassExists(c, classId, studentId)
-	if err.Cause != nil {
-		return false, err
+func (s *classUsecaseImpl) UpdateClass(c context.Context, subject authz.Subject, classId int, request *dto.UpdateClassRequest) pkg.CustomError {
+	classResult, resource, customError := s.classResource(c, subject, authz.CLASS, classId)
+	if customError.Cause != nil {
+		return customError
 	}
 
-	if exists {
-		return true, pkg.CustomError{}
+	customError = authz.Authorize(subject, authz.UPDATE, resource)
+	if customError.Cause != nil {
+		return customError
 	}
 
-	return false, pkg.CustomError{}
+	customError = requireActiveClass(classResult)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	meetings, customError := request.Normalize(viper.GetString("CLASS_TIMEZONE"))
+	if customError.Cause != nil {
+		return customError
+	}
+
+	if request.TermId != nil {
+		_, customError = s.termRepo.GetTermById(c, *request.TermId)
+		if customError.Cause != nil {
+			return customError
+		}
+	}
+
+	return s.classRepo.UpdateClass(c, classId, request, meetings)
 }
 
-func (s *classUsecaseImpl) JoinClass(c context.Context, studentId uuid.UUID, classId int, key string) pkg.CustomError {
-	exists, err := s.classRepo.CheckStudentClassExists(c, classId, studentId)
-	if err.Cause != nil {
-		return err
+// CloneClass offers a copy of a past class in another term, with its
+// meetings, enrollment settings, sections, materials and assignments. The copy
+// belongs to the owner of the original and starts without students.
+func (s *classUsecaseImpl) CloneClass(c context.Context, subject authz.Subject, classId int, request *dto.CloneClassRequest) (int, pkg.CustomError) {
+	classResult, resource, customError := s.classResource(c, subject, authz.CLASS, classId)
+	if customError.Cause != nil {
+		return 0, customError
 	}
 
-	if exists {
-		return pkg.CustomError{
+	customError = authz.Authorize(subject, authz.UPDATE, resource)
+	if customError.Cause != nil {
+		return 0, customError
+	}
+
+	term, customError := s.termRepo.GetTermById(c, request.TermId)
+	if customError.Cause != nil {
+		return 0, customError
+	}
+
+	// Deadlines keep their place in the term. Without a term to measure from
+	// they are left for the teacher to set again.
+	var deadlineShiftDays *int
+	if classResult.TermId != nil {
+		sourceTerm, customError := s.termRepo.GetTermById(c, *classResult.TermId)
+		if customError.Cause != nil {
+			return 0, customError
+		}
+		days := int(term.StartsOn.Sub(sourceTerm.StartsOn).Hours() / 24)
+		deadlineShiftDays = &days
+	}
+
+	name := strings.TrimSpace(request.Name)
+	if name == "" {
+		name = classResult.Name
+	}
+	if len(name) > 50 {
+		return 0, pkg.CustomError{
 			Code:    utils.BAD_REQUEST,
-			Cause:   errors.New("you already join this class"),
+			Cause:   errors.New("name must be between 1 and 50 characters"),
 			Service: utils.USECASE_SERVICE,
 		}
 	}
 
-	classResult, err := s.classRepo.GetClassByID(c, classId)
-	if err.Cause != nil {
-		return err
+	meetings, customError := s.classRepo.GetClassMeetings(c, []int{classId})
+	if customError.Cause != nil {
+		return 0, customError
 	}
 
-	if classResult.Key != key {
-		return pkg.CustomError{
-			Code:    utils.BAD_REQUEST,
-			Cause:   errors.New("missmatch class key"),
+	key, err := utils.GenerateClassKey(utils.CLASS_KEY_LENGTH)
+	if err != nil {
+		return 0, pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
 			Service: utils.USECASE_SERVICE,
 		}
 	}
 
-	err = s.classRepo.JoinClass(c, classId, studentId)
-	if err.Cause != nil {
-		return err
+	clone := &models.Class{
+		Name:           name,
+		Description:    classResult.Description,
+		Key:            key,
+		TeacherId:      classResult.TeacherId,
+		TermId:         &term.ID,
+		EnrollmentMode: classResult.EnrollmentMode,
+		Capacity:       classResult.Capacity,
+		Meetings:       meetings,
 	}
 
-	return pkg.CustomError{}
+	customError = s.classRepo.CloneClass(c, classId, clone, deadlineShiftDays)
+	if customError.Cause != nil {
+		return 0, customError
+	}
+
+	return clone.ID, pkg.CustomError{}
 }
 
-func (s *classUsecaseImpl) FetchSectionClassById(c context.Context, id int) (*models.SectionClass, pkg.CustomError) {
-	sectionClass, customError := s.classRepo.GetClassSectionById(c, id)
+// CheckScheduleConflicts tells a student, without joining, which classes in
+// their schedule a class would overlap.
+func (s *classUsecaseImpl) CheckScheduleConflicts(c context.Context, subject authz.Subject, classId int) ([]*models.ScheduleConflict, pkg.CustomError) {
+	classResult, resource, customError := s.classResource(c, subject, authz.CLASS, classId)
 	if customError.Cause != nil {
 		return nil, customError
 	}
 
-	return sectionClass, pkg.CustomError{}
+	customError = authz.Authorize(subject, authz.JOIN, resource)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	meetings, customError := s.classMeetings(c, classId)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	return s.scheduleConflicts(c, subject, meetings[classId], classResult.TermId)
 }
 
-func (s *classUsecaseImpl) CreateSectionClass(c context.Context, request *models.SectionClass) pkg.CustomError {
-	err := s.classRepo.CreateClassSection(c, request)
-	if err.Cause != nil {
-		return err
+// scheduleConflicts compares meetings with the subject's schedule in the same
+// term: the classes a student is enrolled in or a teacher is on the staff of.
+func (s *classUsecaseImpl) scheduleConflicts(c context.Context, subject authz.Subject, meetings []*models.ClassMeeting, termId *int) ([]*models.ScheduleConflict, pkg.CustomError) {
+	var schedule []*models.ScheduleEntry
+	var customError pkg.CustomError
+	switch subject.Role {
+	case utils.STUDENT_ROLE:
+		schedule, customError = s.studentRepo.FetchStudentClass(c, subject.UserID, termId)
+	case utils.TEACHER_ROLE:
+		schedule, customError = s.classRepo.GetTeacherSchedule(c, subject.UserID, termId)
+	}
+	if customError.Cause != nil {
+		return nil, customError
 	}
 
-	return pkg.CustomError{}
+	scheduled := make([]*models.ClassMeeting, len(schedule))
+	for i, entry := range schedule {
+		scheduled[i] = &entry.ClassMeeting
+	}
+
+	customError = formatMeetings(scheduled)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	return findScheduleConflicts(meetings, schedule, time.Now()), pkg.CustomError{}
 }
 
-func (s *classUsecaseImpl) LeftClass(c context.Context, classId int, studentId uuid.UUID) pkg.CustomError {
-	customError := s.classRepo.LeftCLass(c, classId, studentId)
+// refuseScheduleConflicts is scheduleConflicts as a 409 listing the conflicts.
+func (s *classUsecaseImpl) refuseScheduleConflicts(c context.Context, subject authz.Subject, meetings []*models.ClassMeeting, termId *int) pkg.CustomError {
+	conflicts, customError := s.scheduleConflicts(c, subject, meetings, termId)
 	if customError.Cause != nil {
 		return customError
 	}
 
+	if len(conflicts) > 0 {
+		return pkg.CustomError{
+			Code:    utils.CONFLICT,
+			Cause:   &ScheduleConflictError{Conflicts: conflicts},
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
 	return pkg.CustomError{}
 }
 
-func (s *classUsecaseImpl) AddSubmissionTeacher(c context.Context, request *models.Submission) pkg.CustomError {
-	customError := s.classRepo.InsertSubmissionTeacher(c, request)
+// classTerm checks the term a new class is offered in, defaulting to the
+// current term. It stays nil while there are no terms.
+func (s *classUsecaseImpl) classTerm(c context.Context, termId *int) (*int, pkg.CustomError) {
+	if termId != nil {
+		_, customError := s.termRepo.GetTermById(c, *termId)
+		if customError.Cause != nil {
+			return nil, customError
+		}
+		return termId, pkg.CustomError{}
+	}
+
+	return resolveTermFilter(c, s.termRepo, "current")
+}
+
+func (s *classUsecaseImpl) ArchiveClass(c context.Context, subject authz.Subject, classId int) pkg.CustomError {
+	_, resource, customError := s.classResource(c, subject, authz.CLASS, classId)
 	if customError.Cause != nil {
 		return customError
 	}
 
-	sectionClass, customError := s.classRepo.GetClassSectionById(c, request.ClassSectionId)
+	customError = authz.Authorize(subject, authz.UPDATE, resource)
 	if customError.Cause != nil {
 		return customError
 	}
 
-	sectionClass.Task = true
+	return s.classRepo.ArchiveClass(c, classId)
+}
 
-	customError = s.classRepo.UpdateClassSection(c, sectionClass)
+func (s *classUsecaseImpl) UnarchiveClass(c context.Context, subject authz.Subject, classId int) pkg.CustomError {
+	_, resource, customError := s.classResource(c, subject, authz.CLASS, classId)
 	if customError.Cause != nil {
 		return customError
 	}
 
-	return pkg.CustomError{}
+	customError = authz.Authorize(subject, authz.UPDATE, resource)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	return s.classRepo.UnarchiveClass(c, classId)
 }
 
-func (s *classUsecaseImpl) AddSubmissionStudent(c context.Context, request *dto.StudentSubmissionRequest, file *multipart.FileHeader) pkg.CustomError {
-	sectionClass, customError := s.classRepo.GetClassSectionById(c, request.ClassSectionId)
+func (s *classUsecaseImpl) DeleteClass(c context.Context, subject authz.Subject, classId int) pkg.CustomError {
+	_, resource, customError := s.classResource(c, subject, authz.CLASS, classId)
 	if customError.Cause != nil {
 		return customError
 	}
 
-	student, customError := s.studentRepo.GetStudentByID(c, request.ID)
+	customError = authz.Authorize(subject, authz.DELETE, resource)
 	if customError.Cause != nil {
 		return customError
 	}
 
-	if sectionClass.Task != true {
-		return pkg.CustomError{
+	return s.classRepo.DeleteClass(c, classId)
+}
+
+// RestoreClass brings back a class deleted less than CLASS_RESTORE_DAYS ago.
+// The class can't be loaded while deleted, so the owner is checked on the
+// staff list alone.
+func (s *classUsecaseImpl) RestoreClass(c context.Context, subject authz.Subject, classId int) pkg.CustomError {
+	resource := authz.Resource{
+		Type: authz.CLASS,
+	}
+
+	if subject.Role == utils.TEACHER_ROLE {
+		var customError pkg.CustomError
+		resource.StaffRole, customError = s.classRepo.GetClassStaffRole(c, classId, subject.UserID)
+		if customError.Cause != nil {
+			return customError
+		}
+	}
+
+	customError := authz.Authorize(subject, authz.DELETE, resource)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	return s.classRepo.RestoreClass(c, classId, viper.GetInt("CLASS_RESTORE_DAYS"))
+}
+
+func (s *classUsecaseImpl) CheckIfStudentInClass(c context.Context, studentId uuid.UUID, classId int) (bool, pkg.CustomError) {
+	exists, err := s.classRepo.CheckStudentClassExists(c, classId, studentId)
+	if err.Cause != nil {
+		return false, err
+	}
+
+	if exists {
+		return true, pkg.CustomError{}
+	}
+
+	return false, pkg.CustomError{}
+}
+
+// JoinClass lets a student in according to the class's enrollment mode, or
+// with an invite code whatever the mode. It returns whether they are enrolled,
+// waiting for approval or waitlisted.
+func (s *classUsecaseImpl) JoinClass(c context.Context, subject authz.Subject, classId int, request *dto.JoinClassRequest) (string, pkg.CustomError) {
+	classResult, resource, err := s.classResource(c, subject, authz.CLASS, classId)
+	if err.Cause != nil {
+		return "", err
+	}
+
+	err = authz.Authorize(subject, authz.JOIN, resource)
+	if err.Cause != nil {
+		return "", err
+	}
+
+	if resource.IsMember {
+		return "", pkg.CustomError{
 			Code:    utils.BAD_REQUEST,
-			Cause:   errors.New("there's currently no task here"),
+			Cause:   errors.New("you already join this class"),
 			Service: utils.USECASE_SERVICE,
 		}
 	}
 
-	parsedFile, err := file.Open()
-	if err != nil {
-		return pkg.CustomError{
-			Code:    utils.INTERNAL_SERVER_ERROR,
-			Cause:   err,
+	err = requireActiveClass(classResult)
+	if err.Cause != nil {
+		return "", err
+	}
+
+	if classResult.TermId != nil {
+		term, err := s.termRepo.GetTermById(c, *classResult.TermId)
+		if err.Cause != nil {
+			return "", err
+		}
+		if !term.EnrollmentOpen(time.Now()) {
+			return "", pkg.CustomError{
+				Code:    utils.BAD_REQUEST,
+				Cause:   fmt.Errorf("enrollment for %s is closed", term.Name),
+				Service: utils.USECASE_SERVICE,
+			}
+		}
+	}
+
+	meetings, err := s.classMeetings(c, classId)
+	if err.Cause != nil {
+		return "", err
+	}
+
+	err = s.refuseScheduleConflicts(c, subject, meetings[classId], classResult.TermId)
+	if err.Cause != nil {
+		return "", err
+	}
+
+	if request.InviteCode != "" {
+		return s.classRepo.AdmitInvitedStudent(c, classId, subject.UserID, utils.HashToken(request.InviteCode))
+	}
+
+	if classResult.EnrollmentMode == utils.ENROLLMENT_MODE_INVITE_ONLY {
+		return "", pkg.CustomError{
+			Code:    utils.FORBIDDEN,
+			Cause:   errors.New("this class only admits invited students"),
 			Service: utils.USECASE_SERVICE,
 		}
 	}
 
-	storageGo := storage_go.NewClient(viper.GetString("SUPABASE_URL"), viper.GetString("SUPABASE_TOKEN"), nil)
-	_, err = storageGo.UploadFile("submissions_student", fmt.Sprintf("class_section/%s/%s-%d.pdf", sectionClass.Title, student.Name, student.NIM), parsedFile)
-	linkFile := storageGo.GetPublicUrl("submissions_student", fmt.Sprintf("class_section/%s/%s-%d.pdf", sectionClass.Title, student.Name, student.NIM))
-	request.File = linkFile.SignedURL + fmt.Sprintf("?download=%s-%s-%d.pdf", sectionClass.Title, student.Name, student.NIM)
+	if classResult.KeyExpiresAt != nil && !classResult.KeyExpiresAt.After(time.Now()) {
+		return "", pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("class key is no longer valid"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
 
-	customError = s.classRepo.InsertSubmissionStudent(c, request)
-	if customError.Cause != nil {
-		return customError
+	if classResult.Key != request.Key {
+		return "", pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("missmatch class key"),
+			Service: utils.USECASE_SERVICE,
+		}
 	}
 
-	return pkg.CustomError{}
-}
+	if classResult.EnrollmentMode == utils.ENROLLMENT_MODE_APPROVAL {
+		err = s.classRepo.CreateEnrollmentRequest(c, classId, subject.UserID, utils.ENROLLMENT_PENDING)
+		if err.Cause != nil {
+			return "", err
+		}
+		return utils.ENROLLMENT_PENDING, pkg.CustomError{}
+	}
 
-func (s *classUsecaseImpl) FetchSubmissionBySection(c context.Context, sectionClassId int, teacherId uuid.UUID) ([]*models.StudentSubmission, pkg.CustomError) {
-	//isAuthorized, customError := s.classRepo.CheckStudentClassExists(c, teacherId, )
+	return s.classRepo.AdmitStudent(c, classId, subject.UserID, nil)
+}
 
-	submissions, customError := s.classRepo.GetSubmissionByClassSection(c, sectionClassId)
-	if customError.Cause != nil {
-		return nil, customError
+// FetchClassInvite shows which class an invite link is for, so the student can
+// check before joining.
+func (s *classUsecaseImpl) FetchClassInvite(c context.Context, subject authz.Subject, token string) (*dto.ClassByNameResponse, pkg.CustomError) {
+	classId, _, err := parseClassInvite(token)
+	if err.Cause != nil {
+		return nil, err
 	}
 
-	return submissions, pkg.CustomError{}
+	classResult, err := s.classRepo.GetClassByID(c, classId)
+	if err.Cause != nil {
+		return nil, err
+	}
+
+	err = authz.Authorize(subject, authz.READ, authz.Resource{Type: authz.CLASS})
+	if err.Cause != nil {
+		return nil, err
+	}
+
+	meetings, err := s.classMeetings(c, classId)
+	if err.Cause != nil {
+		return nil, err
+	}
+
+	return &dto.ClassByNameResponse{
+		ID:          classResult.ID,
+		Name:        classResult.Name,
+		Description: classResult.Description,
+		TermId:      classResult.TermId,
+		Meetings:    meetings[classId],
+	}, pkg.CustomError{}
+}
+
+func (s *classUsecaseImpl) JoinClassByInvite(c context.Context, subject authz.Subject, token string) (string, pkg.CustomError) {
+	classId, code, customError := parseClassInvite(token)
+	if customError.Cause != nil {
+		return "", customError
+	}
+
+	return s.JoinClass(c, subject, classId, &dto.JoinClassRequest{InviteCode: code})
+}
+
+func parseClassInvite(token string) (int, string, pkg.CustomError) {
+	classId, code, err := utils.ParseClassInvite(token)
+	if err != nil {
+		code := utils.INTERNAL_SERVER_ERROR
+		if errors.Is(err, utils.ErrInvalidInviteLink) {
+			code = utils.BAD_REQUEST
+		}
+		return 0, "", pkg.CustomError{
+			Code:    code,
+			Cause:   err,
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	return classId, code, pkg.CustomError{}
+}
+
+// RotateClassKey replaces the class key, so the old one stops working.
+func (s *classUsecaseImpl) RotateClassKey(c context.Context, subject authz.Subject, classId int, request *dto.RotateClassKeyRequest) (interface{}, pkg.CustomError) {
+	_, resource, customError := s.classResource(c, subject, authz.CLASS, classId)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	customError = authz.Authorize(subject, authz.MANAGE, resource)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	if request.ExpiresInHours < 0 {
+		return nil, pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("expires_in_hours can't be negative"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	key, err := utils.GenerateClassKey(utils.CLASS_KEY_LENGTH)
+	if err != nil {
+		return nil, pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	var expiresAt *time.Time
+	if request.ExpiresInHours > 0 {
+		expiry := time.Now().Add(time.Hour * time.Duration(request.ExpiresInHours))
+		expiresAt = &expiry
+	}
+
+	customError = s.classRepo.UpdateClassKey(c, classId, key, expiresAt)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	return map[string]interface{}{
+		"key":            key,
+		"key_expires_at": expiresAt,
+	}, pkg.CustomError{}
+}
+
+// DisableClassKey makes the key expire now. Invites keep working.
+func (s *classUsecaseImpl) DisableClassKey(c context.Context, subject authz.Subject, classId int) pkg.CustomError {
+	classResult, resource, customError := s.classResource(c, subject, authz.CLASS, classId)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	customError = authz.Authorize(subject, authz.MANAGE, resource)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	now := time.Now()
+	return s.classRepo.UpdateClassKey(c, classId, classResult.Key, &now)
+}
+
+// CreateClassInvite returns the invite code and a link to join with it. Only a
+// hash of the code is kept, so both are shown this once.
+func (s *classUsecaseImpl) CreateClassInvite(c context.Context, subject authz.Subject, classId int, request *dto.CreateClassInviteRequest) (interface{}, pkg.CustomError) {
+	_, resource, customError := s.classResource(c, subject, authz.CLASS, classId)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	customError = authz.Authorize(subject, authz.MANAGE, resource)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	if request.MaxUses < 0 || request.ExpiresInHours < 0 {
+		return nil, pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("max_uses and expires_in_hours can't be negative"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	code, err := utils.GenerateClassKey(utils.CLASS_INVITE_CODE_LENGTH)
+	if err != nil {
+		return nil, pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	link, err := utils.SignClassInvite(classId, code)
+	if err != nil {
+		return nil, pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+	if inviteUrl := viper.GetString("CLASS_INVITE_URL"); inviteUrl != "" {
+		link = fmt.Sprintf("%s?token=%s", inviteUrl, link)
+	}
+
+	invite := &models.ClassInvite{
+		ClassId:   classId,
+		CodeHash:  utils.HashToken(code),
+		CreatedBy: subject.UserID,
+	}
+	if request.MaxUses > 0 {
+		invite.MaxUses = &request.MaxUses
+	}
+	if request.ExpiresInHours > 0 {
+		expiresAt := time.Now().Add(time.Hour * time.Duration(request.ExpiresInHours))
+		invite.ExpiresAt = &expiresAt
+	}
+
+	customError = s.classRepo.CreateClassInvite(c, invite)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	return map[string]interface{}{
+		"code":   code,
+		"link":   link,
+		"invite": invite,
+	}, pkg.CustomError{}
+}
+
+func (s *classUsecaseImpl) FetchClassInvites(c context.Context, subject authz.Subject, classId int) ([]*models.ClassInvite, pkg.CustomError) {
+	_, resource, customError := s.classResource(c, subject, authz.CLASS, classId)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	customError = authz.Authorize(subject, authz.MANAGE, resource)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	return s.classRepo.GetClassInvites(c, classId)
+}
+
+func (s *classUsecaseImpl) RevokeClassInvite(c context.Context, subject authz.Subject, classId int, inviteId int) pkg.CustomError {
+	_, resource, customError := s.classResource(c, subject, authz.CLASS, classId)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	customError = authz.Authorize(subject, authz.MANAGE, resource)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	return s.classRepo.RevokeClassInvite(c, classId, inviteId)
+}
+
+// WithdrawJoinRequest takes the student off the approval queue or waitlist.
+func (s *classUsecaseImpl) WithdrawJoinRequest(c context.Context, subject authz.Subject, classId int) pkg.CustomError {
+	_, resource, customError := s.classResource(c, subject, authz.CLASS, classId)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	customError = authz.Authorize(subject, authz.JOIN, resource)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	return s.classRepo.CloseEnrollmentRequest(c, classId, subject.UserID, utils.ENROLLMENT_CANCELLED, nil)
+}
+
+func (s *classUsecaseImpl) FetchSectionClassById(c context.Context, subject authz.Subject, id int) (*models.SectionClass, pkg.CustomError) {
+	sectionClass, _, resource, customError := s.sectionResource(c, subject, authz.SECTION, id)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	customError = authz.Authorize(subject, authz.READ, resource)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	return sectionClass, pkg.CustomError{}
+}
+
+func (s *classUsecaseImpl) CreateSectionClass(c context.Context, subject authz.Subject, request *models.SectionClass) pkg.CustomError {
+	classResult, resource, err := s.classResource(c, subject, authz.SECTION, request.ClassId)
+	if err.Cause != nil {
+		return err
+	}
+
+	err = authz.Authorize(subject, authz.CREATE, resource)
+	if err.Cause != nil {
+		return err
+	}
+
+	err = requireActiveClass(classResult)
+	if err.Cause != nil {
+		return err
+	}
+
+	err = s.classRepo.CreateClassSection(c, request)
+	if err.Cause != nil {
+		return err
+	}
+
+	return pkg.CustomError{}
+}
+
+func (s *classUsecaseImpl) LeftClass(c context.Context, subject authz.Subject, classId int) pkg.CustomError {
+	_, resource, customError := s.classResource(c, subject, authz.CLASS, classId)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	customError = authz.Authorize(subject, authz.LEAVE, resource)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	customError = s.classRepo.LeftCLass(c, classId, subject.UserID)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	s.promoteWaitlist(c, classId)
+
+	return pkg.CustomError{}
+}
+
+// promoteWaitlist hands out free seats after a change that may have made
+// some. The change itself already succeeded, so a failure is only logged and
+// the seats go out on the next one.
+func (s *classUsecaseImpl) promoteWaitlist(c context.Context, classId int) {
+	_, customError := s.classRepo.PromoteWaitlist(c, classId)
+	if customError.Cause != nil {
+		log.Printf("failed to promote waitlist of class %d: %s", classId, customError.Cause)
+	}
+}
+
+func (s *classUsecaseImpl) UpdateClassEnrollment(c context.Context, subject authz.Subject, classId int, request *dto.UpdateEnrollmentRequest) pkg.CustomError {
+	classResult, resource, customError := s.classResource(c, subject, authz.CLASS, classId)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	customError = authz.Authorize(subject, authz.UPDATE, resource)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	mode, capacity := classResult.EnrollmentMode, classResult.Capacity
+	if request.Mode != nil {
+		mode = *request.Mode
+	}
+	if request.Capacity != nil {
+		capacity = request.Capacity
+		if *capacity == 0 {
+			capacity = nil
+		}
+	}
+
+	if mode != utils.ENROLLMENT_MODE_OPEN && mode != utils.ENROLLMENT_MODE_APPROVAL && mode != utils.ENROLLMENT_MODE_INVITE_ONLY {
+		return pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("enrollment_mode must be open, approval or invite_only"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	if capacity != nil && *capacity < 0 {
+		return pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("capacity can't be negative"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	customError = s.classRepo.UpdateClassEnrollment(c, classId, mode, capacity)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	s.promoteWaitlist(c, classId)
+
+	return pkg.CustomError{}
+}
+
+// FetchEnrollmentRequests lists the open requests by default, or those with
+// the given status.
+func (s *classUsecaseImpl) FetchEnrollmentRequests(c context.Context, subject authz.Subject, classId int, status string) ([]*models.EnrollmentRequest, pkg.CustomError) {
+	_, resource, customError := s.classResource(c, subject, authz.CLASS, classId)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	customError = authz.Authorize(subject, authz.MANAGE, resource)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	statuses := []string{utils.ENROLLMENT_PENDING, utils.ENROLLMENT_WAITLISTED}
+	switch status {
+	case "":
+	case utils.ENROLLMENT_PENDING, utils.ENROLLMENT_WAITLISTED, utils.ENROLLMENT_APPROVED, utils.ENROLLMENT_REJECTED, utils.ENROLLMENT_CANCELLED:
+		statuses = []string{status}
+	default:
+		return nil, pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   fmt.Errorf("unknown status %q", status),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	return s.classRepo.GetEnrollmentRequests(c, classId, statuses)
+}
+
+// ApproveEnrollmentRequest admits a pending student, onto the waitlist when
+// the class is full.
+func (s *classUsecaseImpl) ApproveEnrollmentRequest(c context.Context, subject authz.Subject, classId int, requestId int) (string, pkg.CustomError) {
+	request, customError := s.pendingEnrollmentRequest(c, subject, classId, requestId)
+	if customError.Cause != nil {
+		return "", customError
+	}
+
+	return s.classRepo.AdmitStudent(c, classId, request.StudentId, &subject.UserID)
+}
+
+func (s *classUsecaseImpl) RejectEnrollmentRequest(c context.Context, subject authz.Subject, classId int, requestId int) pkg.CustomError {
+	request, customError := s.pendingEnrollmentRequest(c, subject, classId, requestId)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	return s.classRepo.CloseEnrollmentRequest(c, classId, request.StudentId, utils.ENROLLMENT_REJECTED, &subject.UserID)
+}
+
+func (s *classUsecaseImpl) pendingEnrollmentRequest(c context.Context, subject authz.Subject, classId int, requestId int) (*models.EnrollmentRequest, pkg.CustomError) {
+	classResult, resource, customError := s.classResource(c, subject, authz.CLASS, classId)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	customError = authz.Authorize(subject, authz.MANAGE, resource)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	customError = requireActiveClass(classResult)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	request, customError := s.classRepo.GetEnrollmentRequest(c, classId, requestId)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	if request.Status != utils.ENROLLMENT_PENDING {
+		return nil, pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   fmt.Errorf("request is %s, not pending", request.Status),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	return request, pkg.CustomError{}
+}
+
+// EnrollStudent is how teachers add students themselves, whatever the
+// enrollment mode. Capacity still applies.
+func (s *classUsecaseImpl) EnrollStudent(c context.Context, subject authz.Subject, classId int, request *dto.EnrollStudentRequest) (string, pkg.CustomError) {
+	classResult, resource, customError := s.classResource(c, subject, authz.CLASS, classId)
+	if customError.Cause != nil {
+		return "", customError
+	}
+
+	customError = authz.Authorize(subject, authz.MANAGE, resource)
+	if customError.Cause != nil {
+		return "", customError
+	}
+
+	customError = requireActiveClass(classResult)
+	if customError.Cause != nil {
+		return "", customError
+	}
+
+	student, customError := s.studentRepo.GetStudentByEmail(c, request.Email)
+	if customError.Cause != nil {
+		return "", customError
+	}
+
+	isMember, customError := s.classRepo.CheckStudentClassExists(c, classId, student.ID)
+	if customError.Cause != nil {
+		return "", customError
+	}
+
+	if isMember {
+		return "", pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("student already joined this class"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	return s.classRepo.AdmitStudent(c, classId, student.ID, &subject.UserID)
+}
+
+func (s *classUsecaseImpl) AddSubmissionTeacher(c context.Context, subject authz.Subject, request *models.Submission, file *multipart.FileHeader) pkg.CustomError {
+	sectionClass, classResult, resource, customError := s.sectionResource(c, subject, authz.MATERIAL, request.ClassSectionId)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	customError = authz.Authorize(subject, authz.CREATE, resource)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	customError = requireActiveClass(classResult)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	parsedFile, err := file.Open()
+	if err != nil {
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	storageGo := storage_go.NewClient(viper.GetString("SUPABASE_URL"), viper.GetString("SUPABASE_TOKEN"), nil)
+	_, err = storageGo.UploadFile("submissions_teacher", fmt.Sprintf("class_section/%d/%s.pdf", request.ClassSectionId, request.Title), parsedFile)
+	if err != nil {
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	linkFile := storageGo.GetPublicUrl("submissions_teacher", fmt.Sprintf("class_section/%d/%s.pdf", request.ClassSectionId, request.Title))
+	request.File = linkFile.SignedURL + fmt.Sprintf("?download=%s.pdf", request.Title)
+
+	customError = s.classRepo.InsertSubmissionTeacher(c, request)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	sectionClass.Task = true
+
+	customError = s.classRepo.UpdateClassSection(c, sectionClass)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	return pkg.CustomError{}
+}
+
+func (s *classUsecaseImpl) AddSubmissionStudent(c context.Context, subject authz.Subject, request *dto.StudentSubmissionRequest, file *multipart.FileHeader) pkg.CustomError {
+	sectionClass, classResult, resource, customError := s.sectionResource(c, subject, authz.SUBMISSION, request.ClassSectionId)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	customError = authz.Authorize(subject, authz.CREATE, resource)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	customError = requireActiveClass(classResult)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	request.ID = subject.UserID
+
+	student, customError := s.studentRepo.GetStudentByID(c, request.ID)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	if sectionClass.Task != true {
+		return pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("there's currently no task here"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	parsedFile, err := file.Open()
+	if err != nil {
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	storageGo := storage_go.NewClient(viper.GetString("SUPABASE_URL"), viper.GetString("SUPABASE_TOKEN"), nil)
+	_, err = storageGo.UploadFile("submissions_student", fmt.Sprintf("class_section/%s/%s-%d.pdf", sectionClass.Title, student.Name, student.NIM), parsedFile)
+	linkFile := storageGo.GetPublicUrl("submissions_student", fmt.Sprintf("class_section/%s/%s-%d.pdf", sectionClass.Title, student.Name, student.NIM))
+	request.File = linkFile.SignedURL + fmt.Sprintf("?download=%s-%s-%d.pdf", sectionClass.Title, student.Name, student.NIM)
+
+	customError = s.classRepo.InsertSubmissionStudent(c, request)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	return pkg.CustomError{}
+}
+
+func (s *classUsecaseImpl) FetchSubmissionBySection(c context.Context, subject authz.Subject, sectionClassId int) ([]*models.StudentSubmission, pkg.CustomError) {
+	_, _, resource, customError := s.sectionResource(c, subject, authz.SUBMISSION, sectionClassId)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	customError = authz.Authorize(subject, authz.READ, resource)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	submissions, customError := s.classRepo.GetSubmissionByClassSection(c, sectionClassId)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	return submissions, pkg.CustomError{}
+}
+
+func (s *classUsecaseImpl) FetchClassStaff(c context.Context, subject authz.Subject, classId int) ([]*models.ClassStaff, pkg.CustomError) {
+	_, resource, customError := s.classResource(c, subject, authz.STAFF, classId)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	customError = authz.Authorize(subject, authz.READ, resource)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	return s.classRepo.GetClassStaff(c, classId)
+}
+
+// InviteClassStaff adds a pending staff member, who gets no access to the
+// class until they accept.
+func (s *classUsecaseImpl) InviteClassStaff(c context.Context, subject authz.Subject, classId int, request *dto.InviteClassStaffRequest) pkg.CustomError {
+	_, resource, customError := s.classResource(c, subject, authz.STAFF, classId)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	customError = authz.Authorize(subject, authz.CREATE, resource)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	if request.Role != utils.CLASS_STAFF_CO_TEACHER && request.Role != utils.CLASS_STAFF_ASSISTANT {
+		return pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("role must be co_teacher or assistant"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	teacher, customError := s.teacherRepo.GetTeacherByEmail(c, request.Email)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	if teacher.ApprovedAt == nil || teacher.SuspendedAt != nil {
+		return pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("teacher is not an active account"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	return s.classRepo.InviteClassStaff(c, &models.ClassStaff{
+		ClassId:   classId,
+		TeacherId: teacher.ID,
+		Role:      request.Role,
+		InvitedBy: &subject.UserID,
+	})
+}
+
+func (s *classUsecaseImpl) AcceptClassStaffInvite(c context.Context, subject authz.Subject, classId int) pkg.CustomError {
+	customError := authz.AuthorizeScope(subject, authz.CLASSES_WRITE)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	if subject.Role != utils.TEACHER_ROLE {
+		return pkg.CustomError{
+			Code:    utils.FORBIDDEN,
+			Cause:   errors.New("only teachers can join a class staff"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	return s.classRepo.AcceptClassStaffInvite(c, classId, subject.UserID)
+}
+
+// RemoveClassStaff is also how staff leave a class or decline an invitation,
+// which needs no permission on the staff list.
+func (s *classUsecaseImpl) RemoveClassStaff(c context.Context, subject authz.Subject, classId int, teacherId uuid.UUID) pkg.CustomError {
+	customError := authz.AuthorizeScope(subject, authz.CLASSES_WRITE)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	if subject.Role != utils.TEACHER_ROLE || subject.UserID != teacherId {
+		_, resource, customError := s.classResource(c, subject, authz.STAFF, classId)
+		if customError.Cause != nil {
+			return customError
+		}
+
+		customError = authz.Authorize(subject, authz.DELETE, resource)
+		if customError.Cause != nil {
+			return customError
+		}
+	}
+
+	return s.classRepo.RemoveClassStaff(c, classId, teacherId)
 }
 
-func NewClassUsecase(classRepo repository.ClassRepository, studentRepo repository.StudentRepository) ClassUsecase {
+func NewClassUsecase(classRepo repository.ClassRepository, studentRepo repository.StudentRepository, teacherRepo repository.TeacherRepository, termRepo repository.TermRepository) ClassUsecase {
 	return &classUsecaseImpl{
 		classRepo:   classRepo,
 		studentRepo: studentRepo,
+		teacherRepo: teacherRepo,
+		termRepo:    termRepo,
 	}
 }
@@ -0,0 +1,203 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/rifkhia/lms-remake/internal/models"
+	"github.com/rifkhia/lms-remake/internal/pkg"
+	"github.com/rifkhia/lms-remake/internal/repository"
+	"github.com/rifkhia/lms-remake/internal/utils"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	dummyHash     string
+	dummyHashOnce sync.Once
+)
+
+// dummyPasswordHash is compared against when the email is unknown, so a login
+// for a missing account takes as long as one with a wrong password. It is made
+// with the configured algorithm so the timing matches real hashes.
+func dummyPasswordHash() string {
+	dummyHashOnce.Do(func() {
+		hash, err := utils.GeneratePassword("lms-remake-dummy-password")
+		if err != nil {
+			hash = "$2a$08$pWpQSb/J.lbyvkykK6Ny4OYnXMwpqkE0rBu0hN1XdPiaaDvpYx3GC"
+		}
+		dummyHash = hash
+	})
+	return dummyHash
+}
+
+// LoginGuard throttles password logins per account and per IP address and keeps
+// an audit trail of every attempt.
+type LoginGuard interface {
+	CheckLoginAllowed(c context.Context, email string, role string, ipAddress string) pkg.CustomError
+	RecordLoginFailure(c context.Context, email string, role string, ipAddress string, reason string) pkg.CustomError
+	RecordLoginSuccess(c context.Context, email string, role string, ipAddress string) pkg.CustomError
+}
+
+type loginGuardImpl struct {
+	loginAttemptRepo repository.LoginAttemptRepository
+}
+
+func invalidCredentialsError() pkg.CustomError {
+	return pkg.CustomError{
+		Code:    utils.UNAUTHORIZED,
+		Cause:   errors.New("invalid credentials"),
+		Service: utils.USECASE_SERVICE,
+	}
+}
+
+// lockedUntil doubles the lockout for every failure past maxFailures, starting
+// from LOGIN_LOCKOUT_BASE_SECONDS and capped at LOGIN_LOCKOUT_MAX_MINUTES.
+func lockedUntil(failures int, lastFailure time.Time, maxFailures int) time.Time {
+	if failures < maxFailures {
+		return time.Time{}
+	}
+
+	maxLockout := utils.LOGIN_LOCKOUT_MAX_MINUTES * time.Minute
+	lockout := time.Duration(float64(utils.LOGIN_LOCKOUT_BASE_SECONDS*time.Second) * math.Pow(2, float64(failures-maxFailures)))
+	if lockout <= 0 || lockout > maxLockout {
+		lockout = maxLockout
+	}
+
+	return lastFailure.Add(lockout)
+}
+
+// accountBlockedError records a login refused for the state of the account,
+// after the password was already checked.
+func accountBlockedError(c context.Context, loginGuard LoginGuard, email string, role string, ipAddress string, reason string, message string) pkg.CustomError {
+	customError := loginGuard.RecordLoginFailure(c, email, role, ipAddress, reason)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	return pkg.CustomError{
+		Code:    utils.FORBIDDEN,
+		Cause:   errors.New(message),
+		Service: utils.USECASE_SERVICE,
+	}
+}
+
+func (g *loginGuardImpl) CheckLoginAllowed(c context.Context, email string, role string, ipAddress string) pkg.CustomError {
+	email = strings.ToLower(email)
+	since := time.Now().Add(-utils.LOGIN_FAILURE_WINDOW_MINUTES * time.Minute)
+
+	accountFailures, accountLastFailure, customError := g.loginAttemptRepo.GetAccountFailures(c, email, role, since)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	ipFailures, ipLastFailure, customError := g.loginAttemptRepo.GetIPFailures(c, ipAddress, since)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	until := lockedUntil(accountFailures, accountLastFailure, utils.LOGIN_MAX_ACCOUNT_FAILURES)
+	if ipUntil := lockedUntil(ipFailures, ipLastFailure, utils.LOGIN_MAX_IP_FAILURES); ipUntil.After(until) {
+		until = ipUntil
+	}
+
+	if !until.After(time.Now()) {
+		return pkg.CustomError{}
+	}
+
+	customError = g.RecordLoginFailure(c, email, role, ipAddress, utils.LOGIN_FAILURE_LOCKED)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	return pkg.CustomError{
+		Code:    utils.TOO_MANY_REQUESTS,
+		Cause:   fmt.Errorf("too many failed login attempts, try again in %d seconds", int(time.Until(until).Seconds())+1),
+		Service: utils.USECASE_SERVICE,
+	}
+}
+
+func (g *loginGuardImpl) RecordLoginFailure(c context.Context, email string, role string, ipAddress string, reason string) pkg.CustomError {
+	return g.loginAttemptRepo.CreateLoginAttempt(c, &models.LoginAttempt{
+		Email:     strings.ToLower(email),
+		Role:      role,
+		IPAddress: ipAddress,
+		Success:   false,
+		Reason:    &reason,
+	})
+}
+
+func (g *loginGuardImpl) RecordLoginSuccess(c context.Context, email string, role string, ipAddress string) pkg.CustomError {
+	return g.loginAttemptRepo.CreateLoginAttempt(c, &models.LoginAttempt{
+		Email:     strings.ToLower(email),
+		Role:      role,
+		IPAddress: ipAddress,
+		Success:   true,
+	})
+}
+
+// loginAccount is the part of a student or teacher that decides whether they
+// may log in.
+type loginAccount struct {
+	ID          uuid.UUID
+	Email       string
+	VerifiedAt  *time.Time
+	ApprovedAt  *time.Time
+	SuspendedAt *time.Time
+}
+
+// studentLoginAccount and teacherLoginAccount turn a repository "not found" into a
+// nil account, so callers can tell it apart from a real failure.
+func studentLoginAccount(student *models.Student, customError pkg.CustomError) (*loginAccount, pkg.CustomError) {
+	if customError.Cause != nil {
+		if customError.Code == utils.BAD_REQUEST {
+			return nil, pkg.CustomError{}
+		}
+		return nil, customError
+	}
+
+	return &loginAccount{
+		ID:          student.ID,
+		Email:       student.Email,
+		VerifiedAt:  student.VerifiedAt,
+		SuspendedAt: student.SuspendedAt,
+	}, pkg.CustomError{}
+}
+
+func teacherLoginAccount(teacher *models.Teacher, customError pkg.CustomError) (*loginAccount, pkg.CustomError) {
+	if customError.Cause != nil {
+		if customError.Code == utils.BAD_REQUEST {
+			return nil, pkg.CustomError{}
+		}
+		return nil, customError
+	}
+
+	return &loginAccount{
+		ID:          teacher.ID,
+		Email:       teacher.Email,
+		VerifiedAt:  teacher.VerifiedAt,
+		ApprovedAt:  teacher.ApprovedAt,
+		SuspendedAt: teacher.SuspendedAt,
+	}, pkg.CustomError{}
+}
+
+// getLoginAccount returns nil when the account doesn't exist or was deleted.
+func getLoginAccount(c context.Context, studentRepo repository.StudentRepository, teacherRepo repository.TeacherRepository, role string, id uuid.UUID) (*loginAccount, pkg.CustomError) {
+	switch role {
+	case utils.STUDENT_ROLE:
+		return studentLoginAccount(studentRepo.GetStudentByID(c, id))
+	case utils.TEACHER_ROLE:
+		return teacherLoginAccount(teacherRepo.GetTeacherById(c, id))
+	default:
+		return nil, unknownAccountTypeError()
+	}
+}
+
+func NewLoginGuard(loginAttemptRepo repository.LoginAttemptRepository) LoginGuard {
+	return &loginGuardImpl{
+		loginAttemptRepo: loginAttemptRepo,
+	}
+}
@@ -0,0 +1,391 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/rifkhia/lms-remake/internal/authz"
+	"github.com/rifkhia/lms-remake/internal/dto"
+	"github.com/rifkhia/lms-remake/internal/pkg"
+	"github.com/rifkhia/lms-remake/internal/repository"
+	"github.com/rifkhia/lms-remake/internal/utils"
+	"time"
+)
+
+// MFAUsecase handles optional TOTP two-factor login for students and teachers.
+type MFAUsecase interface {
+	StartChallenge(c context.Context, userId uuid.UUID, role string) (string, pkg.CustomError)
+	VerifyLogin(c context.Context, request *dto.MFALoginRequest, client dto.ClientInfo) (interface{}, pkg.CustomError)
+	FetchStatus(c context.Context, subject authz.Subject) (interface{}, pkg.CustomError)
+	Enroll(c context.Context, subject authz.Subject) (interface{}, pkg.CustomError)
+	Confirm(c context.Context, subject authz.Subject, request *dto.MFACodeRequest) (interface{}, pkg.CustomError)
+	Disable(c context.Context, subject authz.Subject, request *dto.MFACodeRequest) pkg.CustomError
+	RegenerateRecoveryCodes(c context.Context, subject authz.Subject, request *dto.MFACodeRequest) (interface{}, pkg.CustomError)
+	ResetMFA(c context.Context, userId uuid.UUID, role string) pkg.CustomError
+}
+
+type mfaUsecaseImpl struct {
+	mfaRepo      repository.MFARepository
+	studentRepo  repository.StudentRepository
+	teacherRepo  repository.TeacherRepository
+	tokenService TokenService
+	loginGuard   LoginGuard
+}
+
+// StartChallenge is called once the password was accepted. It returns an MFA
+// token when the account has two-factor login on, or "" when the login can be
+// finished right away.
+func (s *mfaUsecaseImpl) StartChallenge(c context.Context, userId uuid.UUID, role string) (string, pkg.CustomError) {
+	secret, customError := s.mfaRepo.GetTOTPSecret(c, userId, role)
+	if customError.Cause != nil {
+		return "", customError
+	}
+
+	if secret == nil || secret.ConfirmedAt == nil {
+		return "", pkg.CustomError{}
+	}
+
+	return s.tokenService.IssueMFAToken(userId, role)
+}
+
+// VerifyLogin is the second step of a login. Wrong codes count towards the same
+// lockout as wrong passwords.
+func (s *mfaUsecaseImpl) VerifyLogin(c context.Context, request *dto.MFALoginRequest, client dto.ClientInfo) (interface{}, pkg.CustomError) {
+	claims, customError := s.tokenService.VerifyMFAToken(c, request.MFAToken)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	account, customError := getLoginAccount(c, s.studentRepo, s.teacherRepo, claims.Role, claims.UserID)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	if account == nil {
+		return nil, invalidCredentialsError()
+	}
+
+	customError = s.loginGuard.CheckLoginAllowed(c, account.Email, claims.Role, client.IPAddress)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	if account.SuspendedAt != nil {
+		return nil, accountBlockedError(c, s.loginGuard, account.Email, claims.Role, client.IPAddress, utils.LOGIN_FAILURE_SUSPENDED, "account is suspended")
+	}
+
+	valid, customError := s.checkCode(c, claims.UserID, claims.Role, request.Code, request.RecoveryCode)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	if !valid {
+		customError = s.loginGuard.RecordLoginFailure(c, account.Email, claims.Role, client.IPAddress, utils.LOGIN_FAILURE_INVALID_MFA_CODE)
+		if customError.Cause != nil {
+			return nil, customError
+		}
+		return nil, invalidMFACodeError()
+	}
+
+	customError = s.tokenService.RevokeAccessToken(c, claims.RegisteredClaims.ID, claims.ExpiresAt.Time)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	customError = s.loginGuard.RecordLoginSuccess(c, account.Email, claims.Role, client.IPAddress)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	accessToken, refreshToken, customError := s.tokenService.IssueTokens(c, claims.UserID, claims.Role, uuid.New(), client)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	return map[string]interface{}{
+		"access token":  accessToken,
+		"refresh token": refreshToken,
+	}, pkg.CustomError{}
+}
+
+func (s *mfaUsecaseImpl) FetchStatus(c context.Context, subject authz.Subject) (interface{}, pkg.CustomError) {
+	secret, customError := s.mfaRepo.GetTOTPSecret(c, subject.UserID, subject.Role)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	enabled := secret != nil && secret.ConfirmedAt != nil
+	recoveryCodes := 0
+	if enabled {
+		recoveryCodes, customError = s.mfaRepo.CountRecoveryCodes(c, subject.UserID, subject.Role)
+		if customError.Cause != nil {
+			return nil, customError
+		}
+	}
+
+	return map[string]interface{}{
+		"enabled":             enabled,
+		"recovery_codes_left": recoveryCodes,
+	}, pkg.CustomError{}
+}
+
+// Enroll creates a new secret for the user to add to an authenticator app.
+// Two-factor login only turns on after Confirm proves the app works.
+func (s *mfaUsecaseImpl) Enroll(c context.Context, subject authz.Subject) (interface{}, pkg.CustomError) {
+	account, customError := s.account(c, subject)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	existing, customError := s.mfaRepo.GetTOTPSecret(c, subject.UserID, subject.Role)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	if existing != nil && existing.ConfirmedAt != nil {
+		return nil, pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("two-factor authentication is already enabled"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	encrypted, err := utils.EncryptTOTPSecret(secret)
+	if err != nil {
+		return nil, pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	customError = s.mfaRepo.SaveTOTPSecret(c, subject.UserID, subject.Role, encrypted)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	return map[string]interface{}{
+		"secret":           secret,
+		"provisioning_uri": utils.TOTPProvisioningURI(account.Email, secret),
+	}, pkg.CustomError{}
+}
+
+func (s *mfaUsecaseImpl) Confirm(c context.Context, subject authz.Subject, request *dto.MFACodeRequest) (interface{}, pkg.CustomError) {
+	secret, customError := s.mfaRepo.GetTOTPSecret(c, subject.UserID, subject.Role)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	if secret == nil || secret.ConfirmedAt != nil {
+		return nil, pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("there is no pending two-factor enrollment"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	plainSecret, err := utils.DecryptTOTPSecret(secret.Secret)
+	if err != nil {
+		return nil, pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	step, ok := utils.ValidateTOTP(plainSecret, request.Code, time.Now())
+	if !ok {
+		return nil, invalidMFACodeError()
+	}
+
+	codes, hashes, customError := generateRecoveryCodes()
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	customError = s.mfaRepo.ConfirmTOTPSecret(c, subject.UserID, subject.Role, step, hashes)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	return map[string]interface{}{
+		"recovery_codes": codes,
+	}, pkg.CustomError{}
+}
+
+func (s *mfaUsecaseImpl) Disable(c context.Context, subject authz.Subject, request *dto.MFACodeRequest) pkg.CustomError {
+	customError := s.requireCode(c, subject, request)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	return s.mfaRepo.DeleteMFA(c, subject.UserID, subject.Role)
+}
+
+func (s *mfaUsecaseImpl) RegenerateRecoveryCodes(c context.Context, subject authz.Subject, request *dto.MFACodeRequest) (interface{}, pkg.CustomError) {
+	customError := s.requireCode(c, subject, request)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	codes, hashes, customError := generateRecoveryCodes()
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	customError = s.mfaRepo.ReplaceRecoveryCodes(c, subject.UserID, subject.Role, hashes)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	return map[string]interface{}{
+		"recovery_codes": codes,
+	}, pkg.CustomError{}
+}
+
+// ResetMFA is for admins helping a user who lost both the authenticator and
+// the recovery codes.
+func (s *mfaUsecaseImpl) ResetMFA(c context.Context, userId uuid.UUID, role string) pkg.CustomError {
+	return s.mfaRepo.DeleteMFA(c, userId, role)
+}
+
+// requireCode guards changes to an enabled second factor with a current code.
+func (s *mfaUsecaseImpl) requireCode(c context.Context, subject authz.Subject, request *dto.MFACodeRequest) pkg.CustomError {
+	secret, customError := s.mfaRepo.GetTOTPSecret(c, subject.UserID, subject.Role)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	if secret == nil || secret.ConfirmedAt == nil {
+		return pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("two-factor authentication is not enabled"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	valid, customError := s.checkCode(c, subject.UserID, subject.Role, request.Code, request.RecoveryCode)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	if !valid {
+		return invalidMFACodeError()
+	}
+
+	return pkg.CustomError{}
+}
+
+// checkCode accepts either a TOTP code or a recovery code, each only once.
+func (s *mfaUsecaseImpl) checkCode(c context.Context, userId uuid.UUID, role string, code string, recoveryCode string) (bool, pkg.CustomError) {
+	if recoveryCode != "" {
+		return s.mfaRepo.UseRecoveryCode(c, userId, role, utils.HashToken(utils.NormalizeRecoveryCode(recoveryCode)))
+	}
+
+	secret, customError := s.mfaRepo.GetTOTPSecret(c, userId, role)
+	if customError.Cause != nil {
+		return false, customError
+	}
+
+	if secret == nil || secret.ConfirmedAt == nil {
+		return false, pkg.CustomError{}
+	}
+
+	plainSecret, err := utils.DecryptTOTPSecret(secret.Secret)
+	if err != nil {
+		return false, pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	step, ok := utils.ValidateTOTP(plainSecret, code, time.Now())
+	if !ok {
+		return false, pkg.CustomError{}
+	}
+
+	return s.mfaRepo.UseTOTPStep(c, userId, role, step)
+}
+
+func (s *mfaUsecaseImpl) account(c context.Context, subject authz.Subject) (*loginAccount, pkg.CustomError) {
+	if subject.Role != utils.STUDENT_ROLE && subject.Role != utils.TEACHER_ROLE {
+		return nil, pkg.CustomError{
+			Code:    utils.FORBIDDEN,
+			Cause:   errors.New("two-factor authentication is only available for students and teachers"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	account, customError := getLoginAccount(c, s.studentRepo, s.teacherRepo, subject.Role, subject.UserID)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	if account == nil {
+		return nil, pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("no user found using current id"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	return account, pkg.CustomError{}
+}
+
+// generateRecoveryCodes returns the codes to show once and the hashes to store.
+func generateRecoveryCodes() ([]string, []string, pkg.CustomError) {
+	codes := make([]string, 0, utils.MFA_RECOVERY_CODES)
+	hashes := make([]string, 0, utils.MFA_RECOVERY_CODES)
+
+	for i := 0; i < utils.MFA_RECOVERY_CODES; i++ {
+		code, err := utils.GenerateRecoveryCode()
+		if err != nil {
+			return nil, nil, pkg.CustomError{
+				Code:    utils.INTERNAL_SERVER_ERROR,
+				Cause:   err,
+				Service: utils.USECASE_SERVICE,
+			}
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, utils.HashToken(code))
+	}
+
+	return codes, hashes, pkg.CustomError{}
+}
+
+// mfaChallenge is the answer to a correct password when a second factor is
+// still needed. The token is exchanged at /v1/auth/2fa/verify.
+func mfaChallenge(mfaToken string) map[string]interface{} {
+	return map[string]interface{}{
+		"mfa_required": true,
+		"mfa_token":    mfaToken,
+	}
+}
+
+func invalidMFACodeError() pkg.CustomError {
+	return pkg.CustomError{
+		Code:    utils.UNAUTHORIZED,
+		Cause:   errors.New("invalid two-factor code"),
+		Service: utils.USECASE_SERVICE,
+	}
+}
+
+func NewMFAUsecase(mfaRepo repository.MFARepository, studentRepo repository.StudentRepository, teacherRepo repository.TeacherRepository, tokenService TokenService, loginGuard LoginGuard) MFAUsecase {
+	return &mfaUsecaseImpl{
+		mfaRepo:      mfaRepo,
+		studentRepo:  studentRepo,
+		teacherRepo:  teacherRepo,
+		tokenService: tokenService,
+		loginGuard:   loginGuard,
+	}
+}
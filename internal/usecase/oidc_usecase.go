@@ -0,0 +1,357 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/rifkhia/lms-remake/internal/dto"
+	"github.com/rifkhia/lms-remake/internal/models"
+	"github.com/rifkhia/lms-remake/internal/oidc"
+	"github.com/rifkhia/lms-remake/internal/pkg"
+	"github.com/rifkhia/lms-remake/internal/repository"
+	"github.com/rifkhia/lms-remake/internal/utils"
+	"github.com/spf13/viper"
+	"strings"
+	"time"
+)
+
+// OIDCUsecase signs students and teachers in through the university identity
+// provider and then hands out our own tokens, exactly like a password login.
+type OIDCUsecase interface {
+	StartLogin(c context.Context, role string) (string, pkg.CustomError)
+	FinishLogin(c context.Context, state string, code string, client dto.ClientInfo) (interface{}, pkg.CustomError)
+}
+
+type oidcUsecaseImpl struct {
+	oidcRepo     repository.OIDCRepository
+	studentRepo  repository.StudentRepository
+	teacherRepo  repository.TeacherRepository
+	provider     oidc.Provider
+	tokenService TokenService
+	loginGuard   LoginGuard
+}
+
+func (s *oidcUsecaseImpl) StartLogin(c context.Context, role string) (string, pkg.CustomError) {
+	if s.provider == nil {
+		return "", ssoDisabledError()
+	}
+
+	state, err := utils.GenerateToken(32)
+	if err != nil {
+		return "", pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	nonce, err := utils.GenerateToken(16)
+	if err != nil {
+		return "", pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	codeVerifier, err := utils.GenerateToken(32)
+	if err != nil {
+		return "", pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	authorizationURL, err := s.provider.AuthCodeURL(c, state, nonce, codeVerifier)
+	if err != nil {
+		return "", pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   fmt.Errorf("identity provider is unavailable: %w", err),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	customError := s.oidcRepo.CreateLoginRequest(c, &models.OIDCLoginRequest{
+		StateHash:    utils.HashToken(state),
+		Role:         role,
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+		ExpiresAt:    time.Now().Add(time.Minute * time.Duration(viper.GetInt("OIDC_LOGIN_EXPIRY"))),
+	})
+	if customError.Cause != nil {
+		return "", customError
+	}
+
+	return authorizationURL, pkg.CustomError{}
+}
+
+func (s *oidcUsecaseImpl) FinishLogin(c context.Context, state string, code string, client dto.ClientInfo) (interface{}, pkg.CustomError) {
+	if s.provider == nil {
+		return nil, ssoDisabledError()
+	}
+
+	if state == "" || code == "" {
+		return nil, pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("state and code can't be blank"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	loginRequest, customError := s.oidcRepo.UseLoginRequest(c, utils.HashToken(state))
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	claims, err := s.provider.Exchange(c, code, loginRequest.CodeVerifier, loginRequest.Nonce)
+	if err != nil {
+		return nil, pkg.CustomError{
+			Code:    utils.UNAUTHORIZED,
+			Cause:   fmt.Errorf("single sign-on failed: %w", err),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	role := loginRequest.Role
+	account, customError := s.resolveAccount(c, role, claims)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	// the identity provider vouches for the address, which is all our own
+	// email verification would prove
+	if account.VerifiedAt == nil && claims.EmailVerified && strings.EqualFold(claims.Email, account.Email) {
+		customError = s.verifyAccount(c, role, account.ID)
+		if customError.Cause != nil {
+			return nil, customError
+		}
+		now := time.Now()
+		account.VerifiedAt = &now
+	}
+
+	if account.VerifiedAt == nil {
+		return nil, accountBlockedError(c, s.loginGuard, account.Email, role, client.IPAddress, utils.LOGIN_FAILURE_UNVERIFIED, "email is not verified yet")
+	}
+
+	if account.SuspendedAt != nil {
+		return nil, accountBlockedError(c, s.loginGuard, account.Email, role, client.IPAddress, utils.LOGIN_FAILURE_SUSPENDED, "account is suspended")
+	}
+
+	if role == utils.TEACHER_ROLE && account.ApprovedAt == nil {
+		return nil, accountBlockedError(c, s.loginGuard, account.Email, role, client.IPAddress, utils.LOGIN_FAILURE_UNAPPROVED, "account is waiting for admin approval")
+	}
+
+	customError = s.loginGuard.RecordLoginSuccess(c, account.Email, role, client.IPAddress)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	accessToken, refreshToken, customError := s.tokenService.IssueTokens(c, account.ID, role, uuid.New(), client)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	return map[string]interface{}{
+		"access token":  accessToken,
+		"refresh token": refreshToken,
+	}, pkg.CustomError{}
+}
+
+// resolveAccount finds the account an identity belongs to. An identity seen
+// before is already linked. Otherwise it is linked to the account with the same
+// NIM/NPM claim, then to the one with the same verified email, and when neither
+// exists a new account is provisioned.
+func (s *oidcUsecaseImpl) resolveAccount(c context.Context, role string, claims *oidc.Claims) (*loginAccount, pkg.CustomError) {
+	userId, customError := s.oidcRepo.GetIdentityUserId(c, claims.Issuer, claims.Subject, role)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	if userId != uuid.Nil {
+		account, customError := s.accountById(c, role, userId)
+		if customError.Cause != nil {
+			return nil, customError
+		}
+		if account == nil {
+			return nil, pkg.CustomError{
+				Code:    utils.FORBIDDEN,
+				Cause:   errors.New("the linked account was deleted"),
+				Service: utils.USECASE_SERVICE,
+			}
+		}
+		return account, pkg.CustomError{}
+	}
+
+	var account *loginAccount
+	if number := claims.Number(identifierClaim(role)); number != 0 {
+		account, customError = s.accountByNumber(c, role, number)
+		if customError.Cause != nil {
+			return nil, customError
+		}
+	}
+
+	if account == nil && claims.EmailVerified && claims.Email != "" {
+		account, customError = s.accountByEmail(c, role, claims.Email)
+		if customError.Cause != nil {
+			return nil, customError
+		}
+	}
+
+	if account == nil {
+		account, customError = s.provisionAccount(c, role, claims)
+		if customError.Cause != nil {
+			return nil, customError
+		}
+	}
+
+	customError = s.oidcRepo.CreateIdentity(c, &models.OIDCIdentity{
+		Issuer:  claims.Issuer,
+		Subject: claims.Subject,
+		Role:    role,
+		UserID:  account.ID,
+	})
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	return account, pkg.CustomError{}
+}
+
+// provisionAccount creates the account on first sign-in. It gets an unusable
+// random password, so the user can only add one through the reset flow.
+func (s *oidcUsecaseImpl) provisionAccount(c context.Context, role string, claims *oidc.Claims) (*loginAccount, pkg.CustomError) {
+	number := claims.Number(identifierClaim(role))
+	if !viper.GetBool("OIDC_AUTO_PROVISION") || !claims.EmailVerified || claims.Email == "" || number == 0 {
+		return nil, pkg.CustomError{
+			Code:    utils.FORBIDDEN,
+			Cause:   errors.New("no account matches this identity"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	randomPassword, err := utils.GenerateToken(32)
+	if err != nil {
+		return nil, pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	passwordHash, err := utils.GeneratePassword(randomPassword)
+	if err != nil {
+		return nil, pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	name := claims.Name
+	if name == "" {
+		name = strings.Split(claims.Email, "@")[0]
+	}
+
+	id := uuid.New()
+	var customError pkg.CustomError
+	switch role {
+	case utils.STUDENT_ROLE:
+		customError = s.studentRepo.CreateStudent(c, &models.Student{
+			ID:       id,
+			Name:     name,
+			NIM:      number,
+			Email:    claims.Email,
+			Password: passwordHash,
+		})
+	case utils.TEACHER_ROLE:
+		customError = s.teacherRepo.CreateTeacher(c, &models.Teacher{
+			ID:       id,
+			Name:     name,
+			NPM:      number,
+			Email:    claims.Email,
+			Password: passwordHash,
+		})
+	default:
+		customError = unknownAccountTypeError()
+	}
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	customError = s.verifyAccount(c, role, id)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	now := time.Now()
+	return &loginAccount{
+		ID:         id,
+		Email:      claims.Email,
+		VerifiedAt: &now,
+	}, pkg.CustomError{}
+}
+
+func (s *oidcUsecaseImpl) accountById(c context.Context, role string, id uuid.UUID) (*loginAccount, pkg.CustomError) {
+	return getLoginAccount(c, s.studentRepo, s.teacherRepo, role, id)
+}
+
+func (s *oidcUsecaseImpl) accountByNumber(c context.Context, role string, number int) (*loginAccount, pkg.CustomError) {
+	switch role {
+	case utils.STUDENT_ROLE:
+		return studentLoginAccount(s.studentRepo.GetStudentByNIM(c, number))
+	case utils.TEACHER_ROLE:
+		return teacherLoginAccount(s.teacherRepo.GetTeacherByNPM(c, number))
+	default:
+		return nil, unknownAccountTypeError()
+	}
+}
+
+func (s *oidcUsecaseImpl) accountByEmail(c context.Context, role string, email string) (*loginAccount, pkg.CustomError) {
+	switch role {
+	case utils.STUDENT_ROLE:
+		return studentLoginAccount(s.studentRepo.GetStudentByEmail(c, email))
+	case utils.TEACHER_ROLE:
+		return teacherLoginAccount(s.teacherRepo.GetTeacherByEmail(c, email))
+	default:
+		return nil, unknownAccountTypeError()
+	}
+}
+
+func (s *oidcUsecaseImpl) verifyAccount(c context.Context, role string, id uuid.UUID) pkg.CustomError {
+	switch role {
+	case utils.STUDENT_ROLE:
+		return s.studentRepo.VerifyStudent(c, id)
+	case utils.TEACHER_ROLE:
+		return s.teacherRepo.VerifyTeacher(c, id)
+	default:
+		return unknownAccountTypeError()
+	}
+}
+
+func identifierClaim(role string) string {
+	if role == utils.TEACHER_ROLE {
+		return viper.GetString("OIDC_NPM_CLAIM")
+	}
+	return viper.GetString("OIDC_NIM_CLAIM")
+}
+
+func ssoDisabledError() pkg.CustomError {
+	return pkg.CustomError{
+		Code:    utils.BAD_REQUEST,
+		Cause:   errors.New("single sign-on is not configured"),
+		Service: utils.USECASE_SERVICE,
+	}
+}
+
+func NewOIDCUsecase(oidcRepo repository.OIDCRepository, studentRepo repository.StudentRepository, teacherRepo repository.TeacherRepository, provider oidc.Provider, tokenService TokenService, loginGuard LoginGuard) OIDCUsecase {
+	return &oidcUsecaseImpl{
+		oidcRepo:     oidcRepo,
+		studentRepo:  studentRepo,
+		teacherRepo:  teacherRepo,
+		provider:     provider,
+		tokenService: tokenService,
+		loginGuard:   loginGuard,
+	}
+}
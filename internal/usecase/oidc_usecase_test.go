@@ -0,0 +1,429 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rifkhia/lms-remake/internal/dto"
+	"github.com/rifkhia/lms-remake/internal/models"
+	"github.com/rifkhia/lms-remake/internal/oidc"
+	"github.com/rifkhia/lms-remake/internal/oidc/oidctest"
+	"github.com/rifkhia/lms-remake/internal/pkg"
+	"github.com/rifkhia/lms-remake/internal/repository"
+	"github.com/rifkhia/lms-remake/internal/utils"
+	"github.com/spf13/viper"
+)
+
+// The fakes embed the repository interfaces, so a call the test didn't expect
+// panics instead of passing silently.
+
+type fakeOIDCRepository struct {
+	repository.OIDCRepository
+	requests   map[string]*models.OIDCLoginRequest
+	identities map[string]uuid.UUID
+}
+
+func identityKey(issuer string, subject string, role string) string {
+	return issuer + "|" + subject + "|" + role
+}
+
+func (r *fakeOIDCRepository) CreateLoginRequest(_ context.Context, request *models.OIDCLoginRequest) pkg.CustomError {
+	r.requests[request.StateHash] = request
+	return pkg.CustomError{}
+}
+
+func (r *fakeOIDCRepository) UseLoginRequest(_ context.Context, stateHash string) (*models.OIDCLoginRequest, pkg.CustomError) {
+	request, ok := r.requests[stateHash]
+	if !ok {
+		return nil, pkg.CustomError{Code: utils.BAD_REQUEST, Cause: errors.New("login request is invalid or expired")}
+	}
+	delete(r.requests, stateHash)
+	return request, pkg.CustomError{}
+}
+
+func (r *fakeOIDCRepository) GetIdentityUserId(_ context.Context, issuer string, subject string, role string) (uuid.UUID, pkg.CustomError) {
+	return r.identities[identityKey(issuer, subject, role)], pkg.CustomError{}
+}
+
+func (r *fakeOIDCRepository) CreateIdentity(_ context.Context, identity *models.OIDCIdentity) pkg.CustomError {
+	r.identities[identityKey(identity.Issuer, identity.Subject, identity.Role)] = identity.UserID
+	return pkg.CustomError{}
+}
+
+type fakeStudentRepository struct {
+	repository.StudentRepository
+	students []*models.Student
+}
+
+func (r *fakeStudentRepository) find(match func(student *models.Student) bool) (*models.Student, pkg.CustomError) {
+	for _, student := range r.students {
+		if match(student) {
+			return student, pkg.CustomError{}
+		}
+	}
+	return nil, pkg.CustomError{Code: utils.BAD_REQUEST, Cause: errors.New("no student found")}
+}
+
+func (r *fakeStudentRepository) GetStudentByID(_ context.Context, id uuid.UUID) (*models.Student, pkg.CustomError) {
+	return r.find(func(student *models.Student) bool { return student.ID == id })
+}
+
+func (r *fakeStudentRepository) GetStudentByNIM(_ context.Context, nim int) (*models.Student, pkg.CustomError) {
+	return r.find(func(student *models.Student) bool { return student.NIM == nim })
+}
+
+func (r *fakeStudentRepository) GetStudentByEmail(_ context.Context, email string) (*models.Student, pkg.CustomError) {
+	return r.find(func(student *models.Student) bool { return strings.EqualFold(student.Email, email) })
+}
+
+func (r *fakeStudentRepository) CreateStudent(_ context.Context, student *models.Student) pkg.CustomError {
+	r.students = append(r.students, student)
+	return pkg.CustomError{}
+}
+
+func (r *fakeStudentRepository) VerifyStudent(_ context.Context, id uuid.UUID) pkg.CustomError {
+	student, customError := r.GetStudentByID(context.Background(), id)
+	if customError.Cause != nil {
+		return customError
+	}
+	now := time.Now()
+	student.VerifiedAt = &now
+	return pkg.CustomError{}
+}
+
+type fakeTeacherRepository struct {
+	repository.TeacherRepository
+	teachers []*models.Teacher
+}
+
+func (r *fakeTeacherRepository) find(match func(teacher *models.Teacher) bool) (*models.Teacher, pkg.CustomError) {
+	for _, teacher := range r.teachers {
+		if match(teacher) {
+			return teacher, pkg.CustomError{}
+		}
+	}
+	return nil, pkg.CustomError{Code: utils.BAD_REQUEST, Cause: errors.New("no teacher found")}
+}
+
+func (r *fakeTeacherRepository) GetTeacherById(_ context.Context, id uuid.UUID) (*models.Teacher, pkg.CustomError) {
+	return r.find(func(teacher *models.Teacher) bool { return teacher.ID == id })
+}
+
+func (r *fakeTeacherRepository) GetTeacherByNPM(_ context.Context, npm int) (*models.Teacher, pkg.CustomError) {
+	return r.find(func(teacher *models.Teacher) bool { return teacher.NPM == npm })
+}
+
+func (r *fakeTeacherRepository) GetTeacherByEmail(_ context.Context, email string) (*models.Teacher, pkg.CustomError) {
+	return r.find(func(teacher *models.Teacher) bool { return strings.EqualFold(teacher.Email, email) })
+}
+
+func (r *fakeTeacherRepository) CreateTeacher(_ context.Context, teacher *models.Teacher) pkg.CustomError {
+	r.teachers = append(r.teachers, teacher)
+	return pkg.CustomError{}
+}
+
+func (r *fakeTeacherRepository) VerifyTeacher(_ context.Context, id uuid.UUID) pkg.CustomError {
+	teacher, customError := r.GetTeacherById(context.Background(), id)
+	if customError.Cause != nil {
+		return customError
+	}
+	now := time.Now()
+	teacher.VerifiedAt = &now
+	return pkg.CustomError{}
+}
+
+type fakeTokenService struct {
+	TokenService
+	issuedTo []uuid.UUID
+}
+
+func (s *fakeTokenService) IssueTokens(_ context.Context, userId uuid.UUID, _ string, _ uuid.UUID, _ dto.ClientInfo) (string, string, pkg.CustomError) {
+	s.issuedTo = append(s.issuedTo, userId)
+	return "access-token", "refresh-token", pkg.CustomError{}
+}
+
+type fakeLoginGuard struct {
+	LoginGuard
+	failures []string
+}
+
+func (g *fakeLoginGuard) RecordLoginSuccess(context.Context, string, string, string) pkg.CustomError {
+	return pkg.CustomError{}
+}
+
+func (g *fakeLoginGuard) RecordLoginFailure(_ context.Context, _ string, _ string, _ string, reason string) pkg.CustomError {
+	g.failures = append(g.failures, reason)
+	return pkg.CustomError{}
+}
+
+type oidcFixture struct {
+	server   *oidctest.Server
+	oidcRepo *fakeOIDCRepository
+	students *fakeStudentRepository
+	teachers *fakeTeacherRepository
+	tokens   *fakeTokenService
+	guard    *fakeLoginGuard
+	usecase  OIDCUsecase
+}
+
+func newOIDCFixture(t *testing.T) *oidcFixture {
+	t.Helper()
+
+	viper.Set("JWT_LEEWAY", 30)
+	viper.Set("OIDC_LOGIN_EXPIRY", 10)
+	viper.Set("OIDC_NIM_CLAIM", "nim")
+	viper.Set("OIDC_NPM_CLAIM", "npm")
+	viper.Set("OIDC_AUTO_PROVISION", true)
+	viper.Set("PASSWORD_HASH_ALGORITHM", utils.BCRYPT_ALGORITHM)
+	viper.Set("BCRYPT_COST", 4)
+
+	server := oidctest.NewServer("lms", "")
+	t.Cleanup(server.Close)
+
+	f := &oidcFixture{
+		server:   server,
+		oidcRepo: &fakeOIDCRepository{requests: map[string]*models.OIDCLoginRequest{}, identities: map[string]uuid.UUID{}},
+		students: &fakeStudentRepository{},
+		teachers: &fakeTeacherRepository{},
+		tokens:   &fakeTokenService{},
+		guard:    &fakeLoginGuard{},
+	}
+	provider := oidc.NewHTTPProvider(server.Issuer(), "lms", "", "http://localhost:3000/v1/auth/oidc/callback", "openid email profile")
+	f.usecase = NewOIDCUsecase(f.oidcRepo, f.students, f.teachers, provider, f.tokens, f.guard)
+
+	return f
+}
+
+// login signs in as subject at the identity provider, which puts claims in
+// the ID token.
+func (f *oidcFixture) login(t *testing.T, role string, subject string, claims map[string]interface{}) pkg.CustomError {
+	t.Helper()
+	c := context.Background()
+
+	authorizationURL, customError := f.usecase.StartLogin(c, role)
+	if customError.Cause != nil {
+		t.Fatalf("starting the login: %s", customError.Cause)
+	}
+
+	parsed, err := url.Parse(authorizationURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, err := f.server.Authorize(authorizationURL, subject, claims)
+	if err != nil {
+		t.Fatalf("authorizing: %s", err)
+	}
+
+	_, customError = f.usecase.FinishLogin(c, parsed.Query().Get("state"), code, dto.ClientInfo{IPAddress: "127.0.0.1"})
+	return customError
+}
+
+func (f *oidcFixture) loggedInAs(t *testing.T, id uuid.UUID) {
+	t.Helper()
+
+	if len(f.tokens.issuedTo) == 0 || f.tokens.issuedTo[len(f.tokens.issuedTo)-1] != id {
+		t.Fatalf("expected tokens for %s, got %v", id, f.tokens.issuedTo)
+	}
+}
+
+func (f *oidcFixture) linked(t *testing.T, subject string, role string, id uuid.UUID) {
+	t.Helper()
+
+	if got := f.oidcRepo.identities[identityKey(f.server.Issuer(), subject, role)]; got != id {
+		t.Fatalf("expected %s to be linked to %s, got %s", subject, id, got)
+	}
+}
+
+func verifiedNow() *time.Time {
+	now := time.Now()
+	return &now
+}
+
+func TestOIDCLinkByNIM(t *testing.T) {
+	f := newOIDCFixture(t)
+	student := &models.Student{ID: uuid.New(), NIM: 123, Email: "old@example.ac.id", VerifiedAt: verifiedNow()}
+	byEmail := &models.Student{ID: uuid.New(), NIM: 456, Email: "ana@example.ac.id", VerifiedAt: verifiedNow()}
+	f.students.students = []*models.Student{student, byEmail}
+
+	customError := f.login(t, utils.STUDENT_ROLE, "sub-1", map[string]interface{}{
+		"nim":            "123",
+		"email":          "ana@example.ac.id",
+		"email_verified": true,
+	})
+	if customError.Cause != nil {
+		t.Fatal(customError.Cause)
+	}
+
+	// the number wins over the email
+	f.loggedInAs(t, student.ID)
+	f.linked(t, "sub-1", utils.STUDENT_ROLE, student.ID)
+}
+
+func TestOIDCLinkByVerifiedEmail(t *testing.T) {
+	f := newOIDCFixture(t)
+	teacher := &models.Teacher{ID: uuid.New(), NPM: 9, Email: "Budi@example.ac.id", ApprovedAt: verifiedNow()}
+	f.teachers.teachers = []*models.Teacher{teacher}
+
+	customError := f.login(t, utils.TEACHER_ROLE, "sub-2", map[string]interface{}{
+		"email":          "budi@example.ac.id",
+		"email_verified": true,
+	})
+	if customError.Cause != nil {
+		t.Fatal(customError.Cause)
+	}
+
+	f.loggedInAs(t, teacher.ID)
+	f.linked(t, "sub-2", utils.TEACHER_ROLE, teacher.ID)
+	if teacher.VerifiedAt == nil {
+		t.Fatal("the provider's verified email should verify the account")
+	}
+}
+
+func TestOIDCUnverifiedEmailDoesNotLink(t *testing.T) {
+	f := newOIDCFixture(t)
+	student := &models.Student{ID: uuid.New(), NIM: 123, Email: "ana@example.ac.id", VerifiedAt: verifiedNow()}
+	f.students.students = []*models.Student{student}
+
+	customError := f.login(t, utils.STUDENT_ROLE, "sub-3", map[string]interface{}{
+		"email":          "ana@example.ac.id",
+		"email_verified": false,
+	})
+	if customError.Cause == nil || customError.Code != utils.FORBIDDEN {
+		t.Fatalf("expected a forbidden error, got %+v", customError)
+	}
+
+	if len(f.tokens.issuedTo) != 0 || len(f.oidcRepo.identities) != 0 || len(f.students.students) != 1 {
+		t.Fatal("an unverified email must not link or provision an account")
+	}
+}
+
+func TestOIDCAutoProvision(t *testing.T) {
+	f := newOIDCFixture(t)
+
+	customError := f.login(t, utils.STUDENT_ROLE, "sub-4", map[string]interface{}{
+		"nim":            42,
+		"email":          "cici@example.ac.id",
+		"email_verified": true,
+		"name":           "Cici",
+	})
+	if customError.Cause != nil {
+		t.Fatal(customError.Cause)
+	}
+
+	if len(f.students.students) != 1 {
+		t.Fatalf("expected a provisioned student, got %d", len(f.students.students))
+	}
+	student := f.students.students[0]
+	if student.NIM != 42 || student.Email != "cici@example.ac.id" || student.Name != "Cici" || student.VerifiedAt == nil {
+		t.Fatalf("unexpected student %+v", student)
+	}
+	if utils.ValidatePassword(student.Password, "").Cause == nil {
+		t.Fatal("a provisioned account must not have a usable password")
+	}
+
+	f.loggedInAs(t, student.ID)
+	f.linked(t, "sub-4", utils.STUDENT_ROLE, student.ID)
+
+	// the next login finds the identity, even after the email changed
+	customError = f.login(t, utils.STUDENT_ROLE, "sub-4", map[string]interface{}{"email": "new@example.ac.id"})
+	if customError.Cause != nil {
+		t.Fatal(customError.Cause)
+	}
+	f.loggedInAs(t, student.ID)
+	if len(f.students.students) != 1 {
+		t.Fatal("a linked identity must not provision again")
+	}
+}
+
+func TestOIDCAutoProvisionedTeacherNeedsApproval(t *testing.T) {
+	f := newOIDCFixture(t)
+
+	customError := f.login(t, utils.TEACHER_ROLE, "sub-5", map[string]interface{}{
+		"npm":            7,
+		"email":          "dedi@example.ac.id",
+		"email_verified": true,
+	})
+	if customError.Cause == nil || customError.Code != utils.FORBIDDEN || !strings.Contains(customError.Cause.Error(), "approval") {
+		t.Fatalf("expected to wait for approval, got %+v", customError)
+	}
+
+	if len(f.teachers.teachers) != 1 || f.teachers.teachers[0].Name != "dedi" {
+		t.Fatalf("expected a provisioned teacher named after the email, got %+v", f.teachers.teachers)
+	}
+	if len(f.tokens.issuedTo) != 0 || len(f.guard.failures) != 1 || f.guard.failures[0] != utils.LOGIN_FAILURE_UNAPPROVED {
+		t.Fatalf("expected an unapproved login failure, got %v", f.guard.failures)
+	}
+}
+
+func TestOIDCAutoProvisionOff(t *testing.T) {
+	f := newOIDCFixture(t)
+	viper.Set("OIDC_AUTO_PROVISION", false)
+
+	customError := f.login(t, utils.STUDENT_ROLE, "sub-6", map[string]interface{}{
+		"nim":            42,
+		"email":          "cici@example.ac.id",
+		"email_verified": true,
+	})
+	if customError.Cause == nil || customError.Code != utils.FORBIDDEN {
+		t.Fatalf("expected a forbidden error, got %+v", customError)
+	}
+	if len(f.students.students) != 0 {
+		t.Fatal("no account may be provisioned with OIDC_AUTO_PROVISION off")
+	}
+}
+
+func TestOIDCRejectedIDToken(t *testing.T) {
+	tests := map[string]map[string]interface{}{
+		"bad nonce":    {"nonce": "replayed"},
+		"bad audience": {"aud": "another-client"},
+		"bad issuer":   {"iss": "https://evil.example"},
+		"expired":      {"exp": time.Now().Add(-time.Hour).Unix()},
+	}
+
+	for name, claims := range tests {
+		t.Run(name, func(t *testing.T) {
+			f := newOIDCFixture(t)
+			claims["nim"] = 42
+			claims["email"] = "cici@example.ac.id"
+			claims["email_verified"] = true
+
+			customError := f.login(t, utils.STUDENT_ROLE, "sub-7", claims)
+			if customError.Cause == nil || customError.Code != utils.UNAUTHORIZED {
+				t.Fatalf("expected an unauthorized error, got %+v", customError)
+			}
+			if len(f.students.students) != 0 || len(f.tokens.issuedTo) != 0 {
+				t.Fatal("a rejected id token must not sign anyone in")
+			}
+		})
+	}
+}
+
+func TestOIDCStateIsSingleUse(t *testing.T) {
+	f := newOIDCFixture(t)
+	c := context.Background()
+
+	authorizationURL, customError := f.usecase.StartLogin(c, utils.STUDENT_ROLE)
+	if customError.Cause != nil {
+		t.Fatal(customError.Cause)
+	}
+	parsed, _ := url.Parse(authorizationURL)
+	state := parsed.Query().Get("state")
+
+	code, err := f.server.Authorize(authorizationURL, "sub-8", map[string]interface{}{"nim": 1, "email": "e@example.ac.id", "email_verified": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, customError := f.usecase.FinishLogin(c, state, code, dto.ClientInfo{}); customError.Cause != nil {
+		t.Fatal(customError.Cause)
+	}
+	if _, customError := f.usecase.FinishLogin(c, state, code, dto.ClientInfo{}); customError.Cause == nil {
+		t.Fatal("a state must not finish a login twice")
+	}
+}
@@ -0,0 +1,81 @@
+package usecase
+
+import (
+	"fmt"
+	"github.com/rifkhia/lms-remake/internal/models"
+	"time"
+)
+
+const minutesPerWeek = 7 * 24 * 60
+
+// ScheduleConflictError refuses a class that meets while another class in the
+// schedule does. The conflicts are sent along in the error body.
+type ScheduleConflictError struct {
+	Conflicts []*models.ScheduleConflict
+}
+
+func (e *ScheduleConflictError) Error() string {
+	return fmt.Sprintf("the class overlaps %d meeting(s) already in the schedule", len(e.Conflicts))
+}
+
+func (e *ScheduleConflictError) Details() map[string]interface{} {
+	return map[string]interface{}{
+		"conflicts": e.Conflicts,
+	}
+}
+
+// findScheduleConflicts pairs each meeting with the schedule entries of other
+// classes it overlaps. Both sides must already be formatted, see
+// formatMeetings.
+func findScheduleConflicts(meetings []*models.ClassMeeting, schedule []*models.ScheduleEntry, now time.Time) []*models.ScheduleConflict {
+	conflicts := []*models.ScheduleConflict{}
+	for _, meeting := range meetings {
+		start, end := weekMinutes(meeting, now)
+		for _, entry := range schedule {
+			if entry.ClassId == meeting.ClassId && meeting.ClassId != 0 {
+				continue
+			}
+
+			otherStart, otherEnd := weekMinutes(&entry.ClassMeeting, now)
+			// A meeting late on sunday in one timezone can be early on
+			// monday in another, so also compare across the week boundary.
+			for _, shift := range []int{-minutesPerWeek, 0, minutesPerWeek} {
+				if start < otherEnd+shift && otherStart+shift < end {
+					conflicts = append(conflicts, &models.ScheduleConflict{
+						ClassId:       entry.ClassId,
+						ClassName:     entry.Name,
+						Meeting:       &entry.ClassMeeting,
+						ConflictsWith: meeting,
+					})
+					break
+				}
+			}
+		}
+	}
+
+	return conflicts
+}
+
+// weekMinutes places a meeting in the week, in minutes since monday 00:00
+// UTC, with the offset its timezone has at now.
+func weekMinutes(meeting *models.ClassMeeting, now time.Time) (int, int) {
+	location, err := time.LoadLocation(meeting.Timezone)
+	if err != nil {
+		location = time.UTC
+	}
+	_, offset := now.In(location).Zone()
+
+	base := (meeting.Weekday-1)*24*60 - offset/60
+
+	return base + clockMinutes(meeting.StartTime), base + clockMinutes(meeting.EndTime)
+}
+
+// clockMinutes turns a "15:04" time into minutes since midnight.
+func clockMinutes(clock string) int {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0
+	}
+
+	return t.Hour()*60 + t.Minute()
+}
@@ -0,0 +1,92 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/rifkhia/lms-remake/internal/authz"
+	"github.com/rifkhia/lms-remake/internal/models"
+	"github.com/rifkhia/lms-remake/internal/pkg"
+	"github.com/rifkhia/lms-remake/internal/repository"
+	"github.com/rifkhia/lms-remake/internal/utils"
+)
+
+// SessionUsecase lets users see where they are logged in and sign devices out.
+// currentId is the session of the request, uuid.Nil for tokens older than
+// sessions.
+type SessionUsecase interface {
+	FetchSessions(c context.Context, subject authz.Subject, currentId uuid.UUID) ([]*models.Session, pkg.CustomError)
+	RevokeSession(c context.Context, subject authz.Subject, id uuid.UUID) pkg.CustomError
+	RevokeOtherSessions(c context.Context, subject authz.Subject, currentId uuid.UUID) pkg.CustomError
+}
+
+type sessionUsecaseImpl struct {
+	sessionRepo  repository.SessionRepository
+	tokenService TokenService
+}
+
+func (s *sessionUsecaseImpl) FetchSessions(c context.Context, subject authz.Subject, currentId uuid.UUID) ([]*models.Session, pkg.CustomError) {
+	sessions, customError := s.sessionRepo.GetActiveSessions(c, subject.UserID, subject.Role)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	for _, session := range sessions {
+		session.Current = session.ID == currentId
+	}
+
+	return sessions, pkg.CustomError{}
+}
+
+func (s *sessionUsecaseImpl) RevokeSession(c context.Context, subject authz.Subject, id uuid.UUID) pkg.CustomError {
+	sessions, customError := s.sessionRepo.GetActiveSessions(c, subject.UserID, subject.Role)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	for _, session := range sessions {
+		if session.ID == id {
+			return s.tokenService.RevokeRefreshTokenFamily(c, id)
+		}
+	}
+
+	return pkg.CustomError{
+		Code:    utils.BAD_REQUEST,
+		Cause:   errors.New("no active session found using current id"),
+		Service: utils.USECASE_SERVICE,
+	}
+}
+
+func (s *sessionUsecaseImpl) RevokeOtherSessions(c context.Context, subject authz.Subject, currentId uuid.UUID) pkg.CustomError {
+	if currentId == uuid.Nil {
+		return pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("current session is unknown, please login again"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	sessions, customError := s.sessionRepo.GetActiveSessions(c, subject.UserID, subject.Role)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	for _, session := range sessions {
+		if session.ID == currentId {
+			continue
+		}
+		customError = s.tokenService.RevokeRefreshTokenFamily(c, session.ID)
+		if customError.Cause != nil {
+			return customError
+		}
+	}
+
+	return pkg.CustomError{}
+}
+
+func NewSessionUsecase(sessionRepo repository.SessionRepository, tokenService TokenService) SessionUsecase {
+	return &sessionUsecaseImpl{
+		sessionRepo:  sessionRepo,
+		tokenService: tokenService,
+	}
+}
@@ -4,30 +4,47 @@ import (
 	"context"
 	"errors"
 	"github.com/google/uuid"
+	"github.com/rifkhia/lms-remake/internal/authz"
 	"github.com/rifkhia/lms-remake/internal/dto"
 	"github.com/rifkhia/lms-remake/internal/models"
 	"github.com/rifkhia/lms-remake/internal/pkg"
 	"github.com/rifkhia/lms-remake/internal/repository"
 	"github.com/rifkhia/lms-remake/internal/utils"
+	"github.com/spf13/viper"
 	"log"
 	"strings"
+	"time"
 )
 
 type StudentUsecase interface {
-	FetchStudentById(c context.Context, id uuid.UUID) (*models.StudentProfile, pkg.CustomError)
+	FetchStudentById(c context.Context, subject authz.Subject, id uuid.UUID) (*models.StudentProfile, pkg.CustomError)
 	FetchStudentByName(c context.Context, name string) ([]*models.Student, pkg.CustomError)
 	Register(c context.Context, student *dto.StudentRegisterRequest) (interface{}, pkg.CustomError)
-	Login(c context.Context, request *dto.StudentLoginRequest) (interface{}, pkg.CustomError)
-	DeleteStudent(c context.Context, id uuid.UUID) pkg.CustomError
-	EditProfileStudent(c context.Context, request *dto.StudentProfileRequest) pkg.CustomError
-	FetchStudentSchedule(c context.Context, id uuid.UUID) ([]*models.StudentSchedule, pkg.CustomError)
+	Login(c context.Context, request *dto.StudentLoginRequest, client dto.ClientInfo) (interface{}, pkg.CustomError)
+	RequestDeletion(c context.Context, subject authz.Subject, id uuid.UUID) (time.Time, pkg.CustomError)
+	CancelDeletion(c context.Context, subject authz.Subject, id uuid.UUID) pkg.CustomError
+	ExportData(c context.Context, subject authz.Subject, id uuid.UUID) (*dto.StudentExport, pkg.CustomError)
+	PurgeDeletedStudents(c context.Context) (int, pkg.CustomError)
+	EditProfileStudent(c context.Context, subject authz.Subject, request *dto.StudentProfileRequest) pkg.CustomError
+	FetchStudentSchedule(c context.Context, id uuid.UUID, term string) ([]*models.ScheduleEntry, pkg.CustomError)
+	Verify(c context.Context, request *dto.VerifyEmailRequest, client dto.ClientInfo) (interface{}, pkg.CustomError)
+	ResendVerification(c context.Context, request *dto.ResendVerificationRequest) pkg.CustomError
 }
 
 type StudentUsecaseImpl struct {
-	studentRepo repository.StudentRepository
+	studentRepo         repository.StudentRepository
+	tokenService        TokenService
+	verificationService VerificationService
+	loginGuard          LoginGuard
+	mfaUsecase          MFAUsecase
+	termRepo            repository.TermRepository
 }
 
-func (s *StudentUsecaseImpl) FetchStudentById(c context.Context, id uuid.UUID) (*models.StudentProfile, pkg.CustomError) {
+func (s *StudentUsecaseImpl) FetchStudentById(c context.Context, subject authz.Subject, id uuid.UUID) (*models.StudentProfile, pkg.CustomError) {
+	err := authz.Authorize(subject, authz.READ, authz.Resource{Type: authz.PROFILE, OwnerID: id})
+	if err.Cause != nil {
+		return nil, err
+	}
 
 	studentResult, err := s.studentRepo.GetStudentByID(c, id)
 	if err.Cause != nil {
@@ -72,23 +89,86 @@ func (s *StudentUsecaseImpl) Register(c context.Context, student *dto.StudentReg
 		return nil, err
 	}
 
-	accessToken, err := utils.CreateAccessToken(studentRequest.ID, utils.STUDENT_ROLE)
+	err = s.verificationService.SendVerificationCode(c, studentRequest.ID, utils.STUDENT_ROLE, studentRequest.Email)
+	if err.Cause != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"email":    studentRequest.Email,
+		"verified": false,
+	}, pkg.CustomError{}
+}
+
+func (s *StudentUsecaseImpl) Verify(c context.Context, request *dto.VerifyEmailRequest, client dto.ClientInfo) (interface{}, pkg.CustomError) {
+	if request.Email == "" || request.Code == "" {
+		return nil, pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("email or code can't be blank"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	student, err := s.studentRepo.GetStudentByEmail(c, request.Email)
+	if err.Cause != nil {
+		return nil, err
+	}
+
+	if student.VerifiedAt != nil {
+		return nil, pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("email already verified"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	err = s.verificationService.CheckVerificationCode(c, student.ID, utils.STUDENT_ROLE, request.Code)
 	if err.Cause != nil {
 		return nil, err
 	}
 
-	refreshToken, err := utils.CreateRefreshToken(studentRequest.ID, accessToken, utils.STUDENT_ROLE)
+	err = s.studentRepo.VerifyStudent(c, student.ID)
+	if err.Cause != nil {
+		return nil, err
+	}
+
+	if student.SuspendedAt != nil {
+		return nil, pkg.CustomError{
+			Code:    utils.FORBIDDEN,
+			Cause:   errors.New("account is suspended"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	accessToken, refreshToken, err := s.tokenService.IssueTokens(c, student.ID, utils.STUDENT_ROLE, uuid.New(), client)
 	if err.Cause != nil {
 		return nil, err
 	}
 
 	return map[string]interface{}{
-		"Access token":  accessToken,
-		"Refresh token": refreshToken,
+		"access token":  accessToken,
+		"refresh token": refreshToken,
 	}, pkg.CustomError{}
 }
 
-func (s *StudentUsecaseImpl) Login(c context.Context, request *dto.StudentLoginRequest) (interface{}, pkg.CustomError) {
+func (s *StudentUsecaseImpl) ResendVerification(c context.Context, request *dto.ResendVerificationRequest) pkg.CustomError {
+	student, err := s.studentRepo.GetStudentByEmail(c, request.Email)
+	if err.Cause != nil {
+		return err
+	}
+
+	if student.VerifiedAt != nil {
+		return pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("email already verified"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	return s.verificationService.SendVerificationCode(c, student.ID, utils.STUDENT_ROLE, student.Email)
+}
+
+func (s *StudentUsecaseImpl) Login(c context.Context, request *dto.StudentLoginRequest, client dto.ClientInfo) (interface{}, pkg.CustomError) {
 	if request.Email == "" || request.Password == "" {
 		return nil, pkg.CustomError{
 			Code:    utils.BAD_REQUEST,
@@ -97,42 +177,201 @@ func (s *StudentUsecaseImpl) Login(c context.Context, request *dto.StudentLoginR
 		}
 	}
 
-	student, err := s.studentRepo.GetStudentByEmail(c, request.Email)
+	err := s.loginGuard.CheckLoginAllowed(c, request.Email, utils.STUDENT_ROLE, client.IPAddress)
 	if err.Cause != nil {
 		return nil, err
 	}
 
-	err = utils.ValidatePassword(student.Password, request.Password)
+	passwordHash := dummyPasswordHash()
+	student, err := s.studentRepo.GetStudentByEmail(c, request.Email)
+	if err.Cause != nil && err.Code != utils.BAD_REQUEST {
+		return nil, err
+	}
+	if student != nil {
+		passwordHash = student.Password
+	}
+
+	err = utils.ValidatePassword(passwordHash, request.Password)
+	if err.Cause != nil || student == nil {
+		customError := s.loginGuard.RecordLoginFailure(c, request.Email, utils.STUDENT_ROLE, client.IPAddress, utils.LOGIN_FAILURE_INVALID_CREDENTIALS)
+		if customError.Cause != nil {
+			return nil, customError
+		}
+		return nil, invalidCredentialsError()
+	}
+
+	if student.VerifiedAt == nil {
+		return nil, accountBlockedError(c, s.loginGuard, request.Email, utils.STUDENT_ROLE, client.IPAddress, utils.LOGIN_FAILURE_UNVERIFIED, "email is not verified yet")
+	}
+
+	if student.SuspendedAt != nil {
+		return nil, accountBlockedError(c, s.loginGuard, request.Email, utils.STUDENT_ROLE, client.IPAddress, utils.LOGIN_FAILURE_SUSPENDED, "account is suspended")
+	}
+
+	if utils.PasswordNeedsRehash(student.Password) {
+		s.rehashPassword(c, student.ID, request.Password)
+	}
+
+	// with two-factor login on, the login only succeeds after the code is
+	// checked, so wrong codes keep counting towards the lockout
+	mfaToken, err := s.mfaUsecase.StartChallenge(c, student.ID, utils.STUDENT_ROLE)
 	if err.Cause != nil {
 		return nil, err
 	}
+	if mfaToken != "" {
+		return mfaChallenge(mfaToken), pkg.CustomError{}
+	}
 
-	accessToken, err := utils.CreateAccessToken(student.ID, utils.STUDENT_ROLE)
+	err = s.loginGuard.RecordLoginSuccess(c, request.Email, utils.STUDENT_ROLE, client.IPAddress)
 	if err.Cause != nil {
 		return nil, err
 	}
 
-	refreshToken, err := utils.CreateRefreshToken(student.ID, accessToken, utils.STUDENT_ROLE)
+	accessToken, refreshToken, err := s.tokenService.IssueTokens(c, student.ID, utils.STUDENT_ROLE, uuid.New(), client)
 	if err.Cause != nil {
 		return nil, err
 	}
 
 	return map[string]interface{}{
-		"Access token":  accessToken,
-		"Refresh token": refreshToken,
+		"access token":  accessToken,
+		"refresh token": refreshToken,
 	}, pkg.CustomError{}
 }
 
-func (s *StudentUsecaseImpl) DeleteStudent(c context.Context, id uuid.UUID) pkg.CustomError {
-	customError := s.studentRepo.DeleteStudent(c, id)
+// rehashPassword upgrades a stored hash made with outdated settings. A failure
+// here must not block the login, so it is only logged.
+func (s *StudentUsecaseImpl) rehashPassword(c context.Context, id uuid.UUID, password string) {
+	passwordHash, err := utils.GeneratePassword(password)
+	if err != nil {
+		log.Printf("failed to rehash password for student %s: %s", id, err)
+		return
+	}
+
+	customError := s.studentRepo.UpdateStudentPassword(c, id, passwordHash)
+	if customError.Cause != nil {
+		log.Printf("failed to rehash password for student %s: %s", id, customError.Cause)
+	}
+}
+
+// RequestDeletion schedules the student's account for closure once the grace
+// period has passed. Asking again keeps the original date.
+func (s *StudentUsecaseImpl) RequestDeletion(c context.Context, subject authz.Subject, id uuid.UUID) (time.Time, pkg.CustomError) {
+	customError := authz.Authorize(subject, authz.DELETE, authz.Resource{Type: authz.PROFILE, OwnerID: id})
+	if customError.Cause != nil {
+		return time.Time{}, customError
+	}
+
+	student, customError := s.studentRepo.GetStudentByID(c, id)
+	if customError.Cause != nil {
+		return time.Time{}, customError
+	}
+
+	if student.DeletionScheduledAt != nil {
+		return *student.DeletionScheduledAt, pkg.CustomError{}
+	}
+
+	scheduledAt := time.Now().Add(time.Hour * 24 * time.Duration(viper.GetInt("STUDENT_DELETION_GRACE_DAYS")))
+	customError = s.studentRepo.ScheduleStudentDeletion(c, id, scheduledAt)
+	if customError.Cause != nil {
+		return time.Time{}, customError
+	}
+
+	return scheduledAt, pkg.CustomError{}
+}
+
+func (s *StudentUsecaseImpl) CancelDeletion(c context.Context, subject authz.Subject, id uuid.UUID) pkg.CustomError {
+	customError := authz.Authorize(subject, authz.DELETE, authz.Resource{Type: authz.PROFILE, OwnerID: id})
 	if customError.Cause != nil {
 		return customError
 	}
 
-	return pkg.CustomError{}
+	student, customError := s.studentRepo.GetStudentByID(c, id)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	if student.DeletionScheduledAt == nil {
+		return pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("no account deletion is pending"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	return s.studentRepo.CancelStudentDeletion(c, id)
+}
+
+func (s *StudentUsecaseImpl) ExportData(c context.Context, subject authz.Subject, id uuid.UUID) (*dto.StudentExport, pkg.CustomError) {
+	customError := authz.Authorize(subject, authz.READ, authz.Resource{Type: authz.PROFILE, OwnerID: id})
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	student, customError := s.studentRepo.GetStudentByID(c, id)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	profile, customError := s.studentRepo.GetStudentProfile(c, id)
+	if customError.Cause != nil {
+		if !strings.Contains(customError.Cause.Error(), "no student profile found") {
+			return nil, customError
+		}
+		profile = nil
+	}
+
+	enrollments, customError := s.studentRepo.GetStudentEnrollments(c, id)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	submissions, customError := s.studentRepo.GetStudentSubmissions(c, id)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	return &dto.StudentExport{
+		ExportedAt:  time.Now(),
+		Account:     student,
+		Profile:     profile,
+		Enrollments: enrollments,
+		Submissions: submissions,
+	}, pkg.CustomError{}
+}
+
+// PurgeDeletedStudents anonymizes every student whose grace period has ended
+// and signs them out everywhere. A failure for one student is logged and does
+// not hold up the rest; it returns how many were closed.
+func (s *StudentUsecaseImpl) PurgeDeletedStudents(c context.Context) (int, pkg.CustomError) {
+	ids, customError := s.studentRepo.GetStudentsDueForDeletion(c)
+	if customError.Cause != nil {
+		return 0, customError
+	}
+
+	purged := 0
+	for _, id := range ids {
+		customError = s.studentRepo.AnonymizeStudent(c, id)
+		if customError.Cause != nil {
+			log.Printf("failed to anonymize student %s: %s", id, customError.Cause)
+			continue
+		}
+		purged++
+
+		customError = s.tokenService.RevokeAllUserTokens(c, id, utils.STUDENT_ROLE)
+		if customError.Cause != nil {
+			log.Printf("failed to revoke tokens for deleted student %s: %s", id, customError.Cause)
+		}
+	}
+
+	return purged, pkg.CustomError{}
 }
 
-func (s *StudentUsecaseImpl) EditProfileStudent(c context.Context, request *dto.StudentProfileRequest) pkg.CustomError {
+func (s *StudentUsecaseImpl) EditProfileStudent(c context.Context, subject authz.Subject, request *dto.StudentProfileRequest) pkg.CustomError {
+	customError := authz.Authorize(subject, authz.UPDATE, authz.Resource{Type: authz.PROFILE, OwnerID: request.ID})
+	if customError.Cause != nil {
+		return customError
+	}
+
 	student, customError := s.studentRepo.GetStudentProfile(c, request.ID)
 	if customError.Cause != nil {
 		if strings.Contains(customError.Cause.Error(), "no student profile found") {
@@ -155,29 +394,39 @@ func (s *StudentUsecaseImpl) EditProfileStudent(c context.Context, request *dto.
 	return pkg.CustomError{}
 }
 
-func (s *StudentUsecaseImpl) FetchStudentSchedule(c context.Context, id uuid.UUID) ([]*models.StudentSchedule, pkg.CustomError) {
-	studentSchedules, customError := s.studentRepo.FetchStudentClass(c, id)
+// FetchStudentSchedule lists the student's weekly meetings, for the current
+// term unless term says otherwise (see resolveTermFilter).
+func (s *StudentUsecaseImpl) FetchStudentSchedule(c context.Context, id uuid.UUID, term string) ([]*models.ScheduleEntry, pkg.CustomError) {
+	termId, customError := resolveTermFilter(c, s.termRepo, term)
 	if customError.Cause != nil {
 		return nil, customError
 	}
 
-	for _, schedule := range studentSchedules {
-		schedule.Day = utils.ConvertIntToDay(schedule.Day)
-		schedule.StartTime, customError = utils.ConvertTimes(schedule.StartTime)
-		if customError.Cause != nil {
-			return nil, customError
-		}
-		schedule.EndTime, customError = utils.ConvertTimes(schedule.EndTime)
-		if customError.Cause != nil {
-			return nil, customError
-		}
+	studentSchedules, customError := s.studentRepo.FetchStudentClass(c, id, termId)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	meetings := make([]*models.ClassMeeting, len(studentSchedules))
+	for i, schedule := range studentSchedules {
+		meetings[i] = &schedule.ClassMeeting
+	}
+
+	customError = formatMeetings(meetings)
+	if customError.Cause != nil {
+		return nil, customError
 	}
 
 	return studentSchedules, pkg.CustomError{}
 }
 
-func NewStudentUsecase(repo repository.StudentRepository) StudentUsecase {
+func NewStudentUsecase(repo repository.StudentRepository, tokenService TokenService, verificationService VerificationService, loginGuard LoginGuard, mfaUsecase MFAUsecase, termRepo repository.TermRepository) StudentUsecase {
 	return &StudentUsecaseImpl{
-		studentRepo: repo,
+		studentRepo:         repo,
+		tokenService:        tokenService,
+		verificationService: verificationService,
+		loginGuard:          loginGuard,
+		mfaUsecase:          mfaUsecase,
+		termRepo:            termRepo,
 	}
 }
@@ -2,6 +2,8 @@ package usecase
 
 import (
 	"context"
+	"errors"
+	"github.com/google/uuid"
 	"github.com/rifkhia/lms-remake/internal/dto"
 	error2 "github.com/rifkhia/lms-remake/internal/pkg"
 	"github.com/rifkhia/lms-remake/internal/repository"
@@ -10,33 +12,74 @@ import (
 )
 
 type teacherUsecaseImpl struct {
-	teacherRepo repository.TeacherRepository
+	teacherRepo         repository.TeacherRepository
+	tokenService        TokenService
+	verificationService VerificationService
+	loginGuard          LoginGuard
+	mfaUsecase          MFAUsecase
 }
 
 type TeacherUsecase interface {
-	LoginTeacher(c context.Context, request *dto.TeacherLoginRequest) (interface{}, error2.CustomError)
+	LoginTeacher(c context.Context, request *dto.TeacherLoginRequest, client dto.ClientInfo) (interface{}, error2.CustomError)
 	RegisterTeacher(c context.Context, request *dto.TeacherRegisterRequest) (interface{}, error2.CustomError)
+	VerifyTeacher(c context.Context, request *dto.VerifyEmailRequest, client dto.ClientInfo) (interface{}, error2.CustomError)
+	ResendVerificationTeacher(c context.Context, request *dto.ResendVerificationRequest) error2.CustomError
 }
 
-func (s *teacherUsecaseImpl) LoginTeacher(c context.Context, request *dto.TeacherLoginRequest) (interface{}, error2.CustomError) {
-	teacherResult, err := s.teacherRepo.GetTeacherByEmail(c, request.Email)
+func (s *teacherUsecaseImpl) LoginTeacher(c context.Context, request *dto.TeacherLoginRequest, client dto.ClientInfo) (interface{}, error2.CustomError) {
+	err := s.loginGuard.CheckLoginAllowed(c, request.Email, utils.TEACHER_ROLE, client.IPAddress)
 	if err.Cause != nil {
 		return nil, err
 	}
 
-	log.Println(request.Email)
+	passwordHash := dummyPasswordHash()
+	teacherResult, err := s.teacherRepo.GetTeacherByEmail(c, request.Email)
+	if err.Cause != nil && err.Code != utils.BAD_REQUEST {
+		return nil, err
+	}
+	if teacherResult != nil {
+		passwordHash = teacherResult.Password
+	}
+
+	err = utils.ValidatePassword(passwordHash, request.Password)
+	if err.Cause != nil || teacherResult == nil {
+		customError := s.loginGuard.RecordLoginFailure(c, request.Email, utils.TEACHER_ROLE, client.IPAddress, utils.LOGIN_FAILURE_INVALID_CREDENTIALS)
+		if customError.Cause != nil {
+			return nil, customError
+		}
+		return nil, invalidCredentialsError()
+	}
+
+	if teacherResult.VerifiedAt == nil {
+		return nil, accountBlockedError(c, s.loginGuard, request.Email, utils.TEACHER_ROLE, client.IPAddress, utils.LOGIN_FAILURE_UNVERIFIED, "email is not verified yet")
+	}
+
+	if teacherResult.SuspendedAt != nil {
+		return nil, accountBlockedError(c, s.loginGuard, request.Email, utils.TEACHER_ROLE, client.IPAddress, utils.LOGIN_FAILURE_SUSPENDED, "account is suspended")
+	}
 
-	err = utils.ValidatePassword(teacherResult.Password, request.Password)
+	if teacherResult.ApprovedAt == nil {
+		return nil, accountBlockedError(c, s.loginGuard, request.Email, utils.TEACHER_ROLE, client.IPAddress, utils.LOGIN_FAILURE_UNAPPROVED, "account is waiting for admin approval")
+	}
+
+	if utils.PasswordNeedsRehash(teacherResult.Password) {
+		s.rehashPassword(c, teacherResult.ID, request.Password)
+	}
+
+	mfaToken, err := s.mfaUsecase.StartChallenge(c, teacherResult.ID, utils.TEACHER_ROLE)
 	if err.Cause != nil {
 		return nil, err
 	}
+	if mfaToken != "" {
+		return mfaChallenge(mfaToken), error2.CustomError{}
+	}
 
-	accessToken, err := utils.CreateAccessToken(teacherResult.ID, utils.TEACHER_ROLE)
+	err = s.loginGuard.RecordLoginSuccess(c, request.Email, utils.TEACHER_ROLE, client.IPAddress)
 	if err.Cause != nil {
 		return nil, err
 	}
 
-	refeshToken, err := utils.CreateRefreshToken(teacherResult.ID, accessToken, utils.TEACHER_ROLE)
+	accessToken, refeshToken, err := s.tokenService.IssueTokens(c, teacherResult.ID, utils.TEACHER_ROLE, uuid.New(), client)
 	if err.Cause != nil {
 		return nil, err
 	}
@@ -47,6 +90,21 @@ func (s *teacherUsecaseImpl) LoginTeacher(c context.Context, request *dto.Teache
 	}, error2.CustomError{}
 }
 
+// rehashPassword upgrades a stored hash made with outdated settings. A failure
+// here must not block the login, so it is only logged.
+func (s *teacherUsecaseImpl) rehashPassword(c context.Context, id uuid.UUID, password string) {
+	passwordHash, err := utils.GeneratePassword(password)
+	if err != nil {
+		log.Printf("failed to rehash password for teacher %s: %s", id, err)
+		return
+	}
+
+	customError := s.teacherRepo.UpdateTeacherPassword(c, id, passwordHash)
+	if customError.Cause != nil {
+		log.Printf("failed to rehash password for teacher %s: %s", id, customError.Cause)
+	}
+}
+
 func (s *teacherUsecaseImpl) RegisterTeacher(c context.Context, request *dto.TeacherRegisterRequest) (interface{}, error2.CustomError) {
 	teacherRequest, err := request.NewTeacher()
 	if err.Cause != nil {
@@ -58,12 +116,59 @@ func (s *teacherUsecaseImpl) RegisterTeacher(c context.Context, request *dto.Tea
 		return nil, err
 	}
 
-	accessToken, err := utils.CreateAccessToken(teacherRequest.ID, utils.TEACHER_ROLE)
+	err = s.verificationService.SendVerificationCode(c, teacherRequest.ID, utils.TEACHER_ROLE, teacherRequest.Email)
+	if err.Cause != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"email":    teacherRequest.Email,
+		"verified": false,
+		"approved": false,
+	}, err
+}
+
+func (s *teacherUsecaseImpl) VerifyTeacher(c context.Context, request *dto.VerifyEmailRequest, client dto.ClientInfo) (interface{}, error2.CustomError) {
+	if request.Email == "" || request.Code == "" {
+		return nil, error2.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("email or code can't be blank"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	teacherResult, err := s.teacherRepo.GetTeacherByEmail(c, request.Email)
+	if err.Cause != nil {
+		return nil, err
+	}
+
+	if teacherResult.VerifiedAt != nil {
+		return nil, error2.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("email already verified"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	err = s.verificationService.CheckVerificationCode(c, teacherResult.ID, utils.TEACHER_ROLE, request.Code)
 	if err.Cause != nil {
 		return nil, err
 	}
 
-	refeshToken, err := utils.CreateRefreshToken(teacherRequest.ID, accessToken, utils.TEACHER_ROLE)
+	err = s.teacherRepo.VerifyTeacher(c, teacherResult.ID)
+	if err.Cause != nil {
+		return nil, err
+	}
+
+	if teacherResult.ApprovedAt == nil || teacherResult.SuspendedAt != nil {
+		return map[string]interface{}{
+			"email":    teacherResult.Email,
+			"verified": true,
+			"approved": false,
+		}, error2.CustomError{}
+	}
+
+	accessToken, refeshToken, err := s.tokenService.IssueTokens(c, teacherResult.ID, utils.TEACHER_ROLE, uuid.New(), client)
 	if err.Cause != nil {
 		return nil, err
 	}
@@ -71,11 +176,32 @@ func (s *teacherUsecaseImpl) RegisterTeacher(c context.Context, request *dto.Tea
 	return map[string]interface{}{
 		"access token":  accessToken,
 		"refresh token": refeshToken,
-	}, err
+	}, error2.CustomError{}
+}
+
+func (s *teacherUsecaseImpl) ResendVerificationTeacher(c context.Context, request *dto.ResendVerificationRequest) error2.CustomError {
+	teacherResult, err := s.teacherRepo.GetTeacherByEmail(c, request.Email)
+	if err.Cause != nil {
+		return err
+	}
+
+	if teacherResult.VerifiedAt != nil {
+		return error2.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("email already verified"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	return s.verificationService.SendVerificationCode(c, teacherResult.ID, utils.TEACHER_ROLE, teacherResult.Email)
 }
 
-func NewTeacherUsecase(repo repository.TeacherRepository) TeacherUsecase {
+func NewTeacherUsecase(repo repository.TeacherRepository, tokenService TokenService, verificationService VerificationService, loginGuard LoginGuard, mfaUsecase MFAUsecase) TeacherUsecase {
 	return &teacherUsecaseImpl{
-		teacherRepo: repo,
+		teacherRepo:         repo,
+		tokenService:        tokenService,
+		verificationService: verificationService,
+		loginGuard:          loginGuard,
+		mfaUsecase:          mfaUsecase,
 	}
 }
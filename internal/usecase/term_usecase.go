@@ -0,0 +1,140 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"github.com/rifkhia/lms-remake/internal/authz"
+	"github.com/rifkhia/lms-remake/internal/dto"
+	"github.com/rifkhia/lms-remake/internal/models"
+	"github.com/rifkhia/lms-remake/internal/pkg"
+	"github.com/rifkhia/lms-remake/internal/repository"
+	"github.com/rifkhia/lms-remake/internal/utils"
+	"strconv"
+)
+
+// TermUsecase manages academic terms. Everyone may read them, only admins
+// change them.
+type TermUsecase interface {
+	FetchTerms(c context.Context, subject authz.Subject) ([]*models.Term, pkg.CustomError)
+	FetchCurrentTerm(c context.Context, subject authz.Subject) (*models.Term, pkg.CustomError)
+	CreateTerm(c context.Context, subject authz.Subject, request *dto.TermRequest) (*models.Term, pkg.CustomError)
+	UpdateTerm(c context.Context, subject authz.Subject, id int, request *dto.TermRequest) (*models.Term, pkg.CustomError)
+	DeleteTerm(c context.Context, subject authz.Subject, id int) pkg.CustomError
+}
+
+type termUsecaseImpl struct {
+	termRepo repository.TermRepository
+}
+
+func (s *termUsecaseImpl) FetchTerms(c context.Context, subject authz.Subject) ([]*models.Term, pkg.CustomError) {
+	customError := authz.Authorize(subject, authz.READ, authz.Resource{Type: authz.TERM})
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	return s.termRepo.GetTerms(c)
+}
+
+func (s *termUsecaseImpl) FetchCurrentTerm(c context.Context, subject authz.Subject) (*models.Term, pkg.CustomError) {
+	customError := authz.Authorize(subject, authz.READ, authz.Resource{Type: authz.TERM})
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	term, customError := s.termRepo.GetCurrentTerm(c)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	if term == nil {
+		return nil, pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("there is no current or upcoming term"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	return term, pkg.CustomError{}
+}
+
+func (s *termUsecaseImpl) CreateTerm(c context.Context, subject authz.Subject, request *dto.TermRequest) (*models.Term, pkg.CustomError) {
+	customError := authz.Authorize(subject, authz.CREATE, authz.Resource{Type: authz.TERM})
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	term, customError := request.NewTerm()
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	customError = s.termRepo.CreateTerm(c, term)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	return term, pkg.CustomError{}
+}
+
+func (s *termUsecaseImpl) UpdateTerm(c context.Context, subject authz.Subject, id int, request *dto.TermRequest) (*models.Term, pkg.CustomError) {
+	customError := authz.Authorize(subject, authz.UPDATE, authz.Resource{Type: authz.TERM})
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	term, customError := request.NewTerm()
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	term.ID = id
+	customError = s.termRepo.UpdateTerm(c, term)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	return term, pkg.CustomError{}
+}
+
+func (s *termUsecaseImpl) DeleteTerm(c context.Context, subject authz.Subject, id int) pkg.CustomError {
+	customError := authz.Authorize(subject, authz.DELETE, authz.Resource{Type: authz.TERM})
+	if customError.Cause != nil {
+		return customError
+	}
+
+	return s.termRepo.DeleteTerm(c, id)
+}
+
+// resolveTermFilter turns the term query parameter of a listing into the term
+// to filter on: "" or "current" for the current term, "all" for no filter, or
+// a term id. A nil result means no filter, which is also what "current" gives
+// when there is no current term.
+func resolveTermFilter(c context.Context, termRepo repository.TermRepository, term string) (*int, pkg.CustomError) {
+	switch term {
+	case "", "current":
+		current, customError := termRepo.GetCurrentTerm(c)
+		if customError.Cause != nil || current == nil {
+			return nil, customError
+		}
+		return &current.ID, pkg.CustomError{}
+	case "all":
+		return nil, pkg.CustomError{}
+	}
+
+	termId, err := strconv.Atoi(term)
+	if err != nil {
+		return nil, pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("term must be current, all or a term id"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	return &termId, pkg.CustomError{}
+}
+
+func NewTermUsecase(termRepo repository.TermRepository) TermUsecase {
+	return &termUsecaseImpl{
+		termRepo: termRepo,
+	}
+}
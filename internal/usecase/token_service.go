@@ -0,0 +1,247 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/rifkhia/lms-remake/internal/dto"
+	"github.com/rifkhia/lms-remake/internal/models"
+	"github.com/rifkhia/lms-remake/internal/pkg"
+	"github.com/rifkhia/lms-remake/internal/repository"
+	"github.com/rifkhia/lms-remake/internal/utils"
+	"github.com/spf13/viper"
+	"time"
+)
+
+// TokenService is the only place tokens are minted, verified and revoked.
+type TokenService interface {
+	IssueTokens(c context.Context, userId uuid.UUID, role string, familyId uuid.UUID, client dto.ClientInfo) (string, string, pkg.CustomError)
+	VerifyAccessToken(c context.Context, accessToken string) (*utils.JwtCustomClaims, pkg.CustomError)
+	VerifyRefreshToken(c context.Context, refreshToken string) (*utils.JwtCustomRefreshClaims, pkg.CustomError)
+	RevokeAccessToken(c context.Context, tokenId string, expiresAt time.Time) pkg.CustomError
+	RevokeRefreshTokenFamily(c context.Context, familyId uuid.UUID) pkg.CustomError
+	RevokeAllUserTokens(c context.Context, userId uuid.UUID, role string) pkg.CustomError
+	RevokeUserTokensExcept(c context.Context, userId uuid.UUID, role string, keepSessionId uuid.UUID) pkg.CustomError
+	IssueAPIToken(c context.Context, token *models.APIToken) (string, pkg.CustomError)
+	IssueMFAToken(userId uuid.UUID, role string) (string, pkg.CustomError)
+	VerifyMFAToken(c context.Context, mfaToken string) (*utils.JwtCustomClaims, pkg.CustomError)
+	VerifyAPIToken(c context.Context, apiToken string) (*models.APIToken, pkg.CustomError)
+}
+
+type tokenServiceImpl struct {
+	authRepo       repository.AuthRepository
+	revocationRepo repository.RevocationRepository
+	apiTokenRepo   repository.APITokenRepository
+	sessionRepo    repository.SessionRepository
+}
+
+// sessionRevocationKey is what a revoked session is recorded under in the
+// revocation list, next to the ids of single revoked tokens.
+func sessionRevocationKey(sessionId uuid.UUID) string {
+	return "session:" + sessionId.String()
+}
+
+// IssueTokens creates an access/refresh pair and persists the refresh token
+// under the given family so it can be rotated later. The family is the session
+// the user sees, so client is recorded with it as its latest activity.
+func (s *tokenServiceImpl) IssueTokens(c context.Context, userId uuid.UUID, role string, familyId uuid.UUID, client dto.ClientInfo) (string, string, pkg.CustomError) {
+	accessToken, customError := utils.CreateAccessToken(userId, role, familyId)
+	if customError.Cause != nil {
+		return "", "", customError
+	}
+
+	tokenId := uuid.New()
+	refreshToken, expiresAt, customError := utils.CreateRefreshToken(userId, accessToken, role, tokenId, familyId)
+	if customError.Cause != nil {
+		return "", "", customError
+	}
+
+	userAgent := client.UserAgent
+	if len(userAgent) > utils.SESSION_USER_AGENT_MAX_LENGTH {
+		userAgent = userAgent[:utils.SESSION_USER_AGENT_MAX_LENGTH]
+	}
+
+	customError = s.sessionRepo.SaveSession(c, &models.Session{
+		ID:        familyId,
+		UserID:    userId,
+		Role:      role,
+		UserAgent: userAgent,
+		IPAddress: client.IPAddress,
+		ExpiresAt: expiresAt,
+	})
+	if customError.Cause != nil {
+		return "", "", customError
+	}
+
+	customError = s.authRepo.CreateRefreshToken(c, &models.RefreshToken{
+		ID:        tokenId,
+		FamilyID:  familyId,
+		UserID:    userId,
+		Role:      role,
+		ExpiresAt: expiresAt,
+	})
+	if customError.Cause != nil {
+		return "", "", customError
+	}
+
+	return accessToken, refreshToken, pkg.CustomError{}
+}
+
+func (s *tokenServiceImpl) VerifyAccessToken(c context.Context, accessToken string) (*utils.JwtCustomClaims, pkg.CustomError) {
+	claims, customError := utils.ParseAccessToken(accessToken)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	revoked, customError := s.revocationRepo.IsTokenRevoked(c, claims.RegisteredClaims.ID)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	if !revoked && claims.SessionID != uuid.Nil {
+		revoked, customError = s.revocationRepo.IsTokenRevoked(c, sessionRevocationKey(claims.SessionID))
+		if customError.Cause != nil {
+			return nil, customError
+		}
+	}
+
+	if !revoked && claims.IssuedAt != nil {
+		revoked, customError = s.revocationRepo.IsUserTokenRevoked(c, claims.UserID, claims.Role, claims.SessionID, claims.IssuedAt.Time)
+		if customError.Cause != nil {
+			return nil, customError
+		}
+	}
+
+	if revoked {
+		return nil, pkg.CustomError{
+			Code:    utils.UNAUTHORIZED,
+			Cause:   errors.New("token has been revoked"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	return claims, pkg.CustomError{}
+}
+
+func (s *tokenServiceImpl) VerifyRefreshToken(c context.Context, refreshToken string) (*utils.JwtCustomRefreshClaims, pkg.CustomError) {
+	return utils.ParseRefreshToken(refreshToken)
+}
+
+func (s *tokenServiceImpl) RevokeAccessToken(c context.Context, tokenId string, expiresAt time.Time) pkg.CustomError {
+	return s.revocationRepo.RevokeToken(c, tokenId, expiresAt)
+}
+
+// RevokeRefreshTokenFamily ends the session of the family. Its access tokens
+// stop being accepted too, so they are listed as revoked until the last one
+// issued has expired.
+func (s *tokenServiceImpl) RevokeRefreshTokenFamily(c context.Context, familyId uuid.UUID) pkg.CustomError {
+	customError := s.authRepo.RevokeRefreshTokenFamily(c, familyId)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	customError = s.sessionRepo.RevokeSession(c, familyId)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	expiresAt := time.Now().Add(time.Hour * time.Duration(viper.GetInt("EXPIRY")))
+	return s.revocationRepo.RevokeToken(c, sessionRevocationKey(familyId), expiresAt)
+}
+
+// RevokeAllUserTokens signs the user out everywhere: every refresh token family
+// and API token is revoked and every access token issued until now stops being
+// accepted.
+func (s *tokenServiceImpl) RevokeAllUserTokens(c context.Context, userId uuid.UUID, role string) pkg.CustomError {
+	return s.RevokeUserTokensExcept(c, userId, role, uuid.Nil)
+}
+
+// RevokeUserTokensExcept is RevokeAllUserTokens for a caller about to issue
+// keepSessionId as a new session. The revoke covers the whole current second,
+// so without the exemption the new session's tokens would be revoked as well.
+func (s *tokenServiceImpl) RevokeUserTokensExcept(c context.Context, userId uuid.UUID, role string, keepSessionId uuid.UUID) pkg.CustomError {
+	customError := s.authRepo.RevokeUserRefreshTokens(c, userId, role)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	customError = s.apiTokenRepo.RevokeUserAPITokens(c, userId, role)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	customError = s.sessionRepo.RevokeUserSessions(c, userId, role)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	return s.revocationRepo.RevokeUserTokens(c, userId, role, time.Now(), keepSessionId)
+}
+
+// IssueAPIToken fills in the secret parts of token, stores it and returns the
+// plain token, which is never available again afterwards.
+func (s *tokenServiceImpl) IssueAPIToken(c context.Context, token *models.APIToken) (string, pkg.CustomError) {
+	secret, err := utils.GenerateToken(32)
+	if err != nil {
+		return "", pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	plainToken := utils.API_TOKEN_PREFIX + secret
+	token.ID = uuid.New()
+	token.Prefix = plainToken[:len(utils.API_TOKEN_PREFIX)+4]
+	token.TokenHash = utils.HashToken(plainToken)
+	token.CreatedAt = time.Now()
+
+	customError := s.apiTokenRepo.CreateAPIToken(c, token)
+	if customError.Cause != nil {
+		return "", customError
+	}
+
+	return plainToken, pkg.CustomError{}
+}
+
+func (s *tokenServiceImpl) VerifyAPIToken(c context.Context, apiToken string) (*models.APIToken, pkg.CustomError) {
+	return s.apiTokenRepo.UseAPIToken(c, utils.HashToken(apiToken))
+}
+
+func (s *tokenServiceImpl) IssueMFAToken(userId uuid.UUID, role string) (string, pkg.CustomError) {
+	return utils.CreateMFAToken(userId, role)
+}
+
+// VerifyMFAToken accepts an MFA challenge that was not used yet. The caller
+// revokes it once the code is accepted, so a mistyped code can be retried with
+// the same challenge but a challenge finishes at most one login.
+func (s *tokenServiceImpl) VerifyMFAToken(c context.Context, mfaToken string) (*utils.JwtCustomClaims, pkg.CustomError) {
+	claims, customError := utils.ParseMFAToken(mfaToken)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	revoked, customError := s.revocationRepo.IsTokenRevoked(c, claims.RegisteredClaims.ID)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	if revoked {
+		return nil, pkg.CustomError{
+			Code:    utils.UNAUTHORIZED,
+			Cause:   errors.New("mfa token was already used"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	return claims, pkg.CustomError{}
+}
+
+func NewTokenService(authRepo repository.AuthRepository, revocationRepo repository.RevocationRepository, apiTokenRepo repository.APITokenRepository, sessionRepo repository.SessionRepository) TokenService {
+	return &tokenServiceImpl{
+		authRepo:       authRepo,
+		revocationRepo: revocationRepo,
+		apiTokenRepo:   apiTokenRepo,
+		sessionRepo:    sessionRepo,
+	}
+}
@@ -0,0 +1,178 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/rifkhia/lms-remake/internal/mailer"
+	"github.com/rifkhia/lms-remake/internal/models"
+	"github.com/rifkhia/lms-remake/internal/pkg"
+	"github.com/rifkhia/lms-remake/internal/repository"
+	"github.com/rifkhia/lms-remake/internal/utils"
+	"github.com/spf13/viper"
+	"time"
+)
+
+// VerificationService sends and checks the one-time codes that move a newly
+// registered student or teacher out of the pending state, and that confirm a
+// new email address before it replaces the old one.
+type VerificationService interface {
+	SendVerificationCode(c context.Context, userId uuid.UUID, role string, email string) pkg.CustomError
+	CheckVerificationCode(c context.Context, userId uuid.UUID, role string, code string) pkg.CustomError
+	SendEmailChangeCode(c context.Context, userId uuid.UUID, role string, newEmail string) pkg.CustomError
+	CheckEmailChangeCode(c context.Context, userId uuid.UUID, role string, code string) (string, pkg.CustomError)
+}
+
+type verificationServiceImpl struct {
+	verificationRepo repository.VerificationRepository
+	mailer           mailer.Mailer
+}
+
+func (s *verificationServiceImpl) SendVerificationCode(c context.Context, userId uuid.UUID, role string, email string) pkg.CustomError {
+	return s.sendCode(c, userId, role, email, nil, "Verify your email", "If you did not register, you can ignore this email.")
+}
+
+func (s *verificationServiceImpl) CheckVerificationCode(c context.Context, userId uuid.UUID, role string, code string) pkg.CustomError {
+	_, customError := s.checkCode(c, userId, role, code, false)
+	return customError
+}
+
+// SendEmailChangeCode sends the code to the new address, which proves the user
+// can read mail there.
+func (s *verificationServiceImpl) SendEmailChangeCode(c context.Context, userId uuid.UUID, role string, newEmail string) pkg.CustomError {
+	return s.sendCode(c, userId, role, newEmail, &newEmail, "Confirm your new email", "If you did not ask to change your email, you can ignore this email.")
+}
+
+// CheckEmailChangeCode returns the new address the code confirms.
+func (s *verificationServiceImpl) CheckEmailChangeCode(c context.Context, userId uuid.UUID, role string, code string) (string, pkg.CustomError) {
+	verificationCode, customError := s.checkCode(c, userId, role, code, true)
+	if customError.Cause != nil {
+		return "", customError
+	}
+
+	return *verificationCode.NewEmail, pkg.CustomError{}
+}
+
+func (s *verificationServiceImpl) sendCode(c context.Context, userId uuid.UUID, role string, email string, newEmail *string, subject string, notice string) pkg.CustomError {
+	latestCode, customError := s.verificationRepo.GetLatestVerificationCode(c, userId, role)
+	if customError.Cause != nil && customError.Code != utils.BAD_REQUEST {
+		return customError
+	}
+
+	if latestCode != nil && time.Since(latestCode.CreatedAt) < utils.OTP_RESEND_COOLDOWN_SECONDS*time.Second {
+		return pkg.CustomError{
+			Code:    utils.TOO_MANY_REQUESTS,
+			Cause:   errors.New("please wait before requesting another verification code"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	customError = s.verificationRepo.ConsumeVerificationCodes(c, userId, role)
+	if customError.Cause != nil {
+		return customError
+	}
+
+	otp, err := utils.GenerateOTP(utils.OTP_LENGTH)
+	if err != nil {
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	otpHash, err := utils.GeneratePassword(otp)
+	if err != nil {
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	expiry := time.Duration(viper.GetInt("OTP_EXPIRY")) * time.Minute
+	customError = s.verificationRepo.CreateVerificationCode(c, &models.VerificationCode{
+		UserID:    userId,
+		Role:      role,
+		CodeHash:  otpHash,
+		NewEmail:  newEmail,
+		ExpiresAt: time.Now().Add(expiry),
+	})
+	if customError.Cause != nil {
+		return customError
+	}
+
+	body := fmt.Sprintf("Your verification code is %s\n\nThe code expires in %d minutes. %s", otp, int(expiry.Minutes()), notice)
+	err = s.mailer.Send(email, subject, body)
+	if err != nil {
+		return pkg.CustomError{
+			Code:    utils.INTERNAL_SERVER_ERROR,
+			Cause:   err,
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	return pkg.CustomError{}
+}
+
+// checkCode only accepts a code sent for the same purpose, so a registration
+// code can't confirm an email change or the other way around.
+func (s *verificationServiceImpl) checkCode(c context.Context, userId uuid.UUID, role string, code string, emailChange bool) (*models.VerificationCode, pkg.CustomError) {
+	latestCode, customError := s.verificationRepo.GetLatestVerificationCode(c, userId, role)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	if (latestCode.NewEmail != nil) != emailChange {
+		return nil, pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("no verification code found, please request a new one"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	if latestCode.ExpiresAt.Before(time.Now()) {
+		return nil, pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("verification code expired, please request a new one"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	allowed, customError := s.verificationRepo.UseVerificationAttempt(c, latestCode.ID)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	if !allowed {
+		return nil, pkg.CustomError{
+			Code:    utils.TOO_MANY_REQUESTS,
+			Cause:   errors.New("too many attempts, please request a new verification code"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	customError = utils.ValidatePassword(latestCode.CodeHash, code)
+	if customError.Cause != nil {
+		return nil, pkg.CustomError{
+			Code:    utils.BAD_REQUEST,
+			Cause:   errors.New("invalid verification code"),
+			Service: utils.USECASE_SERVICE,
+		}
+	}
+
+	customError = s.verificationRepo.ConsumeVerificationCodes(c, userId, role)
+	if customError.Cause != nil {
+		return nil, customError
+	}
+
+	return latestCode, pkg.CustomError{}
+}
+
+func NewVerificationService(verificationRepo repository.VerificationRepository, mailer mailer.Mailer) VerificationService {
+	return &verificationServiceImpl{
+		verificationRepo: verificationRepo,
+		mailer:           mailer,
+	}
+}
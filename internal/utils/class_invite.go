@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/spf13/viper"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidInviteLink = errors.New("invalid invite link")
+
+// SignClassInvite turns an invite code into the token of an invite link. The
+// token carries the class id, so the link alone is enough to join, and is
+// signed with CLASS_INVITE_SECRET so the class id can't be swapped.
+func SignClassInvite(classId int, code string) (string, error) {
+	payload := fmt.Sprintf("%d.%s", classId, code)
+
+	signature, err := classInviteSignature(payload)
+	if err != nil {
+		return "", err
+	}
+
+	return payload + "." + signature, nil
+}
+
+// ParseClassInvite checks the signature of an invite link token and returns
+// the class id and invite code in it.
+func ParseClassInvite(token string) (int, string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return 0, "", ErrInvalidInviteLink
+	}
+
+	expected, err := classInviteSignature(parts[0] + "." + parts[1])
+	if err != nil {
+		return 0, "", err
+	}
+
+	if !hmac.Equal([]byte(expected), []byte(parts[2])) {
+		return 0, "", ErrInvalidInviteLink
+	}
+
+	classId, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, "", ErrInvalidInviteLink
+	}
+
+	return classId, parts[1], nil
+}
+
+// CLASS_INVITE_SECRET_MIN_LENGTH keeps the secret from being guessable.
+const CLASS_INVITE_SECRET_MIN_LENGTH = 32
+
+// CheckClassInviteSecret is run at startup, so a missing secret stops the
+// server instead of failing every invite link.
+func CheckClassInviteSecret() error {
+	_, err := classInviteSignature("")
+	return err
+}
+
+func classInviteSignature(payload string) (string, error) {
+	secret := viper.GetString("CLASS_INVITE_SECRET")
+	if secret == "" {
+		return "", errors.New("CLASS_INVITE_SECRET is not set")
+	}
+
+	if len(secret) < CLASS_INVITE_SECRET_MIN_LENGTH {
+		return "", fmt.Errorf("CLASS_INVITE_SECRET must be at least %d characters", CLASS_INVITE_SECRET_MIN_LENGTH)
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
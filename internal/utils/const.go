@@ -2,13 +2,73 @@ package utils
 
 const STUDENT_ROLE = "STUDENT"
 const TEACHER_ROLE = "TEACHER"
+const ADMIN_ROLE = "ADMIN"
 const LETTER_RUNES = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ1234567890"
 
+// EMAIL VERIFICATION
+const OTP_LENGTH = 6
+const OTP_MAX_ATTEMPTS = 5
+const OTP_RESEND_COOLDOWN_SECONDS = 60
+
+// LOGIN THROTTLING
+const LOGIN_MAX_ACCOUNT_FAILURES = 5
+const LOGIN_MAX_IP_FAILURES = 20
+const LOGIN_FAILURE_WINDOW_MINUTES = 15
+const LOGIN_LOCKOUT_BASE_SECONDS = 30
+const LOGIN_LOCKOUT_MAX_MINUTES = 15
+const LOGIN_FAILURE_INVALID_CREDENTIALS = "invalid_credentials"
+const LOGIN_FAILURE_LOCKED = "locked"
+const LOGIN_FAILURE_UNVERIFIED = "unverified"
+const LOGIN_FAILURE_UNAPPROVED = "unapproved"
+const LOGIN_FAILURE_SUSPENDED = "suspended"
+const LOGIN_FAILURE_INVALID_MFA_CODE = "invalid_mfa_code"
+
+// TWO-FACTOR AUTHENTICATION
+const MFA_RECOVERY_CODES = 10
+
+// SESSIONS
+const SESSION_USER_AGENT_MAX_LENGTH = 512
+
+// API TOKENS
+const API_TOKEN_PREFIX = "lms_pat_"
+const API_TOKEN_MAX_PER_USER = 50
+
+// CLASS STAFF ROLES
+const CLASS_STAFF_OWNER = "owner"
+const CLASS_STAFF_CO_TEACHER = "co_teacher"
+const CLASS_STAFF_ASSISTANT = "assistant"
+
+// CLASS KEYS AND INVITES
+const CLASS_KEY_LENGTH = 8
+const CLASS_INVITE_CODE_LENGTH = 10
+
+// CLASS ENROLLMENT MODES
+const ENROLLMENT_MODE_OPEN = "open"
+const ENROLLMENT_MODE_APPROVAL = "approval"
+const ENROLLMENT_MODE_INVITE_ONLY = "invite_only"
+
+// ENROLLMENT STATUS
+const ENROLLMENT_ENROLLED = "enrolled"
+const ENROLLMENT_PENDING = "pending"
+const ENROLLMENT_WAITLISTED = "waitlisted"
+const ENROLLMENT_APPROVED = "approved"
+const ENROLLMENT_REJECTED = "rejected"
+const ENROLLMENT_CANCELLED = "cancelled"
+
+// ACCOUNT STATUS FILTERS
+const ACCOUNT_STATUS_PENDING = "pending"
+const ACCOUNT_STATUS_ACTIVE = "active"
+const ACCOUNT_STATUS_SUSPENDED = "suspended"
+const ACCOUNT_STATUS_DELETED = "deleted"
+
 // LIST CODE FOR ERROR
 const INTERNAL_SERVER_ERROR = 500
 const BAD_REQUEST = 400
+const UNAUTHORIZED = 401
 const FORBIDDEN = 403
+const CONFLICT = 409
 const UNPROCESSABLE_ENTITY = 422
+const TOO_MANY_REQUESTS = 429
 
 // LIST SERVICE
 const REPOSITORY_SERVICE = "repository"
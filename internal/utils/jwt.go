@@ -9,31 +9,56 @@ import (
 	"time"
 )
 
+// token types carried in the typ claim so a refresh token can never be
+// presented as an access token or the other way around
+const ACCESS_TOKEN = "access"
+const REFRESH_TOKEN = "refresh"
+
+// MFA_TOKEN is the short-lived challenge given after a correct password when
+// the account has two-factor login on. It only unlocks the second step.
+const MFA_TOKEN = "mfa"
+const MFA_TOKEN_EXPIRY_MINUTES = 5
+
 type JwtCustomClaims struct {
-	ID   uuid.UUID
-	Role string
+	UserID uuid.UUID `json:"id"`
+	Role   string    `json:"role"`
+	Type   string    `json:"typ"`
+	// SessionID is the refresh token family the access token was issued with.
+	SessionID uuid.UUID `json:"sid"`
 	jwt.RegisteredClaims
 }
 
 type JwtCustomRefreshClaims struct {
-	ID          uuid.UUID
-	Role        string
-	AccessToken string
+	UserID      uuid.UUID `json:"id"`
+	Role        string    `json:"role"`
+	Type        string    `json:"typ"`
+	AccessToken string    `json:"access_token"`
+	FamilyID    uuid.UUID `json:"family_id"`
 	jwt.RegisteredClaims
 }
 
-func CreateAccessToken(user_id uuid.UUID, role string) (accessToken string, custErr error2.CustomError) {
+func newRegisteredClaims(tokenId string, expiresAt time.Time) jwt.RegisteredClaims {
+	now := time.Now()
+	return jwt.RegisteredClaims{
+		ID:        tokenId,
+		Issuer:    viper.GetString("JWT_ISSUER"),
+		Audience:  jwt.ClaimStrings{viper.GetString("JWT_AUDIENCE")},
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+		NotBefore: jwt.NewNumericDate(now),
+		IssuedAt:  jwt.NewNumericDate(now),
+	}
+}
+
+func CreateAccessToken(user_id uuid.UUID, role string, sessionId uuid.UUID) (accessToken string, custErr error2.CustomError) {
 	claims := &JwtCustomClaims{
-		ID:   user_id,
-		Role: role,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * time.Duration(viper.GetInt("EXPIRY")))),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
+		UserID:           user_id,
+		Role:             role,
+		Type:             ACCESS_TOKEN,
+		SessionID:        sessionId,
+		RegisteredClaims: newRegisteredClaims(uuid.New().String(), time.Now().Add(time.Hour*time.Duration(viper.GetInt("EXPIRY")))),
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	t, err := token.SignedString([]byte(viper.GetString("SECRET_JWT")))
+	t, err := signToken(claims)
 	if err != nil {
 		custErr = error2.CustomError{
 			Cause:   err,
@@ -45,17 +70,38 @@ func CreateAccessToken(user_id uuid.UUID, role string) (accessToken string, cust
 	return t, custErr
 }
 
-func CreateRefreshToken(user_id uuid.UUID, accessToken string, role string) (refreshToken string, custErr error2.CustomError) {
+func CreateRefreshToken(user_id uuid.UUID, accessToken string, role string, tokenId uuid.UUID, familyId uuid.UUID) (refreshToken string, expiresAt time.Time, custErr error2.CustomError) {
+	expiresAt = time.Now().Add(time.Hour * time.Duration(viper.GetInt("REFRESH_EXPIRY")))
 	claimsRefresh := &JwtCustomRefreshClaims{
-		ID:          user_id,
-		Role:        role,
-		AccessToken: accessToken,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * time.Duration(viper.GetInt("EXPIRY")))),
-		},
-	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claimsRefresh)
-	rt, err := token.SignedString([]byte(viper.GetString("SECRET_JWT")))
+		UserID:           user_id,
+		Role:             role,
+		Type:             REFRESH_TOKEN,
+		AccessToken:      accessToken,
+		FamilyID:         familyId,
+		RegisteredClaims: newRegisteredClaims(tokenId.String(), expiresAt),
+	}
+
+	rt, err := signToken(claimsRefresh)
+	if err != nil {
+		custErr = error2.CustomError{
+			Cause:   err,
+			Service: "Utils",
+			Code:    INTERNAL_SERVER_ERROR,
+		}
+		return "", expiresAt, custErr
+	}
+	return rt, expiresAt, custErr
+}
+
+func CreateMFAToken(user_id uuid.UUID, role string) (mfaToken string, custErr error2.CustomError) {
+	claims := &JwtCustomClaims{
+		UserID:           user_id,
+		Role:             role,
+		Type:             MFA_TOKEN,
+		RegisteredClaims: newRegisteredClaims(uuid.New().String(), time.Now().Add(time.Minute*MFA_TOKEN_EXPIRY_MINUTES)),
+	}
+
+	t, err := signToken(claims)
 	if err != nil {
 		custErr = error2.CustomError{
 			Cause:   err,
@@ -64,39 +110,102 @@ func CreateRefreshToken(user_id uuid.UUID, accessToken string, role string) (ref
 		}
 		return "", custErr
 	}
-	return rt, custErr
+	return t, custErr
 }
 
-func IsAuthorized(requestToken string, secret string) (bool, error) {
-	_, err := jwt.Parse(requestToken, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+func ParseMFAToken(mfaToken string) (*JwtCustomClaims, error2.CustomError) {
+	claims := new(JwtCustomClaims)
+	err := parseToken(mfaToken, claims, &claims.RegisteredClaims)
+	if err == nil && (claims.Type != MFA_TOKEN || claims.UserID == uuid.Nil) {
+		err = fmt.Errorf("Invalid mfa token")
+	}
+	if err != nil {
+		return nil, error2.CustomError{
+			Cause:   err,
+			Service: "Utils",
+			Code:    UNAUTHORIZED,
 		}
-		return []byte(secret), nil
-	})
+	}
+
+	return claims, error2.CustomError{}
+}
+
+func ParseAccessToken(accessToken string) (*JwtCustomClaims, error2.CustomError) {
+	claims := new(JwtCustomClaims)
+	err := parseToken(accessToken, claims, &claims.RegisteredClaims)
+	if err == nil && (claims.Type != ACCESS_TOKEN || claims.UserID == uuid.Nil) {
+		err = fmt.Errorf("Invalid access token")
+	}
 	if err != nil {
-		return false, err
+		return nil, error2.CustomError{
+			Cause:   err,
+			Service: "Utils",
+			Code:    UNAUTHORIZED,
+		}
 	}
-	return true, nil
+
+	return claims, error2.CustomError{}
 }
 
-func ExtractIDFromToken(requestToken string, secret string) (string, error) {
-	token, err := jwt.Parse(requestToken, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+func ParseRefreshToken(refreshToken string) (*JwtCustomRefreshClaims, error2.CustomError) {
+	claims := new(JwtCustomRefreshClaims)
+	err := parseToken(refreshToken, claims, &claims.RegisteredClaims)
+	if err == nil && (claims.Type != REFRESH_TOKEN || claims.UserID == uuid.Nil || claims.FamilyID == uuid.Nil) {
+		err = fmt.Errorf("Invalid refresh token")
+	}
+	if err != nil {
+		return nil, error2.CustomError{
+			Cause:   err,
+			Service: "Utils",
+			Code:    UNAUTHORIZED,
 		}
-		return []byte(secret), nil
-	})
+	}
 
+	return claims, error2.CustomError{}
+}
+
+// parseToken verifies the signature with the key ring and then checks the
+// registered claims itself, because jwt/v4 has no notion of clock-skew leeway.
+func parseToken(tokenString string, claims jwt.Claims, registered *jwt.RegisteredClaims) error {
+	parser := jwt.NewParser(jwt.WithValidMethods(validMethods()), jwt.WithoutClaimsValidation())
+	token, err := parser.ParseWithClaims(tokenString, claims, verificationKey)
 	if err != nil {
-		return "", err
+		return err
 	}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
+	if !token.Valid {
+		return fmt.Errorf("Invalid token")
+	}
+
+	return validateRegisteredClaims(registered, time.Now())
+}
+
+func validateRegisteredClaims(claims *jwt.RegisteredClaims, now time.Time) error {
+	leeway := time.Duration(viper.GetInt("JWT_LEEWAY")) * time.Second
+
+	if claims.ID == "" {
+		return fmt.Errorf("token has no id")
+	}
+
+	if !claims.VerifyExpiresAt(now.Add(-leeway), true) {
+		return fmt.Errorf("token is expired")
+	}
+
+	if !claims.VerifyNotBefore(now.Add(leeway), false) {
+		return fmt.Errorf("token is not valid yet")
+	}
+
+	if !claims.VerifyIssuedAt(now.Add(leeway), false) {
+		return fmt.Errorf("token used before issued")
+	}
+
+	if !claims.VerifyIssuer(viper.GetString("JWT_ISSUER"), true) {
+		return fmt.Errorf("unexpected token issuer")
+	}
 
-	if !ok && !token.Valid {
-		return "", fmt.Errorf("Invalid Token")
+	if !claims.VerifyAudience(viper.GetString("JWT_AUDIENCE"), true) {
+		return fmt.Errorf("unexpected token audience")
 	}
 
-	return claims["id"].(string), nil
+	return nil
 }
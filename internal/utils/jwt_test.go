@@ -0,0 +1,292 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+)
+
+const testSecret = "test-secret"
+
+func loadHS256KeyRing(t *testing.T) {
+	t.Helper()
+
+	viper.Set("JWT_ALGORITHM", "HS256")
+	viper.Set("SECRET_JWT", testSecret)
+	viper.Set("JWT_SIGNING_KEY_ID", "")
+	viper.Set("JWT_VERIFICATION_KEYS_DIR", "")
+	viper.Set("JWT_ISSUER", "lms-remake")
+	viper.Set("JWT_AUDIENCE", "lms-remake")
+	viper.Set("JWT_LEEWAY", 30)
+	viper.Set("EXPIRY", 2)
+
+	if err := LoadKeyRing(); err != nil {
+		t.Fatalf("loading key ring: %s", err)
+	}
+}
+
+// loadRS256KeyRing signs with a fresh RSA key under kid and returns its PEM
+// encoded public key.
+func loadRS256KeyRing(t *testing.T, kid string) []byte {
+	t.Helper()
+
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyFile := filepath.Join(t.TempDir(), "signing.pem")
+	privatePem := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(private)})
+	if err := os.WriteFile(keyFile, privatePem, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	loadHS256KeyRing(t)
+	viper.Set("JWT_ALGORITHM", "RS256")
+	viper.Set("JWT_SIGNING_KEY_ID", kid)
+	viper.Set("JWT_SIGNING_KEY_FILE", keyFile)
+	t.Cleanup(func() {
+		viper.Set("JWT_SIGNING_KEY_FILE", "")
+	})
+
+	if err := LoadKeyRing(); err != nil {
+		t.Fatalf("loading key ring: %s", err)
+	}
+
+	publicDer, err := x509.MarshalPKIXPublicKey(&private.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDer})
+}
+
+func accessClaims(now time.Time) *JwtCustomClaims {
+	return &JwtCustomClaims{
+		UserID:    uuid.New(),
+		Role:      STUDENT_ROLE,
+		Type:      ACCESS_TOKEN,
+		SessionID: uuid.New(),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Issuer:    "lms-remake",
+			Audience:  jwt.ClaimStrings{"lms-remake"},
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+			NotBefore: jwt.NewNumericDate(now),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, kid string, claims jwt.Claims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("signing token: %s", err)
+	}
+
+	return signed
+}
+
+func TestParseAccessTokenHS256(t *testing.T) {
+	loadHS256KeyRing(t)
+
+	now := time.Now()
+	valid := sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", accessClaims(now))
+
+	withClaims := func(change func(claims *JwtCustomClaims)) string {
+		claims := accessClaims(now)
+		change(claims)
+		return sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", claims)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{"valid", valid, true},
+		{"expired within leeway", withClaims(func(claims *JwtCustomClaims) {
+			claims.ExpiresAt = jwt.NewNumericDate(now.Add(-10 * time.Second))
+		}), true},
+		{"expired", withClaims(func(claims *JwtCustomClaims) {
+			claims.ExpiresAt = jwt.NewNumericDate(now.Add(-time.Minute))
+		}), false},
+		{"without exp", withClaims(func(claims *JwtCustomClaims) {
+			claims.ExpiresAt = nil
+		}), false},
+		{"nbf within leeway", withClaims(func(claims *JwtCustomClaims) {
+			claims.NotBefore = jwt.NewNumericDate(now.Add(10 * time.Second))
+		}), true},
+		{"nbf past leeway", withClaims(func(claims *JwtCustomClaims) {
+			claims.NotBefore = jwt.NewNumericDate(now.Add(time.Minute))
+		}), false},
+		{"iat past leeway", withClaims(func(claims *JwtCustomClaims) {
+			claims.IssuedAt = jwt.NewNumericDate(now.Add(time.Minute))
+		}), false},
+		{"wrong issuer", withClaims(func(claims *JwtCustomClaims) {
+			claims.Issuer = "someone-else"
+		}), false},
+		{"wrong audience", withClaims(func(claims *JwtCustomClaims) {
+			claims.Audience = jwt.ClaimStrings{"someone-else"}
+		}), false},
+		{"without id", withClaims(func(claims *JwtCustomClaims) {
+			claims.ID = ""
+		}), false},
+		{"refresh token type", withClaims(func(claims *JwtCustomClaims) {
+			claims.Type = REFRESH_TOKEN
+		}), false},
+		{"mfa token type", withClaims(func(claims *JwtCustomClaims) {
+			claims.Type = MFA_TOKEN
+		}), false},
+		{"wrong secret", sign(t, jwt.SigningMethodHS256, []byte("other-secret"), "", accessClaims(now)), false},
+		{"unknown kid", sign(t, jwt.SigningMethodHS256, []byte(testSecret), "retired", accessClaims(now)), false},
+		{"alg none", sign(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "", accessClaims(now)), false},
+		{"empty", "", false},
+		{"not a jwt", "not-a-jwt", false},
+		{"two segments", valid[:strings.LastIndex(valid, ".")], false},
+		{"truncated signature", valid[:len(valid)-5], false},
+		{"garbage segments", "abc.def.ghi", false},
+		{"tampered payload", tamperPayload(t, valid), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			claims, customError := ParseAccessToken(test.token)
+			if test.valid {
+				if customError.Cause != nil {
+					t.Fatalf("expected the token to be accepted, got %s", customError.Cause)
+				}
+				if claims.UserID == uuid.Nil {
+					t.Fatal("expected the claims to carry the user id")
+				}
+				return
+			}
+
+			if customError.Cause == nil {
+				t.Fatal("expected the token to be rejected")
+			}
+			if customError.Code != UNAUTHORIZED {
+				t.Fatalf("expected code %d, got %d", UNAUTHORIZED, customError.Code)
+			}
+		})
+	}
+}
+
+func TestParseAccessTokenRS256(t *testing.T) {
+	publicPem := loadRS256KeyRing(t, "current")
+	now := time.Now()
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{"valid", sign(t, jwt.SigningMethodRS256, keyRing.signing.Private, "current", accessClaims(now)), true},
+		{"without kid", sign(t, jwt.SigningMethodRS256, keyRing.signing.Private, "", accessClaims(now)), false},
+		{"unknown kid", sign(t, jwt.SigningMethodRS256, keyRing.signing.Private, "retired", accessClaims(now)), false},
+		{"other key", sign(t, jwt.SigningMethodRS256, otherKey, "current", accessClaims(now)), false},
+		// the classic algorithm confusion attack: an HMAC token whose secret is
+		// the published public key
+		{"HS256 signed with the public key pem", sign(t, jwt.SigningMethodHS256, publicPem, "current", accessClaims(now)), false},
+		{"HS256 signed with the public key der", sign(t, jwt.SigningMethodHS256, pemBytes(t, publicPem), "current", accessClaims(now)), false},
+		{"HS256 signed with the old secret", sign(t, jwt.SigningMethodHS256, []byte(testSecret), "current", accessClaims(now)), false},
+		{"alg none", sign(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "current", accessClaims(now)), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, customError := ParseAccessToken(test.token)
+			if test.valid && customError.Cause != nil {
+				t.Fatalf("expected the token to be accepted, got %s", customError.Cause)
+			}
+			if !test.valid && customError.Cause == nil {
+				t.Fatal("expected the token to be rejected")
+			}
+		})
+	}
+}
+
+func TestIssuedTokensRoundTrip(t *testing.T) {
+	loadHS256KeyRing(t)
+
+	userId := uuid.New()
+	sessionId := uuid.New()
+
+	accessToken, customError := CreateAccessToken(userId, TEACHER_ROLE, sessionId)
+	if customError.Cause != nil {
+		t.Fatal(customError.Cause)
+	}
+
+	claims, customError := ParseAccessToken(accessToken)
+	if customError.Cause != nil {
+		t.Fatal(customError.Cause)
+	}
+	if claims.UserID != userId || claims.Role != TEACHER_ROLE || claims.SessionID != sessionId {
+		t.Fatalf("unexpected claims %+v", claims)
+	}
+
+	refreshToken, _, customError := CreateRefreshToken(userId, accessToken, TEACHER_ROLE, uuid.New(), sessionId)
+	if customError.Cause != nil {
+		t.Fatal(customError.Cause)
+	}
+
+	if _, customError := ParseAccessToken(refreshToken); customError.Cause == nil {
+		t.Fatal("a refresh token must not pass as an access token")
+	}
+
+	if _, customError := ParseRefreshToken(accessToken); customError.Cause == nil {
+		t.Fatal("an access token must not pass as a refresh token")
+	}
+
+	refreshClaims, customError := ParseRefreshToken(refreshToken)
+	if customError.Cause != nil {
+		t.Fatal(customError.Cause)
+	}
+	if refreshClaims.FamilyID != sessionId {
+		t.Fatalf("expected family %s, got %s", sessionId, refreshClaims.FamilyID)
+	}
+}
+
+func tamperPayload(t *testing.T, token string) string {
+	t.Helper()
+
+	parts := strings.Split(token, ".")
+	claims := accessClaims(time.Now())
+	claims.Role = ADMIN_ROLE
+	forged := sign(t, jwt.SigningMethodHS256, []byte("other-secret"), "", claims)
+
+	return parts[0] + "." + strings.Split(forged, ".")[1] + "." + parts[2]
+}
+
+func pemBytes(t *testing.T, encoded []byte) []byte {
+	t.Helper()
+
+	block, _ := pem.Decode(encoded)
+	if block == nil {
+		t.Fatal("invalid pem")
+	}
+
+	return block.Bytes
+}
@@ -0,0 +1,223 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/spf13/viper"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// JwtKey is one key the service knows about. Private is only set for the key
+// currently used for signing.
+type JwtKey struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private interface{}
+	Public  interface{}
+}
+
+type KeyRing struct {
+	signing      *JwtKey
+	verification map[string]*JwtKey
+}
+
+var keyRing *KeyRing
+
+// LoadKeyRing reads the signing key and every verification key from config.
+// HS256 keeps using SECRET_JWT; RS256 and EdDSA read a PEM private key from
+// JWT_SIGNING_KEY_FILE and any retired public keys from JWT_VERIFICATION_KEYS_DIR,
+// where each file is named <kid>.pem.
+func LoadKeyRing() error {
+	ring := &KeyRing{
+		verification: make(map[string]*JwtKey),
+	}
+
+	kid := viper.GetString("JWT_SIGNING_KEY_ID")
+	algorithm := viper.GetString("JWT_ALGORITHM")
+
+	switch algorithm {
+	case "", jwt.SigningMethodHS256.Alg():
+		secret := viper.GetString("SECRET_JWT")
+		if secret == "" {
+			return fmt.Errorf("SECRET_JWT is required for HS256")
+		}
+		ring.signing = &JwtKey{
+			ID:      kid,
+			Method:  jwt.SigningMethodHS256,
+			Private: []byte(secret),
+			Public:  []byte(secret),
+		}
+	case jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg():
+		if kid == "" {
+			return fmt.Errorf("JWT_SIGNING_KEY_ID is required for %s", algorithm)
+		}
+		pemBytes, err := os.ReadFile(viper.GetString("JWT_SIGNING_KEY_FILE"))
+		if err != nil {
+			return fmt.Errorf("reading signing key: %w", err)
+		}
+		ring.signing, err = parsePrivateKey(kid, algorithm, pemBytes)
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported JWT_ALGORITHM %q", algorithm)
+	}
+
+	ring.verification[ring.signing.ID] = ring.signing
+
+	if dir := viper.GetString("JWT_VERIFICATION_KEYS_DIR"); dir != "" {
+		files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			id := strings.TrimSuffix(filepath.Base(file), ".pem")
+			if _, exists := ring.verification[id]; exists {
+				continue
+			}
+			pemBytes, err := os.ReadFile(file)
+			if err != nil {
+				return fmt.Errorf("reading verification key %s: %w", file, err)
+			}
+			key, err := parsePublicKey(id, pemBytes)
+			if err != nil {
+				return err
+			}
+			ring.verification[id] = key
+		}
+	}
+
+	keyRing = ring
+	return nil
+}
+
+func parsePrivateKey(kid string, algorithm string, pemBytes []byte) (*JwtKey, error) {
+	if algorithm == jwt.SigningMethodRS256.Alg() {
+		private, err := jwt.ParseRSAPrivateKeyFromPEM(pemBytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing RSA signing key: %w", err)
+		}
+		return &JwtKey{ID: kid, Method: jwt.SigningMethodRS256, Private: private, Public: &private.PublicKey}, nil
+	}
+
+	private, err := jwt.ParseEdPrivateKeyFromPEM(pemBytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing Ed25519 signing key: %w", err)
+	}
+	edPrivate, ok := private.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("signing key is not an Ed25519 key")
+	}
+	return &JwtKey{ID: kid, Method: jwt.SigningMethodEdDSA, Private: edPrivate, Public: edPrivate.Public()}, nil
+}
+
+func parsePublicKey(kid string, pemBytes []byte) (*JwtKey, error) {
+	if public, err := jwt.ParseRSAPublicKeyFromPEM(pemBytes); err == nil {
+		return &JwtKey{ID: kid, Method: jwt.SigningMethodRS256, Public: public}, nil
+	}
+
+	public, err := jwt.ParseEdPublicKeyFromPEM(pemBytes)
+	if err != nil {
+		return nil, fmt.Errorf("verification key %s is neither RSA nor Ed25519", kid)
+	}
+	return &JwtKey{ID: kid, Method: jwt.SigningMethodEdDSA, Public: public}, nil
+}
+
+func signToken(claims jwt.Claims) (string, error) {
+	if keyRing == nil {
+		return "", fmt.Errorf("signing keys are not loaded")
+	}
+
+	token := jwt.NewWithClaims(keyRing.signing.Method, claims)
+	if keyRing.signing.ID != "" {
+		token.Header["kid"] = keyRing.signing.ID
+	}
+
+	return token.SignedString(keyRing.signing.Private)
+}
+
+func validMethods() []string {
+	if keyRing == nil {
+		return nil
+	}
+
+	var methods []string
+	seen := make(map[string]bool)
+	for _, key := range keyRing.verification {
+		if !seen[key.Method.Alg()] {
+			seen[key.Method.Alg()] = true
+			methods = append(methods, key.Method.Alg())
+		}
+	}
+
+	return methods
+}
+
+// verificationKey is the jwt.Keyfunc for every token we accept. The key is
+// picked by kid and must match the algorithm in the header, so a public key
+// can never be used as an HMAC secret.
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	if keyRing == nil {
+		return nil, fmt.Errorf("signing keys are not loaded")
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, ok := keyRing.verification[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+	}
+
+	return key.Public, nil
+}
+
+// JWKS returns the public verification keys as a JSON Web Key Set. Shared
+// HMAC secrets are never published.
+func JWKS() map[string]interface{} {
+	keys := []map[string]interface{}{}
+	if keyRing == nil {
+		return map[string]interface{}{"keys": keys}
+	}
+
+	ids := make([]string, 0, len(keyRing.verification))
+	for id := range keyRing.verification {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		key := keyRing.verification[id]
+		switch public := key.Public.(type) {
+		case *rsa.PublicKey:
+			keys = append(keys, map[string]interface{}{
+				"kty": "RSA",
+				"use": "sig",
+				"alg": key.Method.Alg(),
+				"kid": key.ID,
+				"n":   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			keys = append(keys, map[string]interface{}{
+				"kty": "OKP",
+				"crv": "Ed25519",
+				"use": "sig",
+				"alg": key.Method.Alg(),
+				"kid": key.ID,
+				"x":   base64.RawURLEncoding.EncodeToString(public),
+			})
+		}
+	}
+
+	return map[string]interface{}{"keys": keys}
+}
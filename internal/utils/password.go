@@ -0,0 +1,238 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/rifkhia/lms-remake/internal/pkg"
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+)
+
+const (
+	BCRYPT_ALGORITHM   = "bcrypt"
+	ARGON2ID_ALGORITHM = "argon2id"
+)
+
+const PASSWORD_MIN_LENGTH = 8
+
+// bcrypt ignores everything past 72 bytes, so longer passwords are refused
+// rather than silently truncated.
+const PASSWORD_MAX_LENGTH = 72
+
+const argon2SaltLength = 16
+const argon2KeyLength = 32
+
+//go:embed common_passwords.txt
+var commonPasswordList string
+
+var commonPasswords = loadCommonPasswords()
+
+type argon2Params struct {
+	Memory  uint32
+	Time    uint32
+	Threads uint8
+}
+
+func loadCommonPasswords() map[string]struct{} {
+	passwords := make(map[string]struct{})
+	scanner := bufio.NewScanner(strings.NewReader(commonPasswordList))
+	for scanner.Scan() {
+		if password := strings.TrimSpace(scanner.Text()); password != "" {
+			passwords[strings.ToLower(password)] = struct{}{}
+		}
+	}
+	return passwords
+}
+
+func passwordHashAlgorithm() string {
+	algorithm := strings.ToLower(viper.GetString("PASSWORD_HASH_ALGORITHM"))
+	if algorithm == "" {
+		return BCRYPT_ALGORITHM
+	}
+	return algorithm
+}
+
+func bcryptCost() int {
+	cost := viper.GetInt("BCRYPT_COST")
+	if cost < bcrypt.MinCost {
+		return bcrypt.DefaultCost
+	}
+	return cost
+}
+
+func configuredArgon2Params() argon2Params {
+	return argon2Params{
+		Memory:  viper.GetUint32("ARGON2_MEMORY"),
+		Time:    viper.GetUint32("ARGON2_TIME"),
+		Threads: uint8(viper.GetUint("ARGON2_THREADS")),
+	}
+}
+
+// ValidatePasswordPolicy checks a new password before it is hashed.
+func ValidatePasswordPolicy(password string) error {
+	if len(password) < PASSWORD_MIN_LENGTH {
+		return fmt.Errorf("password length must be at least %d", PASSWORD_MIN_LENGTH)
+	}
+
+	if len(password) > PASSWORD_MAX_LENGTH {
+		return fmt.Errorf("password length must be at most %d bytes", PASSWORD_MAX_LENGTH)
+	}
+
+	if _, ok := commonPasswords[strings.ToLower(password)]; ok {
+		return errors.New("password is too common, please choose another one")
+	}
+
+	return nil
+}
+
+// GeneratePassword hashes with the algorithm in PASSWORD_HASH_ALGORITHM. Both
+// formats carry their algorithm and parameters, so old hashes stay verifiable
+// after the configuration changes.
+func GeneratePassword(password string) (string, error) {
+	switch passwordHashAlgorithm() {
+	case BCRYPT_ALGORITHM:
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost())
+		if err != nil {
+			return "", err
+		}
+		return string(hash), nil
+	case ARGON2ID_ALGORITHM:
+		return generateArgon2id(password, configuredArgon2Params())
+	default:
+		return "", fmt.Errorf("unsupported PASSWORD_HASH_ALGORITHM %q", passwordHashAlgorithm())
+	}
+}
+
+func ValidatePassword(userPassword string, inputPassword string) pkg.CustomError {
+	var err error
+	if strings.HasPrefix(userPassword, "$"+ARGON2ID_ALGORITHM+"$") {
+		err = compareArgon2id(userPassword, inputPassword)
+	} else {
+		err = bcrypt.CompareHashAndPassword([]byte(userPassword), []byte(inputPassword))
+	}
+
+	if err != nil {
+		return pkg.CustomError{
+			Cause:   errors.New("invalid credentials"),
+			Service: USECASE_SERVICE,
+			Code:    UNAUTHORIZED,
+		}
+	}
+	return pkg.CustomError{}
+}
+
+// PasswordNeedsRehash reports whether the hash was made with another algorithm
+// or weaker parameters than the ones currently configured.
+func PasswordNeedsRehash(hash string) bool {
+	if strings.HasPrefix(hash, "$"+ARGON2ID_ALGORITHM+"$") {
+		if passwordHashAlgorithm() != ARGON2ID_ALGORITHM {
+			return true
+		}
+
+		params, _, _, err := decodeArgon2id(hash)
+		if err != nil {
+			return true
+		}
+
+		configured := configuredArgon2Params()
+		return params.Memory < configured.Memory || params.Time < configured.Time || params.Threads != configured.Threads
+	}
+
+	if passwordHashAlgorithm() != BCRYPT_ALGORITHM {
+		return true
+	}
+
+	cost, err := bcrypt.Cost([]byte(hash))
+	if err != nil {
+		return true
+	}
+
+	return cost < bcryptCost()
+}
+
+func generateArgon2id(password string, params argon2Params) (string, error) {
+	if params.Memory == 0 || params.Time == 0 || params.Threads == 0 {
+		return "", errors.New("ARGON2_MEMORY, ARGON2_TIME and ARGON2_THREADS must be positive")
+	}
+
+	salt := make([]byte, argon2SaltLength)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, argon2KeyLength)
+
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		ARGON2ID_ALGORITHM,
+		argon2.Version,
+		params.Memory,
+		params.Time,
+		params.Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// decodeArgon2id parses the PHC string format
+// $argon2id$v=19$m=<memory>,t=<time>,p=<threads>$<salt>$<key>.
+func decodeArgon2id(hash string) (argon2Params, []byte, []byte, error) {
+	var params argon2Params
+	var version int
+
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != ARGON2ID_ALGORITHM {
+		return params, nil, nil, errors.New("invalid argon2id hash")
+	}
+
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return params, nil, nil, errors.New("unsupported argon2id version")
+	}
+
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads)
+	if err != nil {
+		return params, nil, nil, errors.New("invalid argon2id parameters")
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, err
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return params, nil, nil, err
+	}
+
+	return params, salt, key, nil
+}
+
+func compareArgon2id(hash string, password string) error {
+	params, salt, key, err := decodeArgon2id(hash)
+	if err != nil {
+		return err
+	}
+
+	inputKey := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, uint32(len(key)))
+	if subtle.ConstantTimeCompare(key, inputKey) != 1 {
+		return errors.New("password does not match")
+	}
+
+	return nil
+}
+
+// HashToken is for high-entropy random tokens, which don't need a slow hash.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -1,11 +1,44 @@
 package utils
 
-import "math/rand"
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"math/big"
+)
 
-func GenerateClassKey(n int) string {
-	b := make([]rune, n)
+// GenerateClassKey returns n random letters and digits from crypto/rand. Class
+// keys and invite codes are typed in by hand, so they avoid punctuation.
+func GenerateClassKey(n int) (string, error) {
+	b := make([]byte, n)
 	for i := range b {
-		b[i] = rune(LETTER_RUNES[rand.Intn(len(LETTER_RUNES))])
+		index, err := rand.Int(rand.Reader, big.NewInt(int64(len(LETTER_RUNES))))
+		if err != nil {
+			return "", err
+		}
+		b[i] = LETTER_RUNES[index.Int64()]
 	}
-	return string(b)
+	return string(b), nil
+}
+
+// GenerateOTP returns n random decimal digits from crypto/rand.
+func GenerateOTP(n int) (string, error) {
+	b := make([]byte, n)
+	for i := range b {
+		digit, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+		b[i] = byte('0' + digit.Int64())
+	}
+	return string(b), nil
+}
+
+// GenerateToken returns n random bytes encoded as URL-safe base64.
+func GenerateToken(n int) (string, error) {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
 }
@@ -0,0 +1,194 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/spf13/viper"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TOTP as in RFC 6238 with the parameters every authenticator app supports:
+// HMAC-SHA1, 6 digits and a 30 second step.
+const TOTP_DIGITS = 6
+const TOTP_PERIOD_SECONDS = 30
+
+// TOTP_SKEW_STEPS is how many steps before and after now are accepted, to
+// allow for clock drift on the phone.
+const TOTP_SKEW_STEPS = 1
+
+var base32NoPadding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTOTPSecret returns a new 160 bit secret in base32, the form
+// authenticator apps expect.
+func GenerateTOTPSecret() (string, error) {
+	b := make([]byte, 20)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base32NoPadding.EncodeToString(b), nil
+}
+
+// TOTPProvisioningURI is the otpauth:// URI clients render as a QR code.
+func TOTPProvisioningURI(accountName string, secret string) string {
+	issuer := viper.GetString("TOTP_ISSUER")
+	query := url.Values{
+		"secret":    {secret},
+		"issuer":    {issuer},
+		"algorithm": {"SHA1"},
+		"digits":    {fmt.Sprint(TOTP_DIGITS)},
+		"period":    {fmt.Sprint(TOTP_PERIOD_SECONDS)},
+	}
+
+	label := url.PathEscape(issuer + ":" + accountName)
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+func totpCode(key []byte, step int64) string {
+	counter := make([]byte, 8)
+	binary.BigEndian.PutUint64(counter, uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	modulo := uint32(1)
+	for i := 0; i < TOTP_DIGITS; i++ {
+		modulo *= 10
+	}
+
+	return fmt.Sprintf("%0*d", TOTP_DIGITS, value%modulo)
+}
+
+// ValidateTOTP checks code against the steps around now and returns the step
+// that matched, so the caller can refuse to accept it a second time.
+func ValidateTOTP(secret string, code string, now time.Time) (int64, bool) {
+	key, err := base32NoPadding.DecodeString(strings.ToUpper(secret))
+	if err != nil || len(code) != TOTP_DIGITS {
+		return 0, false
+	}
+
+	current := now.Unix() / TOTP_PERIOD_SECONDS
+	for step := current - TOTP_SKEW_STEPS; step <= current+TOTP_SKEW_STEPS; step++ {
+		if subtle.ConstantTimeCompare([]byte(totpCode(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+
+	return 0, false
+}
+
+// TOTP_ENCRYPTION_KEY_MIN_LENGTH keeps the key from being guessable.
+const TOTP_ENCRYPTION_KEY_MIN_LENGTH = 32
+
+// CheckTOTPEncryptionKey is run at startup, so a missing key stops the server
+// instead of failing every two-factor enrollment.
+func CheckTOTPEncryptionKey() error {
+	_, err := totpCipher()
+	return err
+}
+
+func totpCipher() (cipher.AEAD, error) {
+	secret := viper.GetString("TOTP_ENCRYPTION_KEY")
+	if secret == "" {
+		return nil, errors.New("TOTP_ENCRYPTION_KEY is not set")
+	}
+
+	if len(secret) < TOTP_ENCRYPTION_KEY_MIN_LENGTH {
+		return nil, fmt.Errorf("TOTP_ENCRYPTION_KEY must be at least %d characters", TOTP_ENCRYPTION_KEY_MIN_LENGTH)
+	}
+
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// EncryptTOTPSecret seals a TOTP secret with TOTP_ENCRYPTION_KEY, so a leaked
+// database alone can't be used to generate codes.
+func EncryptTOTPSecret(secret string) (string, error) {
+	aead, err := totpCipher()
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+
+	sealed := aead.Seal(nonce, nonce, []byte(secret), nil)
+	return base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+func DecryptTOTPSecret(encrypted string) (string, error) {
+	aead, err := totpCipher()
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := base64.RawStdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return "", err
+	}
+
+	if len(sealed) < aead.NonceSize() {
+		return "", errors.New("encrypted totp secret is too short")
+	}
+
+	secret, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+
+	return string(secret), nil
+}
+
+// GenerateRecoveryCode returns a code like "k3f9-x2m7" from an alphabet
+// without characters that are easy to mix up.
+func GenerateRecoveryCode() (string, error) {
+	const alphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+	code := make([]byte, 0, 9)
+	for i := 0; i < 8; i++ {
+		if i == 4 {
+			code = append(code, '-')
+		}
+		index, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+		if err != nil {
+			return "", err
+		}
+		code = append(code, alphabet[index.Int64()])
+	}
+
+	return string(code), nil
+}
+
+// NormalizeRecoveryCode makes a typed recovery code comparable to the stored one.
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), " ", ""))
+	if len(code) == 8 && !strings.Contains(code, "-") {
+		code = code[:4] + "-" + code[4:]
+	}
+	return code
+}